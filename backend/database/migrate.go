@@ -0,0 +1,20 @@
+package database
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+// cancelDuplicateContactRequests keeps only the oldest pending request from one user to another
+// and cancels the rest, so the unique index on pending requests can be created.
+func cancelDuplicateContactRequests(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&models.ContactRequest{}) {
+		return nil
+	}
+	oldest := db.Model(&models.ContactRequest{}).Select("MIN(id)").
+		Where("status = ?", models.ContactRequestPending).Group("from_id, to_id")
+	return db.Model(&models.ContactRequest{}).
+		Where("status = ? AND id NOT IN (?)", models.ContactRequestPending, oldest).
+		Update("status", models.ContactRequestCancelled).Error
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestCancelDuplicateContactRequests(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(t.TempDir()+"/facetime.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.ContactRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	// a database from before the unique index could hold duplicates
+	if err := db.Migrator().DropIndex(&models.ContactRequest{}, "idx_pending_request"); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&[]models.ContactRequest{
+		{FromId: 1, ToId: 2, Status: models.ContactRequestPending},
+		{FromId: 1, ToId: 2, Status: models.ContactRequestPending},
+		{FromId: 2, ToId: 1, Status: models.ContactRequestPending},
+		{FromId: 1, ToId: 3, Status: models.ContactRequestDeclined},
+		{FromId: 1, ToId: 3, Status: models.ContactRequestPending},
+	})
+
+	if err := cancelDuplicateContactRequests(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.ContactRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if !db.Migrator().HasIndex(&models.ContactRequest{}, "idx_pending_request") {
+		t.Fatal("unique index on pending requests not created")
+	}
+	var pending []uint
+	db.Model(&models.ContactRequest{}).Where("status = ?", models.ContactRequestPending).Order("id").Pluck("id", &pending)
+	if want := []uint{1, 3, 5}; len(pending) != len(want) || pending[0] != 1 || pending[1] != 3 || pending[2] != 5 {
+		t.Errorf("pending requests %v, want %v", pending, want)
+	}
+	if err := db.Create(&models.ContactRequest{FromId: 1, ToId: 2, Status: models.ContactRequestPending}).Error; err == nil {
+		t.Error("a second pending request from 1 to 2 was stored")
+	}
+}
@@ -21,7 +21,24 @@ func InitSqliteDB() {
 
 	Db.AutoMigrate(&models.User{})
 	Db.AutoMigrate(&models.Call{})
+	Db.AutoMigrate(&models.Recording{})
+	Db.AutoMigrate(&models.Message{})
 	Db.AutoMigrate(&models.UserContact{})
 	Db.AutoMigrate(&models.History{})
+	Db.AutoMigrate(&models.Room{})
+	Db.AutoMigrate(&models.Meeting{})
+	Db.AutoMigrate(&models.MeetingInvitee{})
+	Db.AutoMigrate(&models.Poll{})
+	Db.AutoMigrate(&models.PollOption{})
+	Db.AutoMigrate(&models.PollVote{})
+	Db.AutoMigrate(&models.CallForwardRule{})
+	if err := cancelDuplicateContactRequests(Db); err != nil {
+		log.Fatal("Failed to migrate contact requests:", err)
+	}
+	Db.AutoMigrate(&models.ContactRequest{})
+	Db.AutoMigrate(&models.UserBlock{})
+	Db.AutoMigrate(&models.ContactGroup{})
+	Db.AutoMigrate(&models.ContactGroupMember{})
+	Db.AutoMigrate(&models.Voicemail{})
 
 }
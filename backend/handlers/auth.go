@@ -50,6 +50,18 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
 			return
 		}
+		if user.Guest != claims.Guest {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		if claims.Guest {
+			scope := models.GuestScope{RoomId: claims.RoomId, CallId: claims.CallId}
+			if !guestMayAccess(c, scope) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "guests can only access the call they were invited to"})
+				return
+			}
+			c.Set("guestScope", scope)
+		}
 		c.Set("authUser", user)
 		c.Next()
 	}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// maxBreakoutCountdown bounds the warning given before breakout rooms close.
+const maxBreakoutCountdown = 10 * time.Minute
+
+// breakoutSession resolves :id to the main call of a live session, accepting the id of one of its
+// breakout rooms too. The caller must be in the main call or one of its rooms.
+func breakoutSession(c *gin.Context) (*ws.CallSession, models.User, bool) {
+	callId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid call id"})
+		return nil, models.User{}, false
+	}
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return nil, models.User{}, false
+	}
+	authUser := ai.(models.User)
+
+	if wsHub == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "call service unavailable"})
+		return nil, authUser, false
+	}
+	session, ok := wsHub.GetCallSession(uint(callId))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "call session not found"})
+		return nil, authUser, false
+	}
+	if session.Parent != nil {
+		session = session.Parent
+	}
+	if !session.HasMember(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a participant of this call"})
+		return nil, authUser, false
+	}
+	return session, authUser, true
+}
+
+// breakoutError maps errors from the ws breakout API onto HTTP responses.
+func breakoutError(c *gin.Context, callID uint, err error) {
+	switch {
+	case errors.Is(err, ws.ErrNotPermitted):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrNoBreakouts), errors.Is(err, ws.ErrUnknownBreakout), errors.Is(err, ws.ErrNotInCall):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrBreakoutsOpen):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrInvalidBreakouts):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("breakout error for call %d: %v", callID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update breakout rooms"})
+	}
+}
+
+// OpenBreakouts splits a live call into breakout rooms; host and moderators only. Rooms are named
+// from "names" when given, otherwise "Room 1".."Room N" for "count".
+func OpenBreakouts(c *gin.Context) {
+	session, authUser, ok := breakoutSession(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Count       int      `json:"count"`
+		Names       []string `json:"names"`
+		AllowChoice bool     `json:"allowChoice"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	names := make([]string, 0, len(req.Names))
+	for _, n := range req.Names {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		if req.Count < 1 || req.Count > 50 {
+			breakoutError(c, session.ID, ws.ErrInvalidBreakouts)
+			return
+		}
+		for i := 1; i <= req.Count; i++ {
+			names = append(names, fmt.Sprintf("Room %d", i))
+		}
+	}
+
+	if err := wsHub.OpenBreakouts(session, authUser.Id, names, req.AllowChoice); err != nil {
+		breakoutError(c, session.ID, err)
+		return
+	}
+	c.JSON(http.StatusCreated, session.Breakouts())
+}
+
+// GetBreakouts lists the breakout rooms of a call and who is in each.
+func GetBreakouts(c *gin.Context) {
+	session, _, ok := breakoutSession(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, session.Breakouts())
+}
+
+// AssignBreakout moves a participant into a breakout room, or back to the main call when
+// breakoutId is the main call's id. userId defaults to the caller, who may only pick a room
+// themselves when the host allowed it.
+func AssignBreakout(c *gin.Context) {
+	session, authUser, ok := breakoutSession(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		UserId     uint `json:"userId"`
+		BreakoutId uint `json:"breakoutId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.UserId == 0 {
+		req.UserId = authUser.Id
+	}
+	if err := session.MoveToBreakout(authUser.Id, req.UserId, req.BreakoutId); err != nil {
+		breakoutError(c, session.ID, err)
+		return
+	}
+	c.JSON(http.StatusOK, session.Breakouts())
+}
+
+// ReturnFromBreakout brings the caller back from their breakout room to the main call.
+func ReturnFromBreakout(c *gin.Context) {
+	session, authUser, ok := breakoutSession(c)
+	if !ok {
+		return
+	}
+	if err := session.MoveToBreakout(authUser.Id, authUser.Id, session.ID); err != nil {
+		breakoutError(c, session.ID, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"callId": session.ID})
+}
+
+// BroadcastToBreakouts sends a message from the host or a moderator to the main call and every
+// breakout room.
+func BroadcastToBreakouts(c *gin.Context) {
+	session, authUser, ok := breakoutSession(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Message string `json:"message" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !session.CanModerate(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the host or a moderator can broadcast to breakout rooms"})
+		return
+	}
+	if len(session.Breakouts().Rooms) == 0 {
+		breakoutError(c, session.ID, ws.ErrNoBreakouts)
+		return
+	}
+	session.NotifyBreakouts(models.WebSocketMessage{
+		Type:    models.MessageTypeBreakoutBroadcast,
+		Payload: models.BreakoutNoticeMessage{CallId: session.ID, From: authUser.Id, Message: req.Message},
+		Time:    time.Now(),
+	})
+	c.JSON(http.StatusOK, gin.H{"callId": session.ID})
+}
+
+// CloseBreakouts brings everyone back to the main call and ends the breakout rooms, optionally
+// after ?countdown= seconds announced to every room.
+func CloseBreakouts(c *gin.Context) {
+	session, authUser, ok := breakoutSession(c)
+	if !ok {
+		return
+	}
+	var countdown time.Duration
+	if v := c.Query("countdown"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 || time.Duration(secs)*time.Second > maxBreakoutCountdown {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "countdown must be between 0 and 600 seconds"})
+			return
+		}
+		countdown = time.Duration(secs) * time.Second
+	}
+	if err := wsHub.CloseBreakouts(session, authUser.Id, countdown); err != nil {
+		breakoutError(c, session.ID, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"callId": session.ID, "closesIn": int(countdown / time.Second)})
+}
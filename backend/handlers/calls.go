@@ -1,18 +1,23 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/Neb-iyu/facetime-app/backend/database"
 	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
 	"github.com/gin-gonic/gin"
 )
 
 // createCallPayload no longer accepts callerId from client; caller is authenticated user.
+// GroupId rings the members of one of the caller's contact groups, on top of any CalleeIds.
 type createCallPayload struct {
 	CalleeIds []uint `json:"calleeIds"`
+	GroupId   *uint  `json:"groupId"`
 	Options   any    `json:"options"`
 }
 
@@ -30,9 +35,30 @@ func CreateCall(c *gin.Context) {
 	}
 	authUser := ai.(models.User)
 
+	callees := p.CalleeIds
+	if p.GroupId != nil {
+		members, err := groupCallees(authUser.Id, *p.GroupId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "contact group not found"})
+			return
+		}
+		if len(members) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "contact group has no members"})
+			return
+		}
+		seen := map[uint]bool{authUser.Id: true}
+		callees = nil
+		for _, id := range append(p.CalleeIds, members...) {
+			if !seen[id] {
+				seen[id] = true
+				callees = append(callees, id)
+			}
+		}
+	}
+
 	call := models.Call{
 		CallerId:  authUser.Id,
-		CalleeIds: p.CalleeIds,
+		CalleeIds: callees,
 		StartTime: time.Now(),
 		Status:    models.Ringing,
 	}
@@ -41,23 +67,45 @@ func CreateCall(c *gin.Context) {
 		return
 	}
 	if wsHub != nil {
-		wsHub.CreateCallSession(&call)
+		wsHub.Ring(wsHub.CreateCallSession(&call))
 	}
 	c.JSON(http.StatusCreated, call)
 }
 
+// GetCall returns a call with its polls to someone who took part in it.
 func GetCall(c *gin.Context) {
-	id := c.Param("id")
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid call id"})
+		return
+	}
+	if !canAccessCall(authUser.Id, uint(id)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a participant of this call"})
+		return
+	}
 	var call models.Call
 	if err := database.Db.First(&call, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "call not found"})
 		return
 	}
+	loadCallPolls(&call)
 	c.JSON(http.StatusOK, call)
 }
 
+// JoinCall attaches the user's WS connection to a live call they were invited or admitted to,
+// e.g. a guest who redeemed a call invite.
 func JoinCall(c *gin.Context) {
-	callId := c.Param("id")
+	callId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid call id"})
+		return
+	}
 	ai, ok := c.Get("authUser")
 	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
@@ -65,9 +113,23 @@ func JoinCall(c *gin.Context) {
 	}
 	authUser := ai.(models.User)
 
-	// optional: record join in DB
-	_ = callId
-	_ = authUser
+	if wsHub == nil {
+		c.Status(http.StatusOK)
+		return
+	}
+	session, ok := wsHub.GetCallSession(uint(callId))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "call session not found"})
+		return
+	}
+	if !session.CanJoin(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not invited to this call"})
+		return
+	}
+	if !wsHub.AttachClient(session, authUser.Id) {
+		c.JSON(http.StatusConflict, gin.H{"error": "connect to /ws before joining"})
+		return
+	}
 	c.Status(http.StatusOK)
 }
 
@@ -101,8 +163,20 @@ func LeaveCall(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// EndCall ends a call. While the call is live only its host or a moderator may end it, and
+// every participant is disconnected.
 func EndCall(c *gin.Context) {
 	id := c.Param("id")
+	if callId, err := strconv.ParseUint(id, 10, 32); err == nil && wsHub != nil {
+		if session, ok := wsHub.GetCallSession(uint(callId)); ok {
+			ai, _ := c.Get("authUser")
+			err := session.Moderate(ai.(models.User).Id, models.ModerationRequest{Action: models.ActionEndCall})
+			if errors.Is(err, ws.ErrNotPermitted) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "only the host or a moderator can end the call"})
+				return
+			}
+		}
+	}
 	if err := database.Db.Model(&models.Call{}).Where("id = ?", id).Update("status", models.Ended).Error; err != nil {
 		log.Printf("end call error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to end call"})
@@ -111,13 +185,53 @@ func EndCall(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// GetCallParticipants lists who is connected to a live call, with roles and guests marked.
 func GetCallParticipants(c *gin.Context) {
-	callId := c.Param("id")
-	// TODO: implement query to join call participants table; returning placeholder for now
-	var participants []map[string]any
-	participants = append(participants, map[string]any{"userId": 1, "status": "connected"})
-	_ = callId
-	c.JSON(http.StatusOK, participants)
+	session, _, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, session.ParticipantList())
+}
+
+// GetCallStats returns the latest per-participant media stats of a live call.
+func GetCallStats(c *gin.Context) {
+	session, _, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, session.GetStats())
+}
+
+// liveCallSession resolves :id to a live call session the authenticated user takes part in.
+// It writes the error response itself and returns ok=false when the request should stop.
+func liveCallSession(c *gin.Context) (*ws.CallSession, models.User, bool) {
+	callId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid call id"})
+		return nil, models.User{}, false
+	}
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return nil, models.User{}, false
+	}
+	authUser := ai.(models.User)
+
+	if wsHub == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "call service unavailable"})
+		return nil, authUser, false
+	}
+	session, ok := wsHub.GetCallSession(uint(callId))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "call session not found"})
+		return nil, authUser, false
+	}
+	if !session.HasParticipant(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a participant of this call"})
+		return nil, authUser, false
+	}
+	return session, authUser, true
 }
 
 // media control endpoints (placeholders)
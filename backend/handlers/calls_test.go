@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestGetCallOnlyForParticipants(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "alice", "eve")
+	alice, eve := users[0], users[1]
+
+	call := models.Call{CallerId: alice.Id, StartTime: time.Now(), Status: models.Ended}
+	database.Db.Create(&call)
+	database.Db.Create(&models.Poll{CallId: call.Id, CreatorId: alice.Id, Question: "Again?", Closed: true,
+		Options: []models.PollOption{{Text: "yes"}, {Position: 1, Text: "no"}}})
+
+	path := fmt.Sprintf("/calls/%d", call.Id)
+	var got models.Call
+	if code := serve(t, alice, http.MethodGet, "/calls/:id", path, nil, GetCall, &got); code != http.StatusOK {
+		t.Fatalf("caller: status %d, want 200", code)
+	}
+	if len(got.Polls) != 1 || len(got.Polls[0].Options) != 2 {
+		t.Errorf("caller got polls %+v, want one poll with 2 options", got.Polls)
+	}
+	if code := serve(t, eve, http.MethodGet, "/calls/:id", path, nil, GetCall, nil); code != http.StatusForbidden {
+		t.Errorf("non-participant: status %d, want 403", code)
+	}
+}
+
+func TestCreateCallRingsCallees(t *testing.T) {
+	testDB(t)
+	h := testHub(t)
+	users := testUsers(t, "alice", "bob", "carol")
+	alice, bob, carol := users[0], users[1], users[2]
+	ca, cb := connect(h, alice.Id), connect(h, bob.Id)
+
+	var call models.Call
+	body := map[string]any{"calleeIds": []uint{bob.Id, carol.Id}}
+	if code := serve(t, alice, http.MethodPost, "/calls", "/calls", body, CreateCall, &call); code != http.StatusCreated {
+		t.Fatalf("status %d, want 201", code)
+	}
+	if n := len(messagesOfType(received(cb), "incoming_call")); n != 1 {
+		t.Errorf("bob got %d incoming_call, want 1", n)
+	}
+	unavailable := messagesOfType(received(ca), models.MessageTypeCalleeUnavailable)
+	if len(unavailable) != 1 || unavailable[0].Payload.(models.CalleeUnavailableMessage).UserId != carol.Id {
+		t.Errorf("alice was told %+v, want carol unavailable", unavailable)
+	}
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// group names are capped at 100 characters and groups at 50 members.
+const (
+	maxContactGroupName    = 100
+	maxContactGroupMembers = 50
+)
+
+type contactGroupRequest struct {
+	Name      *string `json:"name"`
+	MemberIds *[]uint `json:"memberIds"`
+}
+
+// GetContactGroups lists the authenticated user's contact groups by name.
+func GetContactGroups(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	var groups []models.ContactGroup
+	if err := database.Db.Where("owner_id = ?", authUser.Id).Order("name").Find(&groups).Error; err != nil {
+		log.Printf("contact groups query error for user %d: %v", authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load contact groups"})
+		return
+	}
+	for i := range groups {
+		groups[i].MemberIds = loadGroupMemberIds(groups[i].Id)
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+// CreateContactGroup creates a group of the authenticated user's contacts.
+func CreateContactGroup(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	var req contactGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	group := models.ContactGroup{OwnerId: authUser.Id, MemberIds: []uint{}}
+	if !applyContactGroupRequest(c, &group, req) {
+		return
+	}
+
+	err := database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		return saveGroupMembers(tx, group.Id, group.MemberIds)
+	})
+	if err != nil {
+		log.Printf("create contact group error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create contact group"})
+		return
+	}
+	c.JSON(http.StatusCreated, group)
+}
+
+// GetContactGroup returns one of the authenticated user's groups.
+func GetContactGroup(c *gin.Context) {
+	group, ok := ownedContactGroup(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// UpdateContactGroup renames a group and/or replaces its members.
+func UpdateContactGroup(c *gin.Context) {
+	group, ok := ownedContactGroup(c)
+	if !ok {
+		return
+	}
+	var req contactGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !applyContactGroupRequest(c, &group, req) {
+		return
+	}
+
+	err := database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&group).Error; err != nil {
+			return err
+		}
+		if req.MemberIds == nil {
+			return nil
+		}
+		if err := tx.Where("group_id = ?", group.Id).Delete(&models.ContactGroupMember{}).Error; err != nil {
+			return err
+		}
+		return saveGroupMembers(tx, group.Id, group.MemberIds)
+	})
+	if err != nil {
+		log.Printf("update contact group error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update contact group"})
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// DeleteContactGroup deletes a group; the contacts themselves are kept.
+func DeleteContactGroup(c *gin.Context) {
+	group, ok := ownedContactGroup(c)
+	if !ok {
+		return
+	}
+	err := database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", group.Id).Delete(&models.ContactGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&group).Error
+	})
+	if err != nil {
+		log.Printf("delete contact group error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete contact group"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "contact group deleted"})
+}
+
+// SetFavorite marks or unmarks a contact as a favorite. Favorites ring through do-not-disturb for
+// users who allow it.
+func SetFavorite(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var req struct {
+		Favorite *bool `json:"favorite" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var contact models.UserContact
+	if err := database.Db.Where("user_id = ? AND contact_id = ?", authUser.Id, userId).First(&contact).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "contact not found"})
+		return
+	}
+	contact.Favorite = *req.Favorite
+	if err := database.Db.Model(&contact).Update("favorite", contact.Favorite).Error; err != nil {
+		log.Printf("failed to update favorite %d of user %d: %v", userId, authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update contact"})
+		return
+	}
+	c.JSON(http.StatusOK, contact)
+}
+
+// groupCallees returns the members of ownerID's group groupID.
+func groupCallees(ownerID, groupID uint) ([]uint, error) {
+	var group models.ContactGroup
+	if err := database.Db.Where("id = ? AND owner_id = ?", groupID, ownerID).First(&group).Error; err != nil {
+		return nil, err
+	}
+	return loadGroupMemberIds(group.Id), nil
+}
+
+// ownedContactGroup loads the group named by :groupId if the authenticated user owns it.
+func ownedContactGroup(c *gin.Context) (models.ContactGroup, bool) {
+	var group models.ContactGroup
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return group, false
+	}
+	authUser := ai.(models.User)
+
+	err := database.Db.Where("id = ? AND owner_id = ?", c.Param("groupId"), authUser.Id).First(&group).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "contact group not found"})
+		return group, false
+	}
+	if err != nil {
+		log.Printf("get contact group error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch contact group"})
+		return group, false
+	}
+	group.MemberIds = loadGroupMemberIds(group.Id)
+	return group, true
+}
+
+// applyContactGroupRequest validates req and copies it onto g. Members must be contacts of the owner.
+func applyContactGroupRequest(c *gin.Context, g *models.ContactGroup, req contactGroupRequest) bool {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len([]rune(name)) > maxContactGroupName {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name must be 1-100 characters"})
+			return false
+		}
+		g.Name = name
+	}
+	if req.MemberIds != nil {
+		ids := make([]uint, 0, len(*req.MemberIds))
+		seen := make(map[uint]bool)
+		for _, id := range *req.MemberIds {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) > maxContactGroupMembers {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a group can have at most 50 members"})
+			return false
+		}
+		var n int64
+		database.Db.Model(&models.UserContact{}).Where("user_id = ? AND contact_id IN ?", g.OwnerId, ids).Count(&n)
+		if int(n) != len(ids) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "group members must be your contacts"})
+			return false
+		}
+		g.MemberIds = ids
+	}
+	return true
+}
+
+func saveGroupMembers(tx *gorm.DB, groupID uint, ids []uint) error {
+	for _, id := range ids {
+		if err := tx.Create(&models.ContactGroupMember{GroupId: groupID, UserId: id}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadGroupMemberIds(groupID uint) []uint {
+	ids := []uint{}
+	database.Db.Model(&models.ContactGroupMember{}).Where("group_id = ?", groupID).Order("id").Pluck("user_id", &ids)
+	return ids
+}
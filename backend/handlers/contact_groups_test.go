@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestCallContactGroup(t *testing.T) {
+	testDB(t)
+	h := testHub(t)
+	users := testUsers(t, "alice", "bob", "carol", "dave")
+	alice, bob, carol, dave := users[0], users[1], users[2], users[3]
+	database.Db.Create(&models.UserContact{UserId: alice.Id, ContactId: bob.Id})
+	database.Db.Create(&models.UserContact{UserId: alice.Id, ContactId: carol.Id})
+	cb, cc, cd := connect(h, bob.Id), connect(h, carol.Id), connect(h, dave.Id)
+
+	body := map[string]any{"name": "team", "memberIds": []uint{bob.Id, dave.Id}}
+	if code := serve(t, alice, http.MethodPost, "/contact-groups", "/contact-groups", body, CreateContactGroup, nil); code != http.StatusBadRequest {
+		t.Fatalf("group with a non-contact: %d, want 400", code)
+	}
+	var group models.ContactGroup
+	body = map[string]any{"name": "team", "memberIds": []uint{bob.Id, carol.Id, bob.Id}}
+	if code := serve(t, alice, http.MethodPost, "/contact-groups", "/contact-groups", body, CreateContactGroup, &group); code != http.StatusCreated {
+		t.Fatalf("create group: %d, want 201", code)
+	}
+	if len(group.MemberIds) != 2 {
+		t.Fatalf("members = %v, want bob and carol once each", group.MemberIds)
+	}
+
+	if code := serve(t, dave, http.MethodPost, "/calls", "/calls", map[string]any{"groupId": group.Id}, CreateCall, nil); code != http.StatusNotFound {
+		t.Fatalf("calling someone else's group: %d, want 404", code)
+	}
+	var call models.Call
+	body = map[string]any{"groupId": group.Id, "calleeIds": []uint{carol.Id, dave.Id}}
+	if code := serve(t, alice, http.MethodPost, "/calls", "/calls", body, CreateCall, &call); code != http.StatusCreated {
+		t.Fatalf("call group: %d, want 201", code)
+	}
+	if len(call.CalleeIds) != 3 {
+		t.Errorf("callees = %v, want carol, dave and bob once each", call.CalleeIds)
+	}
+	for _, c := range []struct {
+		name string
+		n    int
+	}{
+		{"bob", len(messagesOfType(received(cb), "incoming_call"))},
+		{"carol", len(messagesOfType(received(cc), "incoming_call"))},
+		{"dave", len(messagesOfType(received(cd), "incoming_call"))},
+	} {
+		if c.n != 1 {
+			t.Errorf("%s got %d incoming_call, want 1", c.name, c.n)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// contact request messages are capped at 500 characters.
+const maxContactRequestMessage = 500
+
+// SendContactRequest asks another user to become a contact. If they already asked the caller, their
+// request is accepted instead.
+func SendContactRequest(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	var req struct {
+		ContactId uint   `json:"contactId" binding:"required"`
+		Message   string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ContactId == authUser.Id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot add yourself as a contact"})
+		return
+	}
+	if len([]rune(req.Message)) > maxContactRequestMessage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "message must be at most 500 characters"})
+		return
+	}
+	var target models.User
+	if err := database.Db.First(&target, req.ContactId).Error; err != nil || target.Guest {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if ws.BlockedBetween(authUser.Id, target.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot send a contact request to this user"})
+		return
+	}
+	if isContact(authUser.Id, target.Id) {
+		c.JSON(http.StatusConflict, gin.H{"error": "already a contact"})
+		return
+	}
+
+	db := database.Db
+	var pending models.ContactRequest
+	if err := db.Where("from_id = ? AND to_id = ? AND status = ?", target.Id, authUser.Id, models.ContactRequestPending).
+		First(&pending).Error; err == nil {
+		acceptContactRequest(c, pending)
+		return
+	}
+	if err := db.Where("from_id = ? AND to_id = ? AND status = ?", authUser.Id, target.Id, models.ContactRequestPending).
+		First(&pending).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "contact request already pending", "request": pending})
+		return
+	}
+
+	request := models.ContactRequest{
+		FromId:    authUser.Id,
+		ToId:      target.Id,
+		Message:   req.Message,
+		Status:    models.ContactRequestPending,
+		CreatedAt: time.Now(),
+	}
+	if err := db.Create(&request).Error; err != nil {
+		// a request sent at the same time won the unique index on pending requests
+		if db.Where("from_id = ? AND to_id = ? AND status = ?", authUser.Id, target.Id, models.ContactRequestPending).
+			First(&pending).Error == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "contact request already pending", "request": pending})
+			return
+		}
+		log.Printf("failed to create contact request from %d to %d: %v", authUser.Id, target.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send contact request"})
+		return
+	}
+	notifyContact(target.Id, models.MessageTypeContactRequest, request)
+	c.JSON(http.StatusCreated, request)
+}
+
+// GetContactRequests lists the caller's pending contact requests (?direction=incoming|outgoing,
+// incoming by default).
+func GetContactRequests(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	column := "to_id"
+	switch c.DefaultQuery("direction", "incoming") {
+	case "incoming":
+	case "outgoing":
+		column = "from_id"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "direction must be incoming or outgoing"})
+		return
+	}
+	var requests []models.ContactRequest
+	if err := database.Db.Where(column+" = ? AND status = ?", authUser.Id, models.ContactRequestPending).
+		Order("created_at DESC").Find(&requests).Error; err != nil {
+		log.Printf("contact requests query error for user %d: %v", authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load contact requests"})
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// AcceptContactRequest makes the sender and the caller mutual contacts.
+func AcceptContactRequest(c *gin.Context) {
+	request, ok := pendingContactRequest(c, false)
+	if !ok {
+		return
+	}
+	acceptContactRequest(c, request)
+}
+
+// DeclineContactRequest turns down a request sent to the caller.
+func DeclineContactRequest(c *gin.Context) {
+	request, ok := pendingContactRequest(c, false)
+	if !ok {
+		return
+	}
+	if !respondContactRequest(c, &request, models.ContactRequestDeclined) {
+		return
+	}
+	notifyContact(request.FromId, models.MessageTypeContactRequestDeclined, request)
+	c.JSON(http.StatusOK, request)
+}
+
+// CancelContactRequest withdraws a request the caller sent.
+func CancelContactRequest(c *gin.Context) {
+	request, ok := pendingContactRequest(c, true)
+	if !ok {
+		return
+	}
+	if !respondContactRequest(c, &request, models.ContactRequestCancelled) {
+		return
+	}
+	notifyContact(request.ToId, models.MessageTypeContactRequestCancelled, request)
+	c.JSON(http.StatusOK, request)
+}
+
+// RemoveContact ends the contact relationship on both sides.
+func RemoveContact(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	other := uint(userId)
+	if !isContact(authUser.Id, other) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "contact not found"})
+		return
+	}
+	if err := removeContacts(database.Db, authUser.Id, other); err != nil {
+		log.Printf("failed to remove contact %d of user %d: %v", other, authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove contact"})
+		return
+	}
+	notifyContact(other, models.MessageTypeContactRemoved, models.ContactRemovedMessage{UserId: authUser.Id})
+	if wsHub != nil {
+		wsHub.SyncPresence(authUser.Id, other)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "contact removed"})
+}
+
+// GetBlockedUsers lists the users the caller has blocked.
+func GetBlockedUsers(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	var blocks []models.UserBlock
+	if err := database.Db.Where("user_id = ?", authUser.Id).Order("created_at DESC").Find(&blocks).Error; err != nil {
+		log.Printf("blocked users query error for user %d: %v", authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load blocked users"})
+		return
+	}
+	c.JSON(http.StatusOK, blocks)
+}
+
+// BlockUser blocks another user: they are removed from the caller's contacts, pending requests
+// between them are cancelled, and they can no longer call, message or see the caller. The blocked
+// user isn't told.
+func BlockUser(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	var req struct {
+		UserId uint `json:"userId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.UserId == authUser.Id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot block yourself"})
+		return
+	}
+	var target models.User
+	if err := database.Db.First(&target, req.UserId).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	block := models.UserBlock{UserId: authUser.Id, BlockedId: target.Id, CreatedAt: time.Now()}
+	err := database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND blocked_id = ?", authUser.Id, target.Id).FirstOrCreate(&block).Error; err != nil {
+			return err
+		}
+		if err := removeContacts(tx, authUser.Id, target.Id); err != nil {
+			return err
+		}
+		return tx.Model(&models.ContactRequest{}).
+			Where("((from_id = ? AND to_id = ?) OR (from_id = ? AND to_id = ?)) AND status = ?",
+				authUser.Id, target.Id, target.Id, authUser.Id, models.ContactRequestPending).
+			Updates(map[string]interface{}{"status": models.ContactRequestCancelled, "responded_at": time.Now()}).Error
+	})
+	if err != nil {
+		log.Printf("failed to block user %d for user %d: %v", target.Id, authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to block user"})
+		return
+	}
+	if wsHub != nil {
+		wsHub.SyncPresence(authUser.Id, target.Id)
+	}
+	c.JSON(http.StatusOK, block)
+}
+
+// UnblockUser lifts a block. Contacts removed by the block are not restored.
+func UnblockUser(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	result := database.Db.Where("user_id = ? AND blocked_id = ?", authUser.Id, userId).Delete(&models.UserBlock{})
+	if result.Error != nil {
+		log.Printf("failed to unblock user %d for user %d: %v", userId, authUser.Id, result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unblock user"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user is not blocked"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "user unblocked"})
+}
+
+// pendingContactRequest loads the pending request named by :requestId. The caller must be its
+// sender when sent is true, its recipient otherwise.
+func pendingContactRequest(c *gin.Context, sent bool) (models.ContactRequest, bool) {
+	var request models.ContactRequest
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return request, false
+	}
+	authUser := ai.(models.User)
+
+	requestId, err := strconv.ParseUint(c.Param("requestId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return request, false
+	}
+	if err := database.Db.First(&request, requestId).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "contact request not found"})
+		return request, false
+	}
+	owner := request.ToId
+	if sent {
+		owner = request.FromId
+	}
+	if owner != authUser.Id {
+		c.JSON(http.StatusNotFound, gin.H{"error": "contact request not found"})
+		return request, false
+	}
+	if request.Status != models.ContactRequestPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "contact request is already " + string(request.Status)})
+		return request, false
+	}
+	return request, true
+}
+
+// acceptContactRequest records both sides of the contact and tells the sender.
+func acceptContactRequest(c *gin.Context, request models.ContactRequest) {
+	now := time.Now()
+	request.Status = models.ContactRequestAccepted
+	request.RespondedAt = &now
+	err := database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&request).Error; err != nil {
+			return err
+		}
+		for _, pair := range [][2]uint{{request.FromId, request.ToId}, {request.ToId, request.FromId}} {
+			contact := models.UserContact{UserId: pair[0], ContactId: pair[1]}
+			if err := tx.Where("user_id = ? AND contact_id = ?", pair[0], pair[1]).FirstOrCreate(&contact).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to accept contact request %d: %v", request.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to accept contact request"})
+		return
+	}
+	notifyContact(request.FromId, models.MessageTypeContactRequestAccepted, request)
+	if wsHub != nil {
+		wsHub.SyncPresence(request.FromId, request.ToId)
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// respondContactRequest closes a pending request with status.
+func respondContactRequest(c *gin.Context, request *models.ContactRequest, status models.ContactRequestStatus) bool {
+	now := time.Now()
+	request.Status = status
+	request.RespondedAt = &now
+	if err := database.Db.Save(request).Error; err != nil {
+		log.Printf("failed to update contact request %d: %v", request.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update contact request"})
+		return false
+	}
+	return true
+}
+
+// isContact reports whether contactID is in userID's contacts.
+func isContact(userID, contactID uint) bool {
+	var n int64
+	database.Db.Model(&models.UserContact{}).Where("user_id = ? AND contact_id = ?", userID, contactID).Count(&n)
+	return n > 0
+}
+
+// removeContacts deletes the contact rows between a and b in both directions, and takes each out
+// of the other's contact groups.
+func removeContacts(db *gorm.DB, a, b uint) error {
+	for _, pair := range [][2]uint{{a, b}, {b, a}} {
+		owned := db.Model(&models.ContactGroup{}).Select("id").Where("owner_id = ?", pair[0])
+		if err := db.Where("user_id = ? AND group_id IN (?)", pair[1], owned).Delete(&models.ContactGroupMember{}).Error; err != nil {
+			return err
+		}
+	}
+	return db.Where("(user_id = ? AND contact_id = ?) OR (user_id = ? AND contact_id = ?)", a, b, b, a).
+		Delete(&models.UserContact{}).Error
+}
+
+// notifyContact pushes a contact event to userID if they are connected.
+func notifyContact(userID uint, typ models.WSMessageType, payload interface{}) {
+	if wsHub == nil {
+		return
+	}
+	wsHub.SendToUser(userID, models.WebSocketMessage{Type: typ, Payload: payload, Time: time.Now()})
+}
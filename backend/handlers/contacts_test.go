@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestSendContactRequestOncePending(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "alice", "bob")
+	alice, bob := users[0], users[1]
+	body := map[string]any{"contactId": bob.Id}
+
+	var wg sync.WaitGroup
+	codes := make([]int, 5)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = serve(t, alice, http.MethodPost, "/contacts/requests", "/contacts/requests", body, SendContactRequest, nil)
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+		default:
+			t.Errorf("status %d, want 201 or 409", code)
+		}
+	}
+	var pending int64
+	database.Db.Model(&models.ContactRequest{}).Where("from_id = ? AND to_id = ? AND status = ?", alice.Id, bob.Id, models.ContactRequestPending).Count(&pending)
+	if created != 1 || pending != 1 {
+		t.Errorf("%d requests created and %d pending, want 1 and 1", created, pending)
+	}
+}
+
+func TestBlockedUserCannotSendContactRequest(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "alice", "bob")
+	database.Db.Create(&models.UserBlock{UserId: users[1].Id, BlockedId: users[0].Id})
+
+	code := serve(t, users[0], http.MethodPost, "/contacts/requests", "/contacts/requests", map[string]any{"contactId": users[1].Id}, SendContactRequest, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("request to a user who blocked the sender: status %d, want 403", code)
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// directory pages default to 20 users and are capped at 50. Searching the whole directory needs a
+// query of at least 2 characters so it can't be walked page by page.
+const (
+	defaultDirectoryPage = 20
+	maxDirectoryPage     = 50
+	minDirectoryQuery    = 2
+)
+
+// name matches rank exact, then prefix, then email prefix, then any other match.
+const directoryRank = `CASE WHEN LOWER(name) = ? THEN 0 WHEN LOWER(name) LIKE ? ESCAPE '\' THEN 1 ` +
+	`WHEN LOWER(email) LIKE ? ESCAPE '\' THEN 2 ELSE 3 END`
+
+// directoryCursor is the position after the last result of a page. Name is the lowercase name as
+// the database computes it, so it compares the same way as the rows it continues from.
+type directoryCursor struct {
+	Rank int    `json:"r"`
+	Name string `json:"n"`
+	Id   uint   `json:"i"`
+}
+
+type directoryRow struct {
+	Id          uint
+	Name        string
+	AvatarUrl   *string
+	Status      models.UserStatus
+	Presence    models.Presence
+	StatusText  string
+	StatusEmoji string
+	MatchRank   int
+	SortName    string
+}
+
+// SearchUsers searches the user directory.
+//
+//	?q=        matches every word against the name, or the whole query against the start of the email
+//	?presence= online, offline, available, away or dnd; only mutual contacts are matched
+//	?relation= contacts, favorites or others (non-contacts)
+//	?cursor=   nextCursor of the previous page
+//	?limit=    page size, at most 50
+func SearchUsers(c *gin.Context) {
+	page, ok := searchDirectory(c, "")
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// searchDirectory runs a directory search for the authenticated user, with defaultRelation
+// applying when ?relation= is not given. It writes the error response itself and returns
+// ok=false on bad input.
+func searchDirectory(c *gin.Context, defaultRelation string) (models.DirectoryPage, bool) {
+	var page models.DirectoryPage
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return page, false
+	}
+	authUser := ai.(models.User)
+
+	limit := defaultDirectoryPage
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return page, false
+		}
+		limit = min(n, maxDirectoryPage)
+	}
+	var after *directoryCursor
+	if s := c.Query("cursor"); s != "" {
+		after = &directoryCursor{}
+		raw, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || json.Unmarshal(raw, after) != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return page, false
+		}
+	}
+
+	db := database.Db
+	me := authUser.Id
+	contacts := db.Model(&models.UserContact{}).Select("contact_id").Where("user_id = ?", me)
+	mutual := db.Model(&models.UserContact{}).Select("contact_id").
+		Where("user_id = ? AND contact_id IN (?)", me, db.Model(&models.UserContact{}).Select("user_id").Where("contact_id = ?", me))
+	q := db.Model(&models.User{}).Where("guest = ? AND id <> ?", false, me).
+		Where("id NOT IN (?)", db.Model(&models.UserBlock{}).Select("blocked_id").Where("user_id = ?", me)).
+		Where("id NOT IN (?)", db.Model(&models.UserBlock{}).Select("user_id").Where("blocked_id = ?", me))
+
+	relation := c.DefaultQuery("relation", defaultRelation)
+	switch relation {
+	case "":
+	case "contacts":
+		q = q.Where("id IN (?)", contacts)
+	case "favorites":
+		q = q.Where("id IN (?)", db.Model(&models.UserContact{}).Select("contact_id").Where("user_id = ? AND favorite = ?", me, true))
+	case "others":
+		q = q.Where("id NOT IN (?)", contacts)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "relation must be contacts, favorites or others"})
+		return page, false
+	}
+	// presence is private to mutual contacts, so filtering on it is too
+	switch presence := c.Query("presence"); presence {
+	case "":
+	case string(models.Online), string(models.Offline):
+		q = q.Where("id IN (?) AND status = ?", mutual, presence)
+	case string(models.Available), string(models.Away), string(models.DoNotDisturb):
+		q = q.Where("id IN (?) AND presence = ?", mutual, presence)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "presence must be online, offline, available, away or dnd"})
+		return page, false
+	}
+
+	term := strings.ToLower(strings.TrimSpace(c.Query("q")))
+	if term == "" && (relation == "" || relation == "others") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required to search the whole directory"})
+		return page, false
+	}
+	if term != "" && len([]rune(term)) < minDirectoryQuery {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q must be at least 2 characters"})
+		return page, false
+	}
+	rank := gorm.Expr("0")
+	if term != "" {
+		escaped := likeEscaper.Replace(term)
+		words := db
+		for _, w := range strings.Fields(escaped) {
+			words = words.Where(`LOWER(name) LIKE ? ESCAPE '\'`, "%"+w+"%")
+		}
+		q = q.Where(db.Where(words).Or(`LOWER(email) LIKE ? ESCAPE '\'`, escaped+"%"))
+		rank = gorm.Expr(directoryRank, term, escaped+"%", escaped+"%")
+	}
+	// SQLite's LOWER only folds ASCII, so the cursor keeps the database's value rather than Go's
+	q = q.Select("id, name, LOWER(name) AS sort_name, avatar_url, status, presence, status_text, status_emoji, ? AS match_rank", rank)
+
+	outer := db.Table("(?) AS d", q)
+	if after != nil {
+		outer = outer.Where("match_rank > ? OR (match_rank = ? AND sort_name > ?) OR (match_rank = ? AND sort_name = ? AND id > ?)",
+			after.Rank, after.Rank, after.Name, after.Rank, after.Name, after.Id)
+	}
+	var rows []directoryRow
+	if err := outer.Order("match_rank, sort_name, id").Limit(limit + 1).Find(&rows).Error; err != nil {
+		log.Printf("directory search error for user %d: %v", me, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search users"})
+		return page, false
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		raw, _ := json.Marshal(directoryCursor{Rank: last.MatchRank, Name: last.SortName, Id: last.Id})
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(raw)
+	}
+
+	var links []models.UserContact
+	database.Db.Where("user_id = ?", me).Find(&links)
+	favorite := make(map[uint]bool, len(links))
+	for _, l := range links {
+		favorite[l.ContactId] = l.Favorite
+	}
+	var mutualIds []uint
+	mutual.Pluck("contact_id", &mutualIds)
+	isMutual := make(map[uint]bool, len(mutualIds))
+	for _, id := range mutualIds {
+		isMutual[id] = true
+	}
+
+	page.Users = make([]models.DirectoryEntry, 0, len(rows))
+	for _, r := range rows {
+		fav, contact := favorite[r.Id]
+		entry := models.DirectoryEntry{Id: r.Id, Name: r.Name, AvatarUrl: r.AvatarUrl, Contact: contact, Favorite: fav}
+		if isMutual[r.Id] {
+			entry.Status = r.Status
+			entry.Presence = r.Presence
+			entry.StatusText = r.StatusText
+			entry.StatusEmoji = r.StatusEmoji
+		}
+		page.Users = append(page.Users, entry)
+	}
+	return page, true
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
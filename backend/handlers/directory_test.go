@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestSearchUsersPagesThroughEveryMatch(t *testing.T) {
+	testDB(t)
+	// names whose case SQLite's LOWER doesn't fold must still page in order
+	users := testUsers(t, "me", "Ärne B", "ärne a", "Ärne a", "Arne", "arne c", "Björn Arne", "ÅRNE")
+	me := users[0]
+
+	seen := make(map[uint]int)
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(users) {
+			t.Fatal("paging did not end")
+		}
+		query := url.Values{"q": {"rne"}, "limit": {"2"}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		var page models.DirectoryPage
+		if code := serve(t, me, http.MethodGet, "/users/search", "/users/search?"+query.Encode(), nil, SearchUsers, &page); code != http.StatusOK {
+			t.Fatalf("page %d: status %d", pages, code)
+		}
+		for _, u := range page.Users {
+			seen[u.Id]++
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	for _, u := range users[1:] {
+		if seen[u.Id] != 1 {
+			t.Errorf("%q returned %d times, want once", u.Name, seen[u.Id])
+		}
+	}
+}
+
+func TestGetUsersKeepsUserList(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "alice", "bob", "carol")
+	alice, bob := users[0], users[1]
+	database.Db.Create(&models.UserContact{UserId: alice.Id, ContactId: bob.Id})
+
+	var list []models.User
+	if code := serve(t, alice, http.MethodGet, "/users", "/users", nil, GetUsers, &list); code != http.StatusOK {
+		t.Fatalf("without q: status %d, want 200", code)
+	}
+	if len(list) != 1 || list[0].Id != bob.Id || list[0].Email != bob.Email {
+		t.Errorf("without q got %+v, want only contact bob", list)
+	}
+
+	list = nil
+	if code := serve(t, alice, http.MethodGet, "/users", "/users?q=car", nil, GetUsers, &list); code != http.StatusOK {
+		t.Fatalf("with q: status %d, want 200", code)
+	}
+	if len(list) != 1 || list[0].Name != "carol" {
+		t.Errorf("searching car got %+v, want carol", list)
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// GetForwardingRules lists the authenticated user's call forwarding rules.
+func GetForwardingRules(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	var rules []models.CallForwardRule
+	if err := database.Db.Where("user_id = ?", authUser.Id).Order("id").Find(&rules).Error; err != nil {
+		log.Printf("forwarding rules query error for user %d: %v", authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load forwarding rules"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// SetForwardingRule creates or replaces the rule for :condition (always, busy or unanswered).
+func SetForwardingRule(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	condition := models.ForwardCondition(c.Param("condition"))
+	if !condition.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "condition must be always, busy or unanswered"})
+		return
+	}
+	var req struct {
+		TargetId     uint `json:"targetId" binding:"required"`
+		AfterSeconds int  `json:"afterSeconds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.TargetId == authUser.Id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot forward calls to yourself"})
+		return
+	}
+	if req.AfterSeconds < 0 || req.AfterSeconds > int(ws.MaxForwardAfter.Seconds()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "afterSeconds must be between 0 and 120"})
+		return
+	}
+	var target models.User
+	if err := database.Db.First(&target, req.TargetId).Error; err != nil || target.Guest {
+		c.JSON(http.StatusNotFound, gin.H{"error": "target user not found"})
+		return
+	}
+
+	rule := models.CallForwardRule{UserId: authUser.Id, Condition: condition}
+	db := database.Db
+	db.Where("user_id = ? AND condition = ?", authUser.Id, condition).First(&rule)
+	rule.TargetId = req.TargetId
+	rule.AfterSeconds = 0
+	if condition == models.ForwardUnanswered {
+		rule.AfterSeconds = req.AfterSeconds
+	}
+	if err := db.Save(&rule).Error; err != nil {
+		log.Printf("failed to save forwarding rule for user %d: %v", authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save forwarding rule"})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteForwardingRule turns off forwarding for :condition.
+func DeleteForwardingRule(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	condition := models.ForwardCondition(c.Param("condition"))
+	if !condition.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "condition must be always, busy or unanswered"})
+		return
+	}
+	res := database.Db.Where("user_id = ? AND condition = ?", authUser.Id, condition).Delete(&models.CallForwardRule{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete forwarding rule"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no forwarding rule for this condition"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// guest sessions end a few hours after the invite is redeemed, long enough for one meeting
+const guestTokenTTL = 4 * time.Hour
+
+type guestJoinRequest struct {
+	Name string `json:"name" binding:"required,max=50"`
+}
+
+// CreateRoomGuestInvite returns a signed link that lets someone without an account join a room
+// (?ttl=<seconds>, default 15 minutes); owner only.
+func CreateRoomGuestInvite(c *gin.Context) {
+	room, ok := ownedRoom(c)
+	if !ok {
+		return
+	}
+	writeGuestInvite(c, fmt.Sprintf("/guests/rooms/%s", room.Slug))
+}
+
+// CreateCallGuestInvite returns a signed link that lets someone without an account join a live
+// call; host or moderators only.
+func CreateCallGuestInvite(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	if !session.CanModerate(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the host or a moderator can invite guests"})
+		return
+	}
+	writeGuestInvite(c, fmt.Sprintf("/guests/calls/%d", session.ID))
+}
+
+// RedeemRoomGuestInvite turns a signed room invite into a guest token. The guest then joins with
+// POST /rooms/:slug/join like anyone else, without the room's passcode.
+func RedeemRoomGuestInvite(c *gin.Context) {
+	if !verifyGuestInvite(c) {
+		return
+	}
+	room, ok := findRoom(c)
+	if !ok {
+		return
+	}
+	user, token, ok := createGuest(c, room.Id, 0)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"token": token, "user": user, "room": roomResponse(room)})
+}
+
+// RedeemCallGuestInvite turns a signed call invite into a guest token and admits the guest to the
+// call, or puts them in its lobby; they take part through POST /calls/:id/join once their WS is
+// connected and they were admitted.
+func RedeemCallGuestInvite(c *gin.Context) {
+	if !verifyGuestInvite(c) {
+		return
+	}
+	callId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid call id"})
+		return
+	}
+	if wsHub == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "call service unavailable"})
+		return
+	}
+	session, ok := wsHub.GetCallSession(uint(callId))
+	if !ok || session.Closed() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "call has ended"})
+		return
+	}
+	user, token, ok := createGuest(c, 0, session.ID)
+	if !ok {
+		return
+	}
+	admitted, err := session.RequestEntry(user)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"token": token, "user": user, "callId": session.ID, "waiting": !admitted})
+}
+
+// guestMayAccess reports whether a guest scoped to scope may use the matched route. Guests get
+// their own profile, the WS connection and the room or call they were invited to, nothing else.
+func guestMayAccess(c *gin.Context, scope models.GuestScope) bool {
+	switch path := c.FullPath(); {
+	case path == "/auth/me", path == "/auth/logout", path == "/ws":
+		return true
+	case strings.HasPrefix(path, "/calls/:id"):
+		callId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			return false
+		}
+		if scope.CallId != 0 {
+			return uint(callId) == scope.CallId
+		}
+		var n int64
+		database.Db.Model(&models.Call{}).Where("id = ? AND room_id = ?", callId, scope.RoomId).Count(&n)
+		return scope.RoomId != 0 && n > 0
+	case path == "/rooms/:slug", path == "/rooms/:slug/join":
+		var room models.Room
+		if err := database.Db.Select("id").Where("slug = ?", c.Param("slug")).First(&room).Error; err != nil {
+			return false
+		}
+		return scope.RoomId != 0 && room.Id == scope.RoomId
+	}
+	return false
+}
+
+func writeGuestInvite(c *gin.Context, path string) {
+	ttl := defaultSignedURLTTL
+	if s := c.Query("ttl"); s != "" {
+		secs, err := strconv.Atoi(s)
+		if err != nil || secs <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ttl"})
+			return
+		}
+		ttl = min(time.Duration(secs)*time.Second, maxSignedURLTTL)
+	}
+	expires := time.Now().Add(ttl)
+	url := fmt.Sprintf("%s?expires=%d&sig=%s", path, expires.Unix(), utils.SignPath(path, expires))
+	c.JSON(http.StatusOK, gin.H{"url": url, "expiresAt": expires})
+}
+
+func verifyGuestInvite(c *gin.Context) bool {
+	if err := utils.VerifySignedPath(c.Request.URL.Path, c.Query("expires"), c.Query("sig")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// createGuest stores a guest user for the name in the request body and issues their token.
+// Names and emails are unique in the users table, so both get a random suffix.
+func createGuest(c *gin.Context, roomID, callID uint) (models.User, string, bool) {
+	var req guestJoinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.User{}, "", false
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+		return models.User{}, "", false
+	}
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("guest id error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create guest"})
+		return models.User{}, "", false
+	}
+	suffix := hex.EncodeToString(b)
+	user := models.User{
+		Name:     fmt.Sprintf("%s (guest %s)", name, suffix[:4]),
+		Email:    fmt.Sprintf("guest-%s@guest.invalid", suffix),
+		Status:   models.Offline,
+		LastSeen: time.Now(),
+		Guest:    true,
+	}
+	if err := database.Db.Create(&user).Error; err != nil {
+		log.Printf("create guest error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create guest"})
+		return models.User{}, "", false
+	}
+	token, err := utils.GenerateGuestToken(user.Id, roomID, callID, guestTokenTTL)
+	if err != nil {
+		log.Printf("guest token error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create guest"})
+		return models.User{}, "", false
+	}
+	return user, token, true
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// testDB opens a fresh SQLite database in a temporary working directory.
+func testDB(t *testing.T) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Chdir(t.TempDir())
+	database.InitSqliteDB()
+}
+
+// testUsers creates users named after names and returns them in order.
+func testUsers(t *testing.T, names ...string) []models.User {
+	t.Helper()
+	users := make([]models.User, len(names))
+	for i, n := range names {
+		users[i] = models.User{Name: n, Email: n + "@example.com"}
+		if err := database.Db.Create(&users[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return users
+}
+
+// serve runs handler for method and path as user, with route as the registered pattern, and
+// decodes a JSON response into out when it is not nil.
+func serve(t *testing.T, user models.User, method, route, path string, body any, handler gin.HandlerFunc, out any) int {
+	t.Helper()
+	r := gin.New()
+	r.Handle(method, route, func(c *gin.Context) { c.Set("authUser", user) }, handler)
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if out != nil && w.Code < 300 {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+// testHub installs a fresh hub for the handlers and removes it when the test ends.
+func testHub(t *testing.T) *ws.Hub {
+	t.Helper()
+	h := ws.NewHub()
+	SetupWSHub(h)
+	t.Cleanup(func() { SetupWSHub(nil) })
+	return h
+}
+
+// connect registers a connected, online WebSocket client for userID with h.
+func connect(h *ws.Hub, userID uint) *ws.Client {
+	c := &ws.Client{Hub: h, UserID: userID, Send: make(chan models.WebSocketMessage, 64)}
+	h.Mutex.Lock()
+	h.UserClients[userID] = c
+	h.UserStatuses[userID] = &models.UserStatusMessage{UserID: userID, Status: models.Online, Presence: models.Available}
+	h.Mutex.Unlock()
+	return c
+}
+
+// received returns the messages queued for c so far.
+func received(c *ws.Client) []models.WebSocketMessage {
+	var out []models.WebSocketMessage
+	for {
+		select {
+		case m := <-c.Send:
+			out = append(out, m)
+		default:
+			return out
+		}
+	}
+}
+
+// messagesOfType returns the messages of type typ among msgs.
+func messagesOfType(msgs []models.WebSocketMessage, typ models.WSMessageType) []models.WebSocketMessage {
+	var out []models.WebSocketMessage
+	for _, m := range msgs {
+		if m.Type == typ {
+			out = append(out, m)
+		}
+	}
+	return out
+}
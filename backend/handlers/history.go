@@ -11,12 +11,23 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// GetHistory returns a call with its recordings to someone who took part in it.
 func GetHistory(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
+	if !canAccessCall(authUser.Id, uint(id)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a participant of this call"})
+		return
+	}
 	var history models.Call
 	db := database.Db
 
@@ -24,6 +35,8 @@ func GetHistory(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "History not found"})
 		return
 	}
+	db.Where("call_id = ?", history.Id).Find(&history.Recordings)
+	loadCallPolls(&history)
 
 	c.JSON(http.StatusOK, history)
 }
@@ -42,19 +55,18 @@ func AddHistory(c *gin.Context) {
 	c.JSON(http.StatusCreated, history)
 }
 
-// GetUserHistory: if :id == "me" return current user's history, otherwise return requested user's history (admin)
+// GetUserHistory returns the calls of :id ("me" for the current user). Someone else's history
+// only includes the calls the current user took part in too.
 func GetUserHistory(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
 	idParam := c.Param("id")
-	var targetUserId uint64
-	if idParam == "me" {
-		ai, ok := c.Get("authUser")
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-			return
-		}
-		authUser := ai.(models.User)
-		targetUserId = uint64(authUser.Id)
-	} else {
+	targetUserId := uint64(authUser.Id)
+	if idParam != "me" {
 		uid, err := strconv.ParseUint(idParam, 10, 32)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
@@ -67,7 +79,7 @@ func GetUserHistory(c *gin.Context) {
 	var history []models.Call
 
 	rows, err := db.Raw(
-		"SELECT c.id, c.caller_id, c.start_time, h.end_time FROM calls c JOIN history h on h.call_id = c.id WHERE h.user_id = ?",
+		"SELECT c.id, c.caller_id, c.start_time, h.end_time FROM calls c JOIN histories h on h.call_id = c.id WHERE h.user_id = ?",
 		targetUserId,
 	).Rows()
 	if err != nil {
@@ -86,6 +98,9 @@ func GetUserHistory(c *gin.Context) {
 			log.Printf("row scan error: %v", err)
 			continue
 		}
+		if targetUserId != uint64(authUser.Id) && !canAccessCall(authUser.Id, id) {
+			continue
+		}
 		call := models.Call{
 			Id:        id,
 			CallerId:  callerId,
@@ -101,6 +116,10 @@ func GetUserHistory(c *gin.Context) {
 				}
 			}
 		}
+		if err := db.Where("call_id = ?", id).Find(&call.Recordings).Error; err != nil {
+			log.Printf("recordings query error for call %d: %v", id, err)
+		}
+		loadCallPolls(&call)
 		history = append(history, call)
 	}
 
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestGetHistoryOnlyForParticipants(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "alice", "bob", "eve")
+	alice, bob, eve := users[0], users[1], users[2]
+
+	call := models.Call{CallerId: alice.Id, StartTime: time.Now(), Status: models.Ended}
+	database.Db.Create(&call)
+	database.Db.Create(&models.History{CallId: call.Id, UserId: bob.Id})
+	database.Db.Create(&models.Recording{CallId: call.Id, UserId: alice.Id, Kind: "audio", StartTime: time.Now()})
+
+	path := fmt.Sprintf("/history/%d", call.Id)
+	for _, u := range []models.User{alice, bob} {
+		var got models.Call
+		if code := serve(t, u, http.MethodGet, "/history/:id", path, nil, GetHistory, &got); code != http.StatusOK {
+			t.Fatalf("%s: status %d, want 200", u.Name, code)
+		}
+		if len(got.Recordings) != 1 {
+			t.Errorf("%s: got %d recordings, want 1", u.Name, len(got.Recordings))
+		}
+	}
+	if code := serve(t, eve, http.MethodGet, "/history/:id", path, nil, GetHistory, nil); code != http.StatusForbidden {
+		t.Errorf("non-participant: status %d, want 403", code)
+	}
+}
+
+func TestGetUserHistoryOnlySharesCommonCalls(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "alice", "bob", "eve")
+	alice, bob, eve := users[0], users[1], users[2]
+
+	shared := models.Call{CallerId: eve.Id, StartTime: time.Now(), Status: models.Ended}
+	private := models.Call{CallerId: alice.Id, StartTime: time.Now(), Status: models.Ended}
+	database.Db.Create(&shared)
+	database.Db.Create(&private)
+	database.Db.Create(&models.History{CallId: shared.Id, UserId: bob.Id, Role: "callee", EndTime: time.Now()})
+	database.Db.Create(&models.History{CallId: private.Id, UserId: bob.Id, Role: "callee", EndTime: time.Now()})
+	database.Db.Create(&models.Recording{CallId: private.Id, UserId: alice.Id, Kind: "audio", StartTime: time.Now()})
+
+	var own []models.Call
+	if code := serve(t, bob, http.MethodGet, "/users/:id/history", "/users/me/history", nil, GetUserHistory, &own); code != http.StatusOK {
+		t.Fatalf("own history: status %d", code)
+	}
+	if len(own) != 2 {
+		t.Errorf("bob sees %d of his calls, want 2", len(own))
+	}
+
+	var theirs []models.Call
+	path := fmt.Sprintf("/users/%d/history", bob.Id)
+	if code := serve(t, eve, http.MethodGet, "/users/:id/history", path, nil, GetUserHistory, &theirs); code != http.StatusOK {
+		t.Fatalf("bob's history as eve: status %d", code)
+	}
+	if len(theirs) != 1 || theirs[0].Id != shared.Id {
+		t.Errorf("eve sees %+v of bob's history, want only the shared call", theirs)
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// interactionError maps errors from hands, reactions and polls onto HTTP responses.
+func interactionError(c *gin.Context, callID uint, err error) {
+	switch {
+	case errors.Is(err, ws.ErrNotPermitted):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrNotInCall), errors.Is(err, ws.ErrUnknownPoll):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrPollClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrReactionRateLimited):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrInvalidReaction), errors.Is(err, ws.ErrInvalidPoll), errors.Is(err, ws.ErrInvalidVote):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("interaction error for call %d: %v", callID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update call"})
+	}
+}
+
+// GetHandQueue lists raised hands in the order they went up; host and moderators only.
+func GetHandQueue(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	if !session.CanModerate(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the host or a moderator can see the hand queue"})
+		return
+	}
+	c.JSON(http.StatusOK, models.HandQueueMessage{CallId: session.ID, Queue: session.HandQueue()})
+}
+
+// SetHand raises or lowers the caller's hand, or lowers someone else's when userId is given by a
+// host or moderator.
+func SetHand(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		UserId uint `json:"userId"`
+		Raised bool `json:"raised"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.UserId == 0 {
+		req.UserId = authUser.Id
+	}
+	if err := session.SetHand(authUser.Id, req.UserId, req.Raised); err != nil {
+		interactionError(c, session.ID, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.HandMessage{CallId: session.ID, UserId: req.UserId, Raised: req.Raised})
+}
+
+// SendReaction relays an emoji reaction to everyone in the call.
+func SendReaction(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Emoji string `json:"emoji" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := session.React(authUser.Id, req.Emoji); err != nil {
+		interactionError(c, session.ID, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetPolls lists the polls of a live call with their current tallies.
+func GetPolls(c *gin.Context) {
+	session, _, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, session.Polls())
+}
+
+// CreatePoll asks the call a single or multiple choice question; host and moderators only.
+func CreatePoll(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	var req models.PollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	poll, err := session.CreatePoll(authUser.Id, req)
+	if err != nil {
+		interactionError(c, session.ID, err)
+		return
+	}
+	c.JSON(http.StatusCreated, poll)
+}
+
+// VotePoll records the caller's choice, replacing an earlier vote.
+func VotePoll(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	pollId, err := strconv.ParseUint(c.Param("pollId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid poll id"})
+		return
+	}
+	var req struct {
+		OptionIds []uint `json:"optionIds" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	poll, err := session.Vote(authUser.Id, uint(pollId), req.OptionIds)
+	if err != nil {
+		interactionError(c, session.ID, err)
+		return
+	}
+	c.JSON(http.StatusOK, poll)
+}
+
+// ClosePoll ends voting and stores the results with the call; host and moderators only.
+func ClosePoll(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	pollId, err := strconv.ParseUint(c.Param("pollId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid poll id"})
+		return
+	}
+	poll, err := session.ClosePoll(authUser.Id, uint(pollId))
+	if err != nil {
+		interactionError(c, session.ID, err)
+		return
+	}
+	c.JSON(http.StatusOK, poll)
+}
+
+// loadCallPolls fills in the stored polls of a call, options in the order they were asked.
+func loadCallPolls(call *models.Call) {
+	err := database.Db.Preload("Options", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Where("call_id = ?", call.Id).Order("id").Find(&call.Polls).Error
+	if err != nil {
+		log.Printf("polls query error for call %d: %v", call.Id, err)
+	}
+}
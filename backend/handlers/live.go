@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Neb-iyu/facetime-app/backend/hls"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// StartLiveStream starts the HLS output of a call; only the host can start it. The optional body
+// {"publisherId": n} picks whose tracks are streamed, defaulting to the host.
+func StartLiveStream(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	if !session.IsHost(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the host can start a live stream"})
+		return
+	}
+	var body struct {
+		PublisherId uint `json:"publisherId"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	ls, err := session.StartLiveStream(authUser.Id, body.PublisherId)
+	if errors.Is(err, ws.ErrLiveStreamActive) || errors.Is(err, ws.ErrNothingToStream) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("start live stream error for call %d: %v", session.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start live stream"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"callId":      session.ID,
+		"publisherId": ls.PublisherID,
+		"startedAt":   ls.StartedAt,
+		"playlistUrl": ws.LivePlaylistURL(session.ID),
+	})
+}
+
+// StopLiveStream stops the HLS output of a call; only the host can stop it.
+func StopLiveStream(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	if !session.IsHost(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the host can stop a live stream"})
+		return
+	}
+	if err := session.StopLiveStream(authUser.Id); errors.Is(err, ws.ErrLiveStreamInactive) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"callId": session.ID})
+}
+
+// GetLiveStreamFile serves the playlist and segments of a call's HLS output. Any signed-in user may
+// watch, since the audience of a broadcast is usually much larger than the invite list.
+func GetLiveStreamFile(c *gin.Context) {
+	session, _, ok := sdpCallSession(c)
+	if !ok {
+		return
+	}
+	ls, ok := session.GetLiveStream()
+	if !ok {
+		c.String(http.StatusNotFound, "call is not being streamed")
+		return
+	}
+	name := c.Param("file")
+	data, ok := ls.Muxer.File(name)
+	if !ok {
+		if name == hls.PlaylistName {
+			// the first segment is still being built
+			c.Header("Retry-After", "2")
+			c.String(http.StatusServiceUnavailable, "stream is starting")
+			return
+		}
+		c.String(http.StatusNotFound, "segment not found")
+		return
+	}
+	if name == hls.PlaylistName {
+		c.Header("Cache-Control", "no-cache")
+	} else {
+		c.Header("Cache-Control", "max-age=60")
+	}
+	c.Data(http.StatusOK, hls.ContentType(name), data)
+}
@@ -0,0 +1,547 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultReminderMinutes = 10
+	maxMeetingMinutes      = 24 * 60
+)
+
+type meetingRequest struct {
+	Title           *string    `json:"title"`
+	Description     *string    `json:"description"`
+	StartTime       *time.Time `json:"startTime"`
+	DurationMinutes *int       `json:"durationMinutes"`
+	InviteeIds      *[]uint    `json:"inviteeIds"`
+	Recurrence      *string    `json:"recurrence"`
+	TimeZone        *string    `json:"timeZone"`
+	ReminderMinutes *int       `json:"reminderMinutes"`
+}
+
+// CreateMeeting schedules a meeting hosted by the authenticated user and notifies the invitees.
+func CreateMeeting(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	var req meetingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Title == nil || req.StartTime == nil || req.DurationMinutes == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title, startTime and durationMinutes are required"})
+		return
+	}
+	meeting := models.Meeting{HostId: authUser.Id, ReminderMinutes: defaultReminderMinutes}
+	if !applyMeetingRequest(c, &meeting, req) {
+		return
+	}
+
+	err := database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&meeting).Error; err != nil {
+			return err
+		}
+		return saveInvitees(tx, meeting.Id, meeting.InviteeIds)
+	})
+	if err != nil {
+		log.Printf("create meeting error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create meeting"})
+		return
+	}
+	notifyMeeting(models.MessageTypeMeetingInvitation, meeting, meeting.StartTime, meeting.InviteeIds)
+	setNextStart(&meeting)
+	c.JSON(http.StatusCreated, meeting)
+}
+
+// GetMeetings lists the meetings the authenticated user hosts or is invited to, soonest first.
+// ?upcoming=true leaves out meetings with no occurrence left.
+func GetMeetings(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	var meetings []models.Meeting
+	invited := database.Db.Model(&models.MeetingInvitee{}).Select("meeting_id").Where("user_id = ?", authUser.Id)
+	if err := database.Db.Where("host_id = ? OR id IN (?)", authUser.Id, invited).Find(&meetings).Error; err != nil {
+		log.Printf("get meetings error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch meetings"})
+		return
+	}
+
+	upcoming := c.Query("upcoming") == "true"
+	out := make([]models.Meeting, 0, len(meetings))
+	for _, m := range meetings {
+		setNextStart(&m)
+		if upcoming && m.NextStart == nil {
+			continue
+		}
+		m.InviteeIds = loadInviteeIds(m.Id)
+		out = append(out, m)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].NextStart == nil || out[j].NextStart == nil {
+			return out[j].NextStart == nil && out[i].NextStart != nil
+		}
+		return out[i].NextStart.Before(*out[j].NextStart)
+	})
+	c.JSON(http.StatusOK, out)
+}
+
+// GetMeeting returns a meeting to its host or an invitee.
+func GetMeeting(c *gin.Context) {
+	meeting, _, ok := accessibleMeeting(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, meeting)
+}
+
+// UpdateMeeting changes a meeting; host only. Newly added invitees get an invitation, removed
+// ones a cancellation.
+func UpdateMeeting(c *gin.Context) {
+	meeting, ok := hostedMeeting(c)
+	if !ok {
+		return
+	}
+	var req meetingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	before := meeting
+	if !applyMeetingRequest(c, &meeting, req) {
+		return
+	}
+	if !meeting.StartTime.Equal(before.StartTime) || meeting.Recurrence != before.Recurrence || meeting.TimeZone != before.TimeZone {
+		meeting.RemindedFor = nil
+	}
+	meeting.Sequence++
+
+	err := database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&meeting).Error; err != nil {
+			return err
+		}
+		if req.InviteeIds == nil {
+			return nil
+		}
+		if err := tx.Where("meeting_id = ?", meeting.Id).Delete(&models.MeetingInvitee{}).Error; err != nil {
+			return err
+		}
+		return saveInvitees(tx, meeting.Id, meeting.InviteeIds)
+	})
+	if err != nil {
+		log.Printf("update meeting error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update meeting"})
+		return
+	}
+	added, removed := diffIds(before.InviteeIds, meeting.InviteeIds)
+	notifyMeeting(models.MessageTypeMeetingInvitation, meeting, meeting.StartTime, added)
+	notifyMeetingCancelled(c, meeting, removed)
+	setNextStart(&meeting)
+	c.JSON(http.StatusOK, meeting)
+}
+
+// DeleteMeeting cancels a meeting; host only. Invitees are notified with an iCalendar cancellation.
+func DeleteMeeting(c *gin.Context) {
+	meeting, ok := hostedMeeting(c)
+	if !ok {
+		return
+	}
+	err := database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("meeting_id = ?", meeting.Id).Delete(&models.MeetingInvitee{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&meeting).Error
+	})
+	if err != nil {
+		log.Printf("delete meeting error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete meeting"})
+		return
+	}
+	meeting.Sequence++
+	notifyMeetingCancelled(c, meeting, meeting.InviteeIds)
+	c.Status(http.StatusNoContent)
+}
+
+// StartMeeting starts the call for a meeting and rings every invitee who is online; host only.
+// Starting a meeting whose call is still running returns that call.
+func StartMeeting(c *gin.Context) {
+	meeting, ok := hostedMeeting(c)
+	if !ok {
+		return
+	}
+	if wsHub == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "call service unavailable"})
+		return
+	}
+	if meeting.CallId != nil {
+		if session, ok := wsHub.GetCallSession(*meeting.CallId); ok && !session.Closed() {
+			session.Mu.RLock()
+			call := session.Call
+			session.Mu.RUnlock()
+			c.JSON(http.StatusOK, call)
+			return
+		}
+	}
+
+	call := models.Call{
+		CallerId:  meeting.HostId,
+		CalleeIds: meeting.InviteeIds,
+		StartTime: time.Now(),
+		Status:    models.Ringing,
+	}
+	if err := database.Db.Create(&call).Error; err != nil {
+		log.Printf("start meeting %d error: %v", meeting.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start meeting"})
+		return
+	}
+	if err := database.Db.Model(&meeting).Update("call_id", call.Id).Error; err != nil {
+		log.Printf("start meeting %d: failed to save call id: %v", meeting.Id, err)
+	}
+	wsHub.Ring(wsHub.CreateCallSession(&call))
+	c.JSON(http.StatusCreated, call)
+}
+
+// GetMeetingICS exports a meeting as an iCalendar file with every attendee.
+func GetMeetingICS(c *gin.Context) {
+	meeting, _, ok := accessibleMeeting(c)
+	if !ok {
+		return
+	}
+	writeMeetingICS(c, "PUBLISH", meeting, meeting.InviteeIds, fmt.Sprintf("meeting-%d.ics", meeting.Id))
+}
+
+// GetMeetingInvite returns the iCalendar invitation for one invitee, listing only the host and that
+// invitee so forwarding it doesn't reveal the rest of the invite list; host only.
+func GetMeetingInvite(c *gin.Context) {
+	meeting, ok := hostedMeeting(c)
+	if !ok {
+		return
+	}
+	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	invited := false
+	for _, id := range meeting.InviteeIds {
+		invited = invited || id == uint(userId)
+	}
+	if !invited {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user is not invited to this meeting"})
+		return
+	}
+	writeMeetingICS(c, "REQUEST", meeting, []uint{uint(userId)}, fmt.Sprintf("meeting-%d-invite-%d.ics", meeting.Id, userId))
+}
+
+// StartMeetingReminders checks every interval for meeting occurrences starting within their
+// reminder window and notifies the host and invitees once per occurrence.
+func StartMeetingReminders(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			sendMeetingReminders(time.Now())
+		}
+	}()
+}
+
+func sendMeetingReminders(now time.Time) {
+	var meetings []models.Meeting
+	err := database.Db.Where("reminder_minutes > 0 AND (recurrence <> '' OR start_time > ?)", now).Find(&meetings).Error
+	if err != nil {
+		log.Printf("meeting reminders error: %v", err)
+		return
+	}
+	for _, m := range meetings {
+		next, ok := nextOccurrence(m, now)
+		if !ok || next.Add(-time.Duration(m.ReminderMinutes)*time.Minute).After(now) {
+			continue
+		}
+		if m.RemindedFor != nil && m.RemindedFor.Equal(next) {
+			continue
+		}
+		if err := database.Db.Model(&m).Update("reminded_for", next).Error; err != nil {
+			log.Printf("meeting reminders: failed to update meeting %d: %v", m.Id, err)
+			continue
+		}
+		notifyMeeting(models.MessageTypeMeetingReminder, m, next, append(loadInviteeIds(m.Id), m.HostId))
+	}
+}
+
+// applyMeetingRequest copies the set fields of req into m, writing a 400 on invalid input.
+func applyMeetingRequest(c *gin.Context, m *models.Meeting, req meetingRequest) bool {
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title cannot be empty"})
+			return false
+		}
+		m.Title = title
+	}
+	if req.Description != nil {
+		m.Description = *req.Description
+	}
+	if req.StartTime != nil {
+		m.StartTime = req.StartTime.UTC()
+	}
+	if req.DurationMinutes != nil {
+		if *req.DurationMinutes <= 0 || *req.DurationMinutes > maxMeetingMinutes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "durationMinutes must be between 1 and 1440"})
+			return false
+		}
+		m.DurationMinutes = *req.DurationMinutes
+	}
+	if req.ReminderMinutes != nil {
+		if *req.ReminderMinutes < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reminderMinutes cannot be negative"})
+			return false
+		}
+		m.ReminderMinutes = *req.ReminderMinutes
+	}
+	if req.Recurrence != nil {
+		rule := strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(*req.Recurrence), "RRULE:"))
+		if rule != "" {
+			if _, err := utils.ParseRRule(rule); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recurrence: " + err.Error()})
+				return false
+			}
+		}
+		m.Recurrence = rule
+	}
+	if req.TimeZone != nil {
+		zone := strings.TrimSpace(*req.TimeZone)
+		if _, err := time.LoadLocation(zone); err != nil || zone == "Local" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "timeZone must be an IANA time zone such as Europe/Berlin"})
+			return false
+		}
+		if zone == "UTC" {
+			zone = ""
+		}
+		m.TimeZone = zone
+	}
+	if req.InviteeIds != nil {
+		ids := make([]uint, 0, len(*req.InviteeIds))
+		seen := map[uint]bool{m.HostId: true}
+		for _, id := range *req.InviteeIds {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		var n int64
+		database.Db.Model(&models.User{}).Where("id IN ? AND guest = ?", ids, false).Count(&n)
+		if int(n) != len(ids) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown invitee"})
+			return false
+		}
+		m.InviteeIds = ids
+	}
+	return true
+}
+
+// accessibleMeeting loads the meeting named by :id for its host or an invitee.
+func accessibleMeeting(c *gin.Context) (models.Meeting, models.User, bool) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return models.Meeting{}, models.User{}, false
+	}
+	authUser := ai.(models.User)
+
+	var meeting models.Meeting
+	err := database.Db.First(&meeting, c.Param("id")).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "meeting not found"})
+		return meeting, authUser, false
+	}
+	if err != nil {
+		log.Printf("get meeting error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch meeting"})
+		return meeting, authUser, false
+	}
+	meeting.InviteeIds = loadInviteeIds(meeting.Id)
+	allowed := meeting.HostId == authUser.Id
+	for _, id := range meeting.InviteeIds {
+		allowed = allowed || id == authUser.Id
+	}
+	if !allowed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "meeting not found"})
+		return meeting, authUser, false
+	}
+	setNextStart(&meeting)
+	return meeting, authUser, true
+}
+
+// hostedMeeting is accessibleMeeting restricted to the host.
+func hostedMeeting(c *gin.Context) (models.Meeting, bool) {
+	meeting, authUser, ok := accessibleMeeting(c)
+	if !ok {
+		return meeting, false
+	}
+	if meeting.HostId != authUser.Id {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the host can change this meeting"})
+		return meeting, false
+	}
+	return meeting, true
+}
+
+func writeMeetingICS(c *gin.Context, method string, m models.Meeting, attendeeIds []uint, filename string) {
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8; method="+method, []byte(meetingICS(c, method, m, attendeeIds)))
+}
+
+// meetingICS renders m as an iCalendar file for method listing the host and attendeeIds.
+// CANCEL marks the event cancelled.
+func meetingICS(c *gin.Context, method string, m models.Meeting, attendeeIds []uint) string {
+	var users []models.User
+	database.Db.Where("id IN ?", append([]uint{m.HostId}, attendeeIds...)).Find(&users)
+	start := m.StartTime.In(meetingLocation(m))
+	ev := utils.ICSEvent{
+		UID:         fmt.Sprintf("meeting-%d@facetime-app", m.Id),
+		Sequence:    m.Sequence,
+		Start:       start,
+		End:         start.Add(time.Duration(m.DurationMinutes) * time.Minute),
+		Summary:     m.Title,
+		Description: m.Description,
+		URL:         meetingURL(c, m.Id),
+		RRule:       m.Recurrence,
+		Cancelled:   method == "CANCEL",
+	}
+	for _, u := range users {
+		p := utils.ICSPerson{Name: u.Name, Email: u.Email}
+		if u.Id == m.HostId {
+			ev.Organizer = p
+		} else {
+			ev.Attendees = append(ev.Attendees, p)
+		}
+	}
+	return utils.RenderICS(method, ev)
+}
+
+func meetingURL(c *gin.Context, id uint) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/meetings/%d", scheme, c.Request.Host, id)
+}
+
+// nextOccurrence returns the first occurrence of m that hasn't ended by from.
+func nextOccurrence(m models.Meeting, from time.Time) (time.Time, bool) {
+	from = from.Add(-time.Duration(m.DurationMinutes) * time.Minute)
+	if m.Recurrence == "" {
+		return m.StartTime, !m.StartTime.Before(from)
+	}
+	rule, err := utils.ParseRRule(m.Recurrence)
+	if err != nil {
+		return time.Time{}, false
+	}
+	next, ok := rule.Next(m.StartTime.In(meetingLocation(m)), from)
+	return next.UTC(), ok
+}
+
+// meetingLocation is the time zone m recurs in, UTC unless the host picked one.
+func meetingLocation(m models.Meeting) *time.Location {
+	if m.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(m.TimeZone)
+	if err != nil {
+		log.Printf("meeting %d: unknown time zone %q: %v", m.Id, m.TimeZone, err)
+		return time.UTC
+	}
+	return loc
+}
+
+func setNextStart(m *models.Meeting) {
+	m.NextStart = nil
+	if next, ok := nextOccurrence(*m, time.Now()); ok {
+		m.NextStart = &next
+	}
+}
+
+func saveInvitees(tx *gorm.DB, meetingID uint, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	rows := make([]models.MeetingInvitee, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, models.MeetingInvitee{MeetingId: meetingID, UserId: id})
+	}
+	return tx.Create(&rows).Error
+}
+
+func loadInviteeIds(meetingID uint) []uint {
+	ids := make([]uint, 0)
+	database.Db.Model(&models.MeetingInvitee{}).Where("meeting_id = ?", meetingID).Pluck("user_id", &ids)
+	return ids
+}
+
+// notifyMeeting pushes a meeting event for the occurrence at start to the given online users.
+func notifyMeeting(typ models.WSMessageType, m models.Meeting, start time.Time, userIds []uint) {
+	if wsHub == nil || len(userIds) == 0 {
+		return
+	}
+	n := models.MeetingNotification{MeetingId: m.Id, Title: m.Title, StartTime: start, HostId: m.HostId}
+	if m.CallId != nil {
+		n.CallId = *m.CallId
+	}
+	msg := models.WebSocketMessage{Type: typ, Payload: n, Time: time.Now()}
+	for _, id := range userIds {
+		wsHub.SendToUser(id, msg)
+	}
+}
+
+// notifyMeetingCancelled tells each of userIds that m was cancelled for them, attaching an
+// iCalendar CANCEL that lists only them.
+func notifyMeetingCancelled(c *gin.Context, m models.Meeting, userIds []uint) {
+	if wsHub == nil {
+		return
+	}
+	for _, id := range userIds {
+		n := models.MeetingNotification{MeetingId: m.Id, Title: m.Title, StartTime: m.StartTime, HostId: m.HostId,
+			Calendar: meetingICS(c, "CANCEL", m, []uint{id})}
+		wsHub.SendToUser(id, models.WebSocketMessage{Type: models.MessageTypeMeetingCancelled, Payload: n, Time: time.Now()})
+	}
+}
+
+// diffIds returns the ids only in after, and those only in before.
+func diffIds(before, after []uint) (added, removed []uint) {
+	in := make(map[uint]bool, len(before))
+	for _, id := range before {
+		in[id] = true
+	}
+	for _, id := range after {
+		if !in[id] {
+			added = append(added, id)
+		}
+		delete(in, id)
+	}
+	for id := range in {
+		removed = append(removed, id)
+	}
+	return added, removed
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestMeetingRecursInItsTimeZone(t *testing.T) {
+	testDB(t)
+	host := testUsers(t, "host")[0]
+	body := map[string]any{
+		"title": "Weekly", "startTime": "2026-03-23T09:00:00+01:00", "durationMinutes": 30,
+		"recurrence": "FREQ=WEEKLY", "timeZone": "Europe/Berlin",
+	}
+	var m models.Meeting
+	if code := serve(t, host, http.MethodPost, "/meetings", "/meetings", body, CreateMeeting, &m); code != http.StatusCreated {
+		t.Fatalf("create: status %d", code)
+	}
+	// the week after clocks go forward, 09:00 in Berlin is 07:00 UTC
+	next, ok := nextOccurrence(m, m.StartTime.Add(time.Hour))
+	if want := time.Date(2026, 3, 30, 7, 0, 0, 0, time.UTC); !ok || !next.Equal(want) {
+		t.Errorf("next occurrence %v, want %v", next, want)
+	}
+
+	body["timeZone"] = "Mars/Olympus"
+	if code := serve(t, host, http.MethodPost, "/meetings", "/meetings", body, CreateMeeting, nil); code != http.StatusBadRequest {
+		t.Errorf("unknown time zone: status %d, want 400", code)
+	}
+}
+
+func TestMeetingCancellationsCarryICS(t *testing.T) {
+	testDB(t)
+	h := testHub(t)
+	users := testUsers(t, "host", "alice", "bob")
+	host, alice, bob := users[0], users[1], users[2]
+	aliceWS, bobWS := connect(h, alice.Id), connect(h, bob.Id)
+
+	body := map[string]any{"title": "Plan", "startTime": time.Now().Add(time.Hour), "durationMinutes": 30,
+		"inviteeIds": []uint{alice.Id, bob.Id}}
+	var m models.Meeting
+	if code := serve(t, host, http.MethodPost, "/meetings", "/meetings", body, CreateMeeting, &m); code != http.StatusCreated {
+		t.Fatalf("create: status %d", code)
+	}
+	received(aliceWS)
+	received(bobWS)
+
+	cancellation := func(c []models.WebSocketMessage, who models.User) string {
+		t.Helper()
+		if len(c) != 1 || c[0].Type != models.MessageTypeMeetingCancelled {
+			t.Fatalf("%s got %v, want one meeting_cancelled", who.Name, c)
+		}
+		ics := strings.ReplaceAll(c[0].Payload.(models.MeetingNotification).Calendar, "\r\n ", "")
+		for _, want := range []string{"METHOD:CANCEL", "STATUS:CANCELLED", "mailto:" + who.Email} {
+			if !strings.Contains(ics, want) {
+				t.Errorf("%s's cancellation is missing %q:\n%s", who.Name, want, ics)
+			}
+		}
+		return ics
+	}
+
+	path := fmt.Sprintf("/meetings/%d", m.Id)
+	update := map[string]any{"inviteeIds": []uint{alice.Id}}
+	if code := serve(t, host, http.MethodPut, "/meetings/:id", path, update, UpdateMeeting, nil); code != http.StatusOK {
+		t.Fatalf("update: status %d", code)
+	}
+	cancellation(received(bobWS), bob)
+	if got := received(aliceWS); len(got) != 0 {
+		t.Errorf("alice, still invited, got %v", got)
+	}
+
+	if code := serve(t, host, http.MethodDelete, "/meetings/:id", path, nil, DeleteMeeting, nil); code != http.StatusNoContent {
+		t.Fatalf("delete: status %d", code)
+	}
+	ics := cancellation(received(aliceWS), alice)
+	if strings.Contains(ics, bob.Email) {
+		t.Error("alice's cancellation lists bob")
+	}
+	if !strings.Contains(ics, "SEQUENCE:2") {
+		t.Errorf("cancellation should follow the update's sequence:\n%s", ics)
+	}
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// message pages default to 50 messages and are capped at 200; bodies are capped at 4000 characters.
+const (
+	defaultMessagePage = 50
+	maxMessagePage     = 200
+	maxMessageLength   = 4000
+)
+
+type sendMessagePayload struct {
+	RecipientId *uint  `json:"recipientId"`
+	CallId      *uint  `json:"callId"`
+	Body        string `json:"body" binding:"required"`
+}
+
+// SendMessage sends a direct message (recipientId), a call message (callId), or a private in-call
+// message (both). Recipients that are online get it over WS right away; offline recipients of direct
+// messages get it when they next connect.
+func SendMessage(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	var p sendMessagePayload
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p.Body = strings.TrimSpace(p.Body)
+	if p.Body == "" || len([]rune(p.Body)) > maxMessageLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "message body must be 1-4000 characters"})
+		return
+	}
+	if p.RecipientId == nil && p.CallId == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "recipientId or callId is required"})
+		return
+	}
+	if p.RecipientId != nil {
+		if *p.RecipientId == authUser.Id {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot message yourself"})
+			return
+		}
+		var recipient models.User
+		if err := database.Db.First(&recipient, *p.RecipientId).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipient not found"})
+			return
+		}
+		if ws.BlockedBetween(authUser.Id, recipient.Id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot message this user"})
+			return
+		}
+	}
+	if p.CallId != nil {
+		if !canAccessCall(authUser.Id, *p.CallId) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "call not found"})
+			return
+		}
+		if p.RecipientId != nil && !canAccessCall(*p.RecipientId, *p.CallId) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "recipient is not part of this call"})
+			return
+		}
+	}
+
+	msg := models.Message{
+		SenderId:    authUser.Id,
+		RecipientId: p.RecipientId,
+		CallId:      p.CallId,
+		Body:        p.Body,
+		CreatedAt:   time.Now(),
+	}
+	if err := database.Db.Create(&msg).Error; err != nil {
+		log.Printf("send message error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send message"})
+		return
+	}
+
+	recipients := []uint{}
+	if p.RecipientId != nil {
+		recipients = append(recipients, *p.RecipientId)
+	} else {
+		for _, uid := range callMembers(*p.CallId) {
+			// call chat is still stored, but not pushed to members who blocked the sender
+			if uid != authUser.Id && !ws.BlockedBetween(authUser.Id, uid) {
+				recipients = append(recipients, uid)
+			}
+		}
+	}
+	if wsHub != nil {
+		for _, uid := range recipients {
+			ev := models.WebSocketMessage{Type: models.MessageTypeMessageNew, Payload: msg, Time: msg.CreatedAt}
+			if wsHub.SendToUser(uid, ev) && msg.IsDirect() {
+				now := time.Now()
+				msg.DeliveredAt = &now
+				database.Db.Model(&msg).Update("delivered_at", now)
+			}
+		}
+	}
+	c.JSON(http.StatusCreated, msg)
+}
+
+// GetConversations lists the user's direct and call conversations, most recently active first
+// (?limit=, ?offset=).
+func GetConversations(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	offset = max(offset, 0)
+
+	db := database.Db
+	type lastRow struct {
+		RefId  uint
+		LastId uint
+	}
+	var direct, calls []lastRow
+	if err := db.Model(&models.Message{}).
+		Select("CASE WHEN sender_id = ? THEN recipient_id ELSE sender_id END AS ref_id, MAX(id) AS last_id", authUser.Id).
+		Where("call_id IS NULL AND (sender_id = ? OR recipient_id = ?)", authUser.Id, authUser.Id).
+		Group("ref_id").Scan(&direct).Error; err != nil {
+		log.Printf("conversations query error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query conversations"})
+		return
+	}
+	callerOf := db.Model(&models.Call{}).Select("id").Where("caller_id = ?", authUser.Id)
+	historyOf := db.Model(&models.History{}).Select("call_id").Where("user_id = ?", authUser.Id)
+	if err := db.Model(&models.Message{}).
+		Select("call_id AS ref_id, MAX(id) AS last_id").
+		Where("call_id IS NOT NULL AND (recipient_id IS NULL OR sender_id = ? OR recipient_id = ?)", authUser.Id, authUser.Id).
+		Where("call_id IN (?) OR call_id IN (?) OR sender_id = ?", callerOf, historyOf, authUser.Id).
+		Group("call_id").Scan(&calls).Error; err != nil {
+		log.Printf("conversations query error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query conversations"})
+		return
+	}
+
+	type unreadRow struct {
+		SenderId uint
+		Count    int64
+	}
+	var unread []unreadRow
+	db.Model(&models.Message{}).Select("sender_id, COUNT(*) AS count").
+		Where("call_id IS NULL AND recipient_id = ? AND read_at IS NULL", authUser.Id).
+		Group("sender_id").Scan(&unread)
+	unreadFrom := make(map[uint]int64, len(unread))
+	for _, u := range unread {
+		unreadFrom[u.SenderId] = u.Count
+	}
+
+	convs := make([]models.Conversation, 0, len(direct)+len(calls))
+	for _, r := range direct {
+		peer := r.RefId
+		convs = append(convs, models.Conversation{PeerId: &peer, LastMessage: models.Message{Id: r.LastId}, Unread: unreadFrom[peer]})
+	}
+	for _, r := range calls {
+		call := r.RefId
+		convs = append(convs, models.Conversation{CallId: &call, LastMessage: models.Message{Id: r.LastId}})
+	}
+	sort.Slice(convs, func(i, j int) bool { return convs[i].LastMessage.Id > convs[j].LastMessage.Id })
+	if offset >= len(convs) {
+		c.JSON(http.StatusOK, []models.Conversation{})
+		return
+	}
+	convs = convs[offset:min(offset+limit, len(convs))]
+
+	ids := make([]uint, len(convs))
+	for i, cv := range convs {
+		ids[i] = cv.LastMessage.Id
+	}
+	var last []models.Message
+	db.Where("id IN ?", ids).Find(&last)
+	byID := make(map[uint]models.Message, len(last))
+	for _, m := range last {
+		byID[m.Id] = m
+	}
+	for i := range convs {
+		convs[i].LastMessage = byID[convs[i].LastMessage.Id]
+	}
+	c.JSON(http.StatusOK, convs)
+}
+
+// GetDirectMessages returns the direct conversation with :userId, newest first (?before=<id>, ?limit=).
+func GetDirectMessages(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+	peerId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+
+	q := database.Db.Where("call_id IS NULL AND ((sender_id = ? AND recipient_id = ?) OR (sender_id = ? AND recipient_id = ?))",
+		authUser.Id, peerId, peerId, authUser.Id)
+	if before, _ := strconv.ParseUint(c.Query("before"), 10, 32); before > 0 {
+		q = q.Where("id < ?", before)
+	}
+	var messages []models.Message
+	if err := q.Order("id DESC").Limit(limit).Find(&messages).Error; err != nil {
+		log.Printf("direct messages query error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query messages"})
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+// MarkDirectRead marks every message from :userId as read and sends the read receipt to them.
+func MarkDirectRead(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+	peerId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	db := database.Db
+	var unread []models.Message
+	if err := db.Where("call_id IS NULL AND sender_id = ? AND recipient_id = ? AND read_at IS NULL", peerId, authUser.Id).
+		Find(&unread).Error; err != nil {
+		log.Printf("mark read query error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to mark messages read"})
+		return
+	}
+	if len(unread) == 0 {
+		c.JSON(http.StatusOK, gin.H{"read": 0})
+		return
+	}
+
+	now := time.Now()
+	ids := make([]uint, len(unread))
+	for i, m := range unread {
+		ids[i] = m.Id
+	}
+	db.Model(&models.Message{}).Where("id IN ? AND delivered_at IS NULL", ids).Update("delivered_at", now)
+	if err := db.Model(&models.Message{}).Where("id IN ?", ids).Update("read_at", now).Error; err != nil {
+		log.Printf("mark read update error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to mark messages read"})
+		return
+	}
+	if wsHub != nil {
+		wsHub.NotifyReceipts(models.MessageTypeMessageRead, authUser.Id, unread, now)
+	}
+	c.JSON(http.StatusOK, gin.H{"read": len(unread)})
+}
+
+// GetCallMessages returns the messages of a call, newest first (?before=<id>, ?limit=). Private
+// in-call messages are only shown to their sender and recipient.
+func GetCallMessages(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+	callId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid call id"})
+		return
+	}
+	if !canAccessCall(authUser.Id, uint(callId)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "call not found"})
+		return
+	}
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+
+	q := database.Db.Where("call_id = ? AND (recipient_id IS NULL OR sender_id = ? OR recipient_id = ?)",
+		callId, authUser.Id, authUser.Id)
+	if before, _ := strconv.ParseUint(c.Query("before"), 10, 32); before > 0 {
+		q = q.Where("id < ?", before)
+	}
+	var messages []models.Message
+	if err := q.Order("id DESC").Limit(limit).Find(&messages).Error; err != nil {
+		log.Printf("call messages query error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query messages"})
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+// pageLimit parses ?limit=. It writes the error response itself and returns ok=false on bad input.
+func pageLimit(c *gin.Context) (int, bool) {
+	s := c.Query("limit")
+	if s == "" {
+		return defaultMessagePage, true
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return 0, false
+	}
+	return min(limit, maxMessagePage), true
+}
+
+// callMembers returns everyone who took part in or was invited to a call.
+func callMembers(callID uint) []uint {
+	seen := make(map[uint]bool)
+	var out []uint
+	add := func(ids ...uint) {
+		for _, id := range ids {
+			if id != 0 && !seen[id] {
+				seen[id] = true
+				out = append(out, id)
+			}
+		}
+	}
+
+	db := database.Db
+	var call models.Call
+	if err := db.First(&call, callID).Error; err == nil {
+		add(call.CallerId)
+	}
+	var userIds []uint
+	db.Model(&models.History{}).Where("call_id = ?", callID).Pluck("user_id", &userIds)
+	add(userIds...)
+	if wsHub != nil {
+		if session, ok := wsHub.GetCallSession(callID); ok {
+			session.Mu.RLock()
+			add(session.Call.CalleeIds...)
+			for uid := range session.Participants {
+				add(uid)
+			}
+			session.Mu.RUnlock()
+		}
+	}
+	return out
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestGetCallMessagesHidesPrivateChat(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "alice", "bob", "carol")
+	alice, bob, carol := users[0], users[1], users[2]
+
+	call := models.Call{CallerId: alice.Id, StartTime: time.Now(), Status: models.Ended}
+	database.Db.Create(&call)
+	for _, u := range []models.User{bob, carol} {
+		database.Db.Create(&models.History{CallId: call.Id, UserId: u.Id})
+	}
+	database.Db.Create(&models.Message{SenderId: alice.Id, CallId: &call.Id, Body: "hello all"})
+	database.Db.Create(&models.Message{SenderId: alice.Id, CallId: &call.Id, RecipientId: &bob.Id, Body: "psst"})
+
+	path := fmt.Sprintf("/calls/%d/messages", call.Id)
+	for u, want := range map[models.User]int{alice: 2, bob: 2, carol: 1} {
+		var got []models.Message
+		if code := serve(t, u, http.MethodGet, "/calls/:id/messages", path, nil, GetCallMessages, &got); code != http.StatusOK {
+			t.Fatalf("%s: status %d", u.Name, code)
+		}
+		if len(got) != want {
+			t.Errorf("%s sees %d messages, want %d", u.Name, len(got), want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+)
+
+type audioMixingRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// SetCallAudioMixing switches the whole call between SFU audio and server-mixed audio; host only.
+func SetCallAudioMixing(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	if !session.IsHost(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the host can change the call's audio mode"})
+		return
+	}
+	var req audioMixingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !writeMixingError(c, session.ID, session.SetAudioMixing(*req.Enabled)) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"callId": session.ID, "enabled": *req.Enabled})
+}
+
+// SetMyAudioMixing lets a participant on a poor link receive one mixed audio track for themselves.
+func SetMyAudioMixing(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	var req audioMixingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !writeMixingError(c, session.ID, session.SetParticipantAudioMixing(authUser.Id, *req.Enabled)) {
+		return
+	}
+	// the call-wide mode wins over a participant opting out
+	c.JSON(http.StatusOK, gin.H{"callId": session.ID, "enabled": session.ReceivesMixedAudio(authUser.Id)})
+}
+
+// writeMixingError reports err to the client and returns false if there was one.
+func writeMixingError(c *gin.Context, callID uint, err error) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, ws.ErrMixingUnavailable) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return false
+	}
+	log.Printf("audio mixing error for call %d: %v", callID, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change audio mode"})
+	return false
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// GetCallRoles lists the role of everyone in a live call, along with its host and lock state.
+func GetCallRoles(c *gin.Context) {
+	session, _, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, session.CallRoles())
+}
+
+// GetLobby lists who is waiting to be admitted to a call; host and moderators only.
+func GetLobby(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	if !session.CanModerate(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the host or a moderator can see the lobby"})
+		return
+	}
+	session.Mu.RLock()
+	enabled := session.LobbyEnabled
+	session.Mu.RUnlock()
+	c.JSON(http.StatusOK, gin.H{"callId": session.ID, "enabled": enabled, "waiting": session.LobbyEntries()})
+}
+
+// ModerateCall applies a host or moderator action to a live call; the body is a
+// models.ModerationRequest, the same payload as the "moderate" WS message.
+func ModerateCall(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	var req models.ModerationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := session.Moderate(authUser.Id, req)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"callId": session.ID, "action": req.Action})
+	case errors.Is(err, ws.ErrNotPermitted):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrNotInCall), errors.Is(err, ws.ErrNotInLobby):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrInvalidModeration):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("moderation error for call %d: %v", session.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to apply moderation action"})
+	}
+}
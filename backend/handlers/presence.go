@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxStatusTextLen   = 100
+	maxStatusEmojiRune = 8
+)
+
+// SetPresence sets the authenticated user's presence (available, away or dnd) and custom status.
+// The status resets to available when expiresAt passes, or after expiresInMinutes.
+func SetPresence(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	var req struct {
+		Presence          models.Presence `json:"presence" binding:"required"`
+		StatusText        string          `json:"statusText"`
+		StatusEmoji       string          `json:"statusEmoji"`
+		ExpiresAt         *time.Time      `json:"expiresAt"`
+		ExpiresInMinutes  int             `json:"expiresInMinutes"`
+		DndAllowFavorites *bool           `json:"dndAllowFavorites"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Presence.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "presence must be available, away or dnd"})
+		return
+	}
+	req.StatusText = strings.TrimSpace(req.StatusText)
+	req.StatusEmoji = strings.TrimSpace(req.StatusEmoji)
+	if utf8.RuneCountInString(req.StatusText) > maxStatusTextLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "statusText is limited to 100 characters"})
+		return
+	}
+	if utf8.RuneCountInString(req.StatusEmoji) > maxStatusEmojiRune {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "statusEmoji must be a single emoji"})
+		return
+	}
+	expires := req.ExpiresAt
+	if req.ExpiresInMinutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiresInMinutes must not be negative"})
+		return
+	}
+	if expires == nil && req.ExpiresInMinutes > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresInMinutes) * time.Minute)
+		expires = &t
+	}
+	if expires != nil && !expires.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiry must be in the future"})
+		return
+	}
+
+	authUser.Presence = req.Presence
+	authUser.StatusText = req.StatusText
+	authUser.StatusEmoji = req.StatusEmoji
+	authUser.StatusExpiresAt = expires
+	if req.DndAllowFavorites != nil {
+		authUser.DndAllowFavorites = *req.DndAllowFavorites
+	}
+	if err := database.Db.Model(&models.User{}).Where("id = ?", authUser.Id).Updates(map[string]interface{}{
+		"presence":            authUser.Presence,
+		"status_text":         authUser.StatusText,
+		"status_emoji":        authUser.StatusEmoji,
+		"status_expires_at":   authUser.StatusExpiresAt,
+		"dnd_allow_favorites": authUser.DndAllowFavorites,
+	}).Error; err != nil {
+		log.Printf("failed to save presence of user %d: %v", authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save presence"})
+		return
+	}
+	if wsHub != nil {
+		wsHub.UpdatePresence(authUser)
+	}
+	c.JSON(http.StatusOK, authUser)
+}
+
+// ClearPresence resets the authenticated user to available with no custom status.
+func ClearPresence(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	authUser.Presence = models.Available
+	authUser.StatusText = ""
+	authUser.StatusEmoji = ""
+	authUser.StatusExpiresAt = nil
+	if err := database.Db.Model(&models.User{}).Where("id = ?", authUser.Id).Updates(map[string]interface{}{
+		"presence":          authUser.Presence,
+		"status_text":       "",
+		"status_emoji":      "",
+		"status_expires_at": nil,
+	}).Error; err != nil {
+		log.Printf("failed to clear presence of user %d: %v", authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear presence"})
+		return
+	}
+	if wsHub != nil {
+		wsHub.UpdatePresence(authUser)
+	}
+	c.JSON(http.StatusOK, authUser)
+}
+
+// SetPrivacy updates the authenticated user's presence privacy settings.
+func SetPrivacy(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	var req struct {
+		HideLastSeen *bool `json:"hideLastSeen"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.HideLastSeen != nil {
+		authUser.HideLastSeen = *req.HideLastSeen
+	}
+	if err := database.Db.Model(&models.User{}).Where("id = ?", authUser.Id).
+		Update("hide_last_seen", authUser.HideLastSeen).Error; err != nil {
+		log.Printf("failed to save privacy settings of user %d: %v", authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save privacy settings"})
+		return
+	}
+	if wsHub != nil {
+		wsHub.UpdatePresence(authUser)
+	}
+	c.JSON(http.StatusOK, gin.H{"hideLastSeen": authUser.HideLastSeen})
+}
+
+// redactPresence hides the presence of users who aren't mutual contacts of viewerID, and last
+// seen of contacts who chose to hide it.
+func redactPresence(viewerID uint, users []models.User) {
+	contacts := make(map[uint]bool)
+	for _, id := range ws.MutualContacts(viewerID) {
+		contacts[id] = true
+	}
+	for i := range users {
+		u := &users[i]
+		if u.Id == viewerID {
+			continue
+		}
+		if u.HideLastSeen || !contacts[u.Id] {
+			u.LastSeen = time.Time{}
+		}
+		if !contacts[u.Id] {
+			u.Status = ""
+			u.Presence = ""
+			u.StatusText = ""
+			u.StatusEmoji = ""
+			u.StatusExpiresAt = nil
+		}
+		u.DndAllowFavorites = false
+		u.HideLastSeen = false
+	}
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/utils"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// StartRecording starts recording a live call; every participant is notified over WS.
+func StartRecording(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	rec, err := session.StartRecording(authUser.Id)
+	if errors.Is(err, ws.ErrRecordingActive) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("start recording error for call %d: %v", session.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start recording"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"callId": session.ID, "startedBy": rec.StartedBy, "startedAt": rec.StartedAt})
+}
+
+// StopRecording stops the active recording of a call and returns the files that were written.
+func StopRecording(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	recordings, err := session.StopRecording(authUser.Id)
+	if errors.Is(err, ws.ErrRecordingInactive) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("stop recording error for call %d: %v", session.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to stop recording"})
+		return
+	}
+	c.JSON(http.StatusOK, recordings)
+}
+
+// signed recording links last 15 minutes unless ?ttl= asks for longer, and at most one day.
+const (
+	defaultSignedURLTTL = 15 * time.Minute
+	maxSignedURLTTL     = 24 * time.Hour
+)
+
+// canAccessCall reports whether userID took part in the call: as caller, as a history participant,
+// as a recorded publisher, as a chat sender, or as a participant of the live session.
+func canAccessCall(userID, callID uint) bool {
+	db := database.Db
+	var count int64
+	db.Model(&models.Call{}).Where("id = ? AND caller_id = ?", callID, userID).Count(&count)
+	if count > 0 {
+		return true
+	}
+	db.Model(&models.History{}).Where("call_id = ? AND user_id = ?", callID, userID).Count(&count)
+	if count > 0 {
+		return true
+	}
+	db.Model(&models.Recording{}).Where("call_id = ? AND user_id = ?", callID, userID).Count(&count)
+	if count > 0 {
+		return true
+	}
+	db.Model(&models.Message{}).Where("call_id = ? AND sender_id = ?", callID, userID).Count(&count)
+	if count > 0 {
+		return true
+	}
+	if wsHub != nil {
+		if session, ok := wsHub.GetCallSession(callID); ok && session.HasParticipant(userID) {
+			return true
+		}
+	}
+	return false
+}
+
+// findRecording loads :id and checks the authenticated user may access it.
+// It writes the error response itself and returns ok=false when the request should stop.
+func findRecording(c *gin.Context) (models.Recording, models.User, bool) {
+	var rec models.Recording
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return rec, models.User{}, false
+	}
+	authUser := ai.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recording id"})
+		return rec, authUser, false
+	}
+	if err := database.Db.First(&rec, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recording not found"})
+		return rec, authUser, false
+	}
+	if !canAccessCall(authUser.Id, rec.CallId) {
+		// don't reveal recordings of calls the user wasn't part of
+		c.JSON(http.StatusNotFound, gin.H{"error": "recording not found"})
+		return rec, authUser, false
+	}
+	return rec, authUser, true
+}
+
+// GetRecordings lists recordings of every call the authenticated user participated in.
+func GetRecordings(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	db := database.Db
+	callerOf := db.Model(&models.Call{}).Select("id").Where("caller_id = ?", authUser.Id)
+	historyOf := db.Model(&models.History{}).Select("call_id").Where("user_id = ?", authUser.Id)
+
+	var recordings []models.Recording
+	if err := db.Where("call_id IN (?) OR call_id IN (?) OR user_id = ?", callerOf, historyOf, authUser.Id).
+		Order("start_time DESC").Find(&recordings).Error; err != nil {
+		log.Printf("recordings query error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query recordings"})
+		return
+	}
+	c.JSON(http.StatusOK, recordings)
+}
+
+// GetCallRecordings lists the recordings of one call.
+func GetCallRecordings(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+
+	callId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid call id"})
+		return
+	}
+	if !canAccessCall(authUser.Id, uint(callId)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a participant of this call"})
+		return
+	}
+
+	var recordings []models.Recording
+	if err := database.Db.Where("call_id = ?", callId).Order("start_time").Find(&recordings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query recordings"})
+		return
+	}
+	c.JSON(http.StatusOK, recordings)
+}
+
+func GetRecording(c *gin.Context) {
+	rec, _, ok := findRecording(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, rec)
+}
+
+// DownloadRecording streams the recording file, honouring Range requests for seeking.
+func DownloadRecording(c *gin.Context) {
+	rec, _, ok := findRecording(c)
+	if !ok {
+		return
+	}
+	serveRecording(c, rec)
+}
+
+// CreateRecordingURL returns a signed link to the recording that works without a bearer token
+// until it expires (?ttl=<seconds>, default 15 minutes).
+func CreateRecordingURL(c *gin.Context) {
+	rec, _, ok := findRecording(c)
+	if !ok {
+		return
+	}
+	ttl := defaultSignedURLTTL
+	if s := c.Query("ttl"); s != "" {
+		secs, err := strconv.Atoi(s)
+		if err != nil || secs <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ttl"})
+			return
+		}
+		ttl = time.Duration(secs) * time.Second
+		if ttl > maxSignedURLTTL {
+			ttl = maxSignedURLTTL
+		}
+	}
+	expires := time.Now().Add(ttl)
+	path := fmt.Sprintf("/recordings/%d/stream", rec.Id)
+	url := fmt.Sprintf("%s?expires=%d&sig=%s", path, expires.Unix(), utils.SignPath(path, expires))
+	c.JSON(http.StatusOK, gin.H{"url": url, "expiresAt": expires})
+}
+
+// StreamSignedRecording serves a recording to holders of a link produced by CreateRecordingURL.
+func StreamSignedRecording(c *gin.Context) {
+	if err := utils.VerifySignedPath(c.Request.URL.Path, c.Query("expires"), c.Query("sig")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	var rec models.Recording
+	if err := database.Db.First(&rec, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recording not found"})
+		return
+	}
+	serveRecording(c, rec)
+}
+
+// DeleteRecording removes a recording; only whoever started it or the call's caller may do so.
+func DeleteRecording(c *gin.Context) {
+	rec, authUser, ok := findRecording(c)
+	if !ok {
+		return
+	}
+	var call models.Call
+	database.Db.First(&call, rec.CallId)
+	if rec.StartedBy != authUser.Id && call.CallerId != authUser.Id {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the recording starter or call owner can delete it"})
+		return
+	}
+	if err := deleteRecording(rec); err != nil {
+		log.Printf("delete recording %d error: %v", rec.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete recording"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func serveRecording(c *gin.Context, rec models.Recording) {
+	if rec.EndTime == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "recording still in progress"})
+		return
+	}
+	f, err := os.Open(rec.Path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recording file missing"})
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read recording"})
+		return
+	}
+
+	c.Header("Content-Type", recordingContentType(rec.Path))
+	if c.Query("download") != "" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(rec.Path)))
+	}
+	http.ServeContent(c.Writer, c.Request, filepath.Base(rec.Path), fi.ModTime(), f)
+}
+
+func recordingContentType(path string) string {
+	switch filepath.Ext(path) {
+	case ".ogg":
+		return "audio/ogg"
+	case ".ivf":
+		return "video/x-ivf"
+	case ".h264":
+		return "video/h264"
+	case ".webm":
+		return "video/webm"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+func deleteRecording(rec models.Recording) error {
+	if err := os.Remove(rec.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return database.Db.Delete(&models.Recording{}, rec.Id).Error
+}
+
+// PurgeRecordings deletes finished recordings that started before cutoff and returns how many were removed.
+func PurgeRecordings(cutoff time.Time) (int, error) {
+	var expired []models.Recording
+	if err := database.Db.Where("start_time < ? AND end_time IS NOT NULL", cutoff).Find(&expired).Error; err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, rec := range expired {
+		if err := deleteRecording(rec); err != nil {
+			log.Printf("purge recording %d error: %v", rec.Id, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// StartRecordingRetention purges recordings older than retention once an hour.
+func StartRecordingRetention(retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			n, err := PurgeRecordings(time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("recording retention error: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("Purged %d recordings older than %v", n, retention)
+			}
+		}
+	}()
+}
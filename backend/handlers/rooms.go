@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// room codes avoid look-alike letters so they can be read out over the phone
+const roomCodeAlphabet = "abcdefghjkmnpqrstuvwxyz"
+
+var roomSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{2,62}$`)
+
+type roomRequest struct {
+	Name            *string `json:"name"`
+	Slug            string  `json:"slug"`
+	Passcode        *string `json:"passcode"` // empty string removes the passcode
+	MaxParticipants *int    `json:"maxParticipants"`
+	MaxScreenShares *int    `json:"maxScreenShares"`
+	AudioMixing     *bool   `json:"audioMixing"`
+
+	Lobby                 *bool `json:"lobby"`
+	AutoAdmitContacts     *bool `json:"autoAdmitContacts"`
+	AutoAdmitOrganization *bool `json:"autoAdmitOrganization"`
+}
+
+// CreateRoom creates a room owned by the authenticated user. Without a slug a random code like
+// "abc-defg-hjk" is generated.
+func CreateRoom(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+	var req roomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	room := models.Room{OwnerId: authUser.Id, MaxScreenShares: ws.DefaultMaxScreenShares}
+	if req.Slug != "" {
+		if !roomSlugPattern.MatchString(req.Slug) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "slug must be 3-63 lowercase letters, digits or dashes"})
+			return
+		}
+		room.Slug = req.Slug
+	} else {
+		slug, err := newRoomCode()
+		if err != nil {
+			log.Printf("room code error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create room"})
+			return
+		}
+		room.Slug = slug
+	}
+	if !applyRoomRequest(c, &room, req) {
+		return
+	}
+	if room.Name == "" {
+		room.Name = room.Slug
+	}
+
+	var taken int64
+	database.Db.Model(&models.Room{}).Where("slug = ?", room.Slug).Count(&taken)
+	if taken > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "slug already taken"})
+		return
+	}
+	if err := database.Db.Create(&room).Error; err != nil {
+		log.Printf("create room error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create room"})
+		return
+	}
+	c.JSON(http.StatusCreated, roomResponse(room))
+}
+
+// GetMyRooms lists the rooms owned by the authenticated user.
+func GetMyRooms(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+	var rooms []models.Room
+	if err := database.Db.Where("owner_id = ?", authUser.Id).Order("created_at desc").Find(&rooms).Error; err != nil {
+		log.Printf("get rooms error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch rooms"})
+		return
+	}
+	out := make([]gin.H, 0, len(rooms))
+	for _, r := range rooms {
+		out = append(out, roomResponse(r))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// GetRoom describes a room to someone holding its link, including whether a call is running.
+func GetRoom(c *gin.Context) {
+	room, ok := findRoom(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, roomResponse(room))
+}
+
+// UpdateRoom changes a room's name, passcode or settings; owner only. Settings apply to the next
+// call started in the room.
+func UpdateRoom(c *gin.Context) {
+	room, ok := ownedRoom(c)
+	if !ok {
+		return
+	}
+	var req roomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Slug != "" && req.Slug != room.Slug {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a room's slug cannot be changed"})
+		return
+	}
+	if !applyRoomRequest(c, &room, req) {
+		return
+	}
+	if err := database.Db.Save(&room).Error; err != nil {
+		log.Printf("update room error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update room"})
+		return
+	}
+	c.JSON(http.StatusOK, roomResponse(room))
+}
+
+// DeleteRoom deletes a room; owner only. A call already running in it carries on.
+func DeleteRoom(c *gin.Context) {
+	room, ok := ownedRoom(c)
+	if !ok {
+		return
+	}
+	if err := database.Db.Delete(&room).Error; err != nil {
+		log.Printf("delete room error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete room"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// JoinRoom joins the call running in a room, starting it if the room is empty. The body carries
+// the passcode when the room has one; the owner never needs it. The response's call id is used
+// for the usual call_offer over WS. In a room with a lobby the response is 202 and the user
+// waits for a lobby_admitted or lobby_denied WS message.
+func JoinRoom(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+	room, ok := findRoom(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		Passcode string `json:"passcode"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	// guests hold an invite from the owner instead of the passcode
+	if room.Passcode != "" && room.OwnerId != authUser.Id && !authUser.Guest {
+		if bcrypt.CompareHashAndPassword([]byte(room.Passcode), []byte(body.Passcode)) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "wrong passcode"})
+			return
+		}
+	}
+	if wsHub == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "call service unavailable"})
+		return
+	}
+
+	session, admitted, err := wsHub.JoinRoom(room, authUser)
+	switch {
+	case errors.Is(err, ws.ErrRoomFull), errors.Is(err, ws.ErrCallLocked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ws.ErrNotPermitted):
+		c.JSON(http.StatusForbidden, gin.H{"error": "you were removed from this call"})
+		return
+	case err != nil:
+		log.Printf("join room %d error: %v", room.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to join room"})
+		return
+	}
+	if !admitted {
+		c.JSON(http.StatusAccepted, gin.H{"room": roomResponse(room), "callId": session.ID, "waiting": true})
+		return
+	}
+	session.Mu.RLock()
+	call := session.Call
+	session.Mu.RUnlock()
+	c.JSON(http.StatusOK, gin.H{"room": roomResponse(room), "call": call})
+}
+
+// applyRoomRequest copies the optional fields of req into room, writing a 400 on invalid input.
+func applyRoomRequest(c *gin.Context, room *models.Room, req roomRequest) bool {
+	if req.Name != nil {
+		room.Name = *req.Name
+	}
+	if req.MaxParticipants != nil {
+		if *req.MaxParticipants < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "maxParticipants cannot be negative"})
+			return false
+		}
+		room.MaxParticipants = *req.MaxParticipants
+	}
+	if req.MaxScreenShares != nil {
+		if *req.MaxScreenShares < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "maxScreenShares cannot be negative"})
+			return false
+		}
+		room.MaxScreenShares = *req.MaxScreenShares
+	}
+	if req.AudioMixing != nil {
+		room.AudioMixing = *req.AudioMixing
+	}
+	if req.Lobby != nil {
+		room.Lobby = *req.Lobby
+	}
+	if req.AutoAdmitContacts != nil {
+		room.AutoAdmitContacts = *req.AutoAdmitContacts
+	}
+	if req.AutoAdmitOrganization != nil {
+		room.AutoAdmitOrganization = *req.AutoAdmitOrganization
+	}
+	if req.Passcode != nil {
+		room.Passcode = ""
+		if *req.Passcode != "" {
+			if len(*req.Passcode) < 4 || len(*req.Passcode) > 64 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "passcode must be 4-64 characters"})
+				return false
+			}
+			hashed, err := bcrypt.GenerateFromPassword([]byte(*req.Passcode), bcrypt.DefaultCost)
+			if err != nil {
+				log.Printf("passcode hash error: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process passcode"})
+				return false
+			}
+			room.Passcode = string(hashed)
+		}
+	}
+	return true
+}
+
+// findRoom loads the room named by :slug, writing a 404 if there is none.
+func findRoom(c *gin.Context) (models.Room, bool) {
+	var room models.Room
+	err := database.Db.Where("slug = ?", c.Param("slug")).First(&room).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return room, false
+	}
+	if err != nil {
+		log.Printf("find room error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch room"})
+		return room, false
+	}
+	return room, true
+}
+
+// ownedRoom is findRoom restricted to the room's owner.
+func ownedRoom(c *gin.Context) (models.Room, bool) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return models.Room{}, false
+	}
+	authUser := ai.(models.User)
+	room, ok := findRoom(c)
+	if !ok {
+		return room, false
+	}
+	if room.OwnerId != authUser.Id {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the room owner can change it"})
+		return room, false
+	}
+	return room, true
+}
+
+// roomResponse adds the join link and the live call, if any, to a room.
+func roomResponse(room models.Room) gin.H {
+	room.HasPasscode = room.Passcode != ""
+	out := gin.H{"room": room, "joinUrl": fmt.Sprintf("/rooms/%s", room.Slug), "live": false}
+	if wsHub != nil {
+		if session, ok := wsHub.RoomSession(room.Id); ok {
+			out["live"] = true
+			out["callId"] = session.ID
+		}
+	}
+	return out
+}
+
+// newRoomCode returns a random code such as "abc-defg-hjk".
+func newRoomCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := make([]byte, 0, 12)
+	for i, v := range b {
+		if i == 3 || i == 7 {
+			code = append(code, '-')
+		}
+		code = append(code, roomCodeAlphabet[int(v)%len(roomCodeAlphabet)])
+	}
+	return string(code), nil
+}
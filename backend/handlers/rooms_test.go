@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestJoinRoomChecksPasscode(t *testing.T) {
+	testDB(t)
+	testHub(t)
+	users := testUsers(t, "owner", "bob")
+	owner, bob := users[0], users[1]
+
+	var created struct {
+		Room struct {
+			Slug        string `json:"slug"`
+			HasPasscode bool   `json:"hasPasscode"`
+		} `json:"room"`
+	}
+	passcode := "1234"
+	body := map[string]any{"slug": "standup", "passcode": passcode}
+	if code := serve(t, owner, "POST", "/rooms", "/rooms", body, CreateRoom, &created); code != http.StatusCreated {
+		t.Fatalf("create room: %d", code)
+	}
+	if created.Room.Slug != "standup" || !created.Room.HasPasscode {
+		t.Fatalf("created room = %+v", created.Room)
+	}
+	if code := serve(t, bob, "POST", "/rooms", "/rooms", body, CreateRoom, nil); code != http.StatusConflict {
+		t.Fatalf("reused slug: %d, want 409", code)
+	}
+
+	join := func(user models.User, passcode string) int {
+		var b any
+		if passcode != "" {
+			b = map[string]string{"passcode": passcode}
+		}
+		return serve(t, user, "POST", "/rooms/:slug/join", "/rooms/standup/join", b, JoinRoom, nil)
+	}
+	if code := join(bob, ""); code != http.StatusForbidden {
+		t.Fatalf("join without passcode: %d, want 403", code)
+	}
+	if code := join(bob, "wrong"); code != http.StatusForbidden {
+		t.Fatalf("join with wrong passcode: %d, want 403", code)
+	}
+	if code := join(owner, ""); code != http.StatusOK {
+		t.Fatalf("owner join: %d, want 200", code)
+	}
+	if code := join(bob, passcode); code != http.StatusOK {
+		t.Fatalf("join with passcode: %d, want 200", code)
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// GetScreenShares lists who is presenting in a call and how many screens may be shared at once.
+func GetScreenShares(c *gin.Context) {
+	session, _, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	session.Mu.RLock()
+	max := session.MaxScreenShares
+	session.Mu.RUnlock()
+	c.JSON(http.StatusOK, gin.H{"callId": session.ID, "max": max, "shares": session.ScreenShares()})
+}
+
+// SetScreenShareLimit changes how many participants may share their screen at once; host only.
+// A limit of 0 turns screen sharing off for new presenters.
+func SetScreenShareLimit(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	if !session.IsHost(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the host can change the screen share limit"})
+		return
+	}
+	var req struct {
+		Max *int `json:"max" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := session.SetMaxScreenShares(*req.Max); errors.Is(err, ws.ErrInvalidScreenLimit) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"callId": session.ID, "max": *req.Max})
+}
+
+// StopScreenShare ends a participant's screen share. Presenters can stop their own; the host and
+// moderators can stop anyone's.
+func StopScreenShare(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	if uint(userId) != authUser.Id && !session.CanModerate(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the host or a moderator can stop another participant's screen share"})
+		return
+	}
+	if err := session.StopScreenShare(uint(userId), authUser.Id); errors.Is(err, ws.ErrNoScreenShare) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"callId": session.ID, "userId": userId})
+}
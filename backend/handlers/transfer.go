@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// transferError maps errors from the ws transfer API onto HTTP responses.
+func transferError(c *gin.Context, callID uint, err error) {
+	switch {
+	case errors.Is(err, ws.ErrNotPermitted):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrNoTransfer), errors.Is(err, ws.ErrNotInCall):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrTransferPending), errors.Is(err, ws.ErrTargetUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrNotOneToOne), errors.Is(err, ws.ErrInvalidTransfer):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("transfer error for call %d: %v", callID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to transfer call"})
+	}
+}
+
+// StartTransfer hands the other party of the caller's 1:1 call over to targetId. mode is
+// "blind" (the default) or "attended".
+func StartTransfer(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		TargetId uint                `json:"targetId" binding:"required"`
+		Mode     models.TransferMode `json:"mode"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t, err := wsHub.StartTransfer(session, authUser.Id, req.TargetId, req.Mode)
+	if err != nil {
+		transferError(c, session.ID, err)
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+// GetTransfer shows the transfer in progress for a call to the users involved in it.
+func GetTransfer(c *gin.Context) {
+	callId, authUser, ok := transferParams(c)
+	if !ok {
+		return
+	}
+	t, found := wsHub.Transfer(callId)
+	if !found {
+		transferError(c, callId, ws.ErrNoTransfer)
+		return
+	}
+	if authUser.Id != t.FromId && authUser.Id != t.PartyId && authUser.Id != t.TargetId {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not part of this transfer"})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// UpdateTransfer applies :action to a call's transfer: the target accepts or declines, and the
+// transferor completes (attended only) or cancels.
+func UpdateTransfer(c *gin.Context) {
+	callId, authUser, ok := transferParams(c)
+	if !ok {
+		return
+	}
+	action := models.TransferAction(c.Param("action"))
+	switch action {
+	case models.TransferAccept, models.TransferDecline, models.TransferComplete, models.TransferCancel:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be accept, decline, complete or cancel"})
+		return
+	}
+	t, err := wsHub.HandleTransfer(authUser.Id, models.TransferRequest{CallId: callId, Action: action})
+	if err != nil {
+		transferError(c, callId, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// transferParams reads the call id and authenticated user for transfer routes, which the target
+// uses before joining the call.
+func transferParams(c *gin.Context) (uint, models.User, bool) {
+	callId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid call id"})
+		return 0, models.User{}, false
+	}
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return 0, models.User{}, false
+	}
+	if wsHub == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "call service unavailable"})
+		return 0, models.User{}, false
+	}
+	return uint(callId), ai.(models.User), true
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/Neb-iyu/facetime-app/backend/database"
 	"github.com/Neb-iyu/facetime-app/backend/models"
@@ -17,14 +18,40 @@ func SetupWSHub(hub *ws.Hub) {
 	wsHub = hub
 }
 
+// GetUsers keeps the user list of older clients: users as in GetUser, in directory search order.
+// The whole directory is no longer listed, so without ?q= it returns the caller's contacts.
 func GetUsers(c *gin.Context) {
-	db := database.Db
-	var users []models.User
-	if result := db.Find(&users); result.Error != nil {
-		log.Printf("Failed to get users from db: %v", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	relation := ""
+	if strings.TrimSpace(c.Query("q")) == "" {
+		relation = "contacts"
+	}
+	page, ok := searchDirectory(c, relation)
+	if !ok {
+		return
+	}
+	ids := make([]uint, len(page.Users))
+	for i, e := range page.Users {
+		ids[i] = e.Id
+	}
+	var found []models.User
+	if err := database.Db.Where("id IN ?", ids).Find(&found).Error; err != nil {
+		log.Printf("Failed to get users from db: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	byId := make(map[uint]models.User, len(found))
+	for _, u := range found {
+		byId[u.Id] = u
+	}
+	users := make([]models.User, 0, len(ids))
+	for _, id := range ids {
+		if u, ok := byId[id]; ok {
+			users = append(users, u)
+		}
+	}
+	if ai, ok := c.Get("authUser"); ok {
+		redactPresence(ai.(models.User).Id, users)
+	}
 	c.JSON(http.StatusOK, users)
 }
 
@@ -56,6 +83,11 @@ func GetUser(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	if ai, ok := c.Get("authUser"); ok {
+		users := []models.User{user}
+		redactPresence(ai.(models.User).Id, users)
+		user = users[0]
+	}
 	c.JSON(http.StatusOK, user)
 }
 
@@ -146,74 +178,59 @@ func UpdateMeProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, authUser)
 }
 
+// GetContacts lists a user's contacts (?favorites=true for favorites only). Contact lists are
+// private, so only "me" or the caller's own id is accepted.
 func GetContacts(c *gin.Context) {
-	// support /users/:id/contacts with :id == "me"
-	idParam := c.Param("id")
-	var uid uint64
-	if idParam == "me" {
-		ai, ok := c.Get("authUser")
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-			return
-		}
-		authUser := ai.(models.User)
-		uid = uint64(authUser.Id)
-	} else {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+	if idParam := c.Param("id"); idParam != "me" {
 		parsed, err := strconv.ParseUint(idParam, 10, 32)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 			return
 		}
-		uid = parsed
+		if uint(parsed) != authUser.Id {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
 	}
 
-	db := database.Db
+	query := database.Db.Joins("JOIN user_contacts ON user_contacts.contact_id = users.id").
+		Where("user_contacts.user_id = ?", authUser.Id)
+	if c.Query("favorites") == "true" {
+		query = query.Where("user_contacts.favorite = ?", true)
+	}
 	var contacts []models.User
-	if result := db.Where("user_id = ?", uid).Find(&contacts); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": result.Error.Error()})
+	if err := query.Order("users.name").Find(&contacts).Error; err != nil {
+		log.Printf("GetContacts: failed to load contacts of user %d: %v", authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load contacts"})
 		return
 	}
+	redactPresence(authUser.Id, contacts)
 	c.JSON(http.StatusOK, contacts)
 }
 
-func AddContact(c *gin.Context) {
-	var contact models.UserContact
-	if err := c.ShouldBindJSON(&contact); err != nil {
-		log.Printf("Failed to bind user contact JSON: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// ensure owner is authenticated user if not provided
-	if contact.UserId == 0 {
-		if ai, ok := c.Get("authUser"); ok {
-			authUser := ai.(models.User)
-			contact.UserId = authUser.Id
-		}
-	}
-
-	db := database.Db
-	if result := db.Create(&contact); result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": result.Error.Error()})
-		return
-	}
-	c.JSON(http.StatusOK, contact)
-}
-
 //GetUserHistory takes the user's id and returns its call history in JSON format
 
 //ws functions
 
-// GetOnlineUsers serializes the online users in JSON format and sends them to client
+// GetOnlineUsers serializes the online contacts of the authenticated user in JSON format and sends them to client
 func GetOnlineUsers(c *gin.Context) {
-	/*userId, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		log.Printf("Failed to bind id JSON: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
 		return
-	}*/
-
-	onlineUsers := wsHub.GetOnlineUsers()
+	}
+	onlineUsers := make([]models.UserStatusMessage, 0)
+	for _, st := range wsHub.VisibleStatuses(ai.(models.User).Id) {
+		if st.Status == models.Online {
+			onlineUsers = append(onlineUsers, st)
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"online_users": onlineUsers})
 }
 
@@ -225,6 +242,15 @@ func CheckUserStatus(c *gin.Context) {
 		return
 	}
 
-	status := wsHub.CheckUserStatus(uint(userID))
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	status, visible := wsHub.StatusFor(ai.(models.User).Id, uint(userID))
+	if !visible {
+		c.JSON(http.StatusNotFound, gin.H{"error": "status not available"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"status": status})
 }
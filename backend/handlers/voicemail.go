@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// StartVoicemail starts recording the caller's camera and microphone as a voicemail for a callee
+// who hasn't answered. The callee may be left out of a 1:1 call.
+func StartVoicemail(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	var req models.VoicemailRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.UserId == 0 {
+		session.Mu.RLock()
+		if len(session.Call.CalleeIds) == 1 {
+			req.UserId = session.Call.CalleeIds[0]
+		}
+		session.Mu.RUnlock()
+	}
+	if req.UserId == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "userId is required in a group call"})
+		return
+	}
+	vm, err := session.StartVoicemail(authUser.Id, req.UserId)
+	if err != nil {
+		voicemailError(c, session.ID, err)
+		return
+	}
+	c.JSON(http.StatusCreated, vm)
+}
+
+// StopVoicemail finishes the caller's voicemail and delivers it.
+func StopVoicemail(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	vm, err := session.StopVoicemail(authUser.Id)
+	if err != nil {
+		voicemailError(c, session.ID, err)
+		return
+	}
+	c.JSON(http.StatusOK, vm)
+}
+
+// CancelVoicemail discards the voicemail the caller is recording.
+func CancelVoicemail(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	if err := session.CancelVoicemail(authUser.Id); err != nil {
+		voicemailError(c, session.ID, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func voicemailError(c *gin.Context, callID uint, err error) {
+	switch {
+	case errors.Is(err, ws.ErrVoicemailActive):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrNoVoicemail):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrVoicemailNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrVoicemailEmpty):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		log.Printf("voicemail error for call %d: %v", callID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "voicemail failed"})
+	}
+}
+
+// GetVoicemails lists the authenticated user's voicemail, newest first, with the unread count
+// (?unread=true for unread only, ?before=<id>, ?limit=).
+func GetVoicemails(c *gin.Context) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	authUser := ai.(models.User)
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+
+	inbox := func() *gorm.DB {
+		return database.Db.Model(&models.Voicemail{}).Where("to_id = ? AND end_time IS NOT NULL", authUser.Id)
+	}
+	list := models.VoicemailList{Voicemails: []models.Voicemail{}}
+	if err := inbox().Where("read_at IS NULL").Count(&list.Unread).Error; err != nil {
+		log.Printf("voicemail count error for user %d: %v", authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query voicemail"})
+		return
+	}
+	q := inbox()
+	if c.Query("unread") == "true" {
+		q = q.Where("read_at IS NULL")
+	}
+	if before, _ := strconv.ParseUint(c.Query("before"), 10, 32); before > 0 {
+		q = q.Where("id < ?", before)
+	}
+	if err := q.Order("id DESC").Limit(limit).Find(&list.Voicemails).Error; err != nil {
+		log.Printf("voicemail query error for user %d: %v", authUser.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query voicemail"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetVoicemail returns one voicemail to its sender or recipient.
+func GetVoicemail(c *gin.Context) {
+	vm, _, ok := findVoicemail(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, vm)
+}
+
+// PlayVoicemail streams a voicemail file (?kind=audio|video, video when there is one) and marks
+// it read for the recipient. Range requests are honoured for seeking.
+func PlayVoicemail(c *gin.Context) {
+	vm, authUser, ok := findVoicemail(c)
+	if !ok {
+		return
+	}
+	kind := c.Query("kind")
+	if kind == "" {
+		kind = "audio"
+		if vm.VideoPath != "" {
+			kind = "video"
+		}
+	}
+	var path string
+	switch kind {
+	case "audio":
+		path = vm.AudioPath
+	case "video":
+		path = vm.VideoPath
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be audio or video"})
+		return
+	}
+	if path == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "voicemail has no " + kind})
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "voicemail file missing"})
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read voicemail"})
+		return
+	}
+	if vm.ToId == authUser.Id && vm.ReadAt == nil {
+		database.Db.Model(&vm).Update("read_at", time.Now())
+	}
+
+	c.Header("Content-Type", recordingContentType(path))
+	if c.Query("download") != "" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("voicemail-%d%s", vm.Id, filepath.Ext(path))))
+	}
+	http.ServeContent(c.Writer, c.Request, filepath.Base(path), fi.ModTime(), f)
+}
+
+// MarkVoicemailRead marks a voicemail read without playing it; recipient only.
+func MarkVoicemailRead(c *gin.Context) {
+	vm, authUser, ok := findVoicemail(c)
+	if !ok {
+		return
+	}
+	if vm.ToId != authUser.Id {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the recipient can mark voicemail read"})
+		return
+	}
+	if vm.ReadAt == nil {
+		now := time.Now()
+		vm.ReadAt = &now
+		if err := database.Db.Model(&vm).Update("read_at", now).Error; err != nil {
+			log.Printf("mark voicemail %d read error: %v", vm.Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update voicemail"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, vm)
+}
+
+// DeleteVoicemail deletes a voicemail and its files; recipient only.
+func DeleteVoicemail(c *gin.Context) {
+	vm, authUser, ok := findVoicemail(c)
+	if !ok {
+		return
+	}
+	if vm.ToId != authUser.Id {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the recipient can delete voicemail"})
+		return
+	}
+	if err := ws.DeleteVoicemail(vm); err != nil {
+		log.Printf("delete voicemail %d error: %v", vm.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete voicemail"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// findVoicemail loads the finished voicemail :id for its sender or recipient.
+// It writes the error response itself and returns ok=false when the request should stop.
+func findVoicemail(c *gin.Context) (models.Voicemail, models.User, bool) {
+	var vm models.Voicemail
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return vm, models.User{}, false
+	}
+	authUser := ai.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid voicemail id"})
+		return vm, authUser, false
+	}
+	if err := database.Db.First(&vm, id).Error; err != nil || vm.EndTime == nil ||
+		(vm.ToId != authUser.Id && vm.FromId != authUser.Id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "voicemail not found"})
+		return vm, authUser, false
+	}
+	return vm, authUser, true
+}
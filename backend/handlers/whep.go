@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/utils"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// WHEPView implements the WHEP egress endpoint. Invited users may always watch; anyone else
+// needs a viewer link signed by the host (see CreateViewerLink). ?tracks=a,b limits the
+// subscription to the given published track ids.
+func WHEPView(c *gin.Context) {
+	session, authUser, ok := sdpCallSession(c)
+	if !ok {
+		return
+	}
+	if !session.CanJoin(authUser.Id) {
+		if err := utils.VerifySignedPath(c.Request.URL.Path, c.Query("expires"), c.Query("sig")); err != nil {
+			c.String(http.StatusForbidden, "viewer link required: "+err.Error())
+			return
+		}
+	}
+	offer, ok := readSDPOffer(c)
+	if !ok {
+		return
+	}
+
+	var trackIDs []string
+	if t := c.Query("tracks"); t != "" {
+		trackIDs = strings.Split(t, ",")
+	}
+	viewer, answer, err := session.AddWHEPViewer(authUser.Id, offer, trackIDs)
+	if errors.Is(err, ws.ErrNoTracksToView) {
+		c.String(http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("WHEP view error for call %d: %v", session.ID, err)
+		c.String(http.StatusBadRequest, "could not negotiate offer")
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/calls/%d/whep/%s", session.ID, viewer.ID))
+	c.Data(http.StatusCreated, "application/sdp", []byte(answer))
+}
+
+// WHEPStop disconnects a WHEP viewer.
+func WHEPStop(c *gin.Context) {
+	session, authUser, ok := sdpCallSession(c)
+	if !ok {
+		return
+	}
+	viewer, ok := session.GetWHEPViewer(c.Param("resourceId"))
+	if !ok || (viewer.UserID != authUser.Id && !session.IsHost(authUser.Id)) {
+		c.String(http.StatusNotFound, "resource not found")
+		return
+	}
+	if err := session.RemoveWHEPViewer(viewer.ID); err != nil && !errors.Is(err, ws.ErrWHEPResourceNotFound) {
+		log.Printf("WHEP stop error for call %d: %v", session.ID, err)
+	}
+	c.Status(http.StatusOK)
+}
+
+// CreateViewerLink lets the host share a signed WHEP URL with people who aren't invited to the call
+// (?ttl=<seconds>, default 15 minutes).
+func CreateViewerLink(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	if !session.IsHost(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the host can share viewer links"})
+		return
+	}
+	ttl := defaultSignedURLTTL
+	if s := c.Query("ttl"); s != "" {
+		secs, err := strconv.Atoi(s)
+		if err != nil || secs <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ttl"})
+			return
+		}
+		ttl = min(time.Duration(secs)*time.Second, maxSignedURLTTL)
+	}
+	expires := time.Now().Add(ttl)
+	path := fmt.Sprintf("/calls/%d/whep", session.ID)
+	url := fmt.Sprintf("%s?expires=%d&sig=%s", path, expires.Unix(), utils.SignPath(path, expires))
+	c.JSON(http.StatusOK, gin.H{"url": url, "expiresAt": expires})
+}
+
+// GetViewers returns the WHEP viewers of a call; only the host can see them.
+func GetViewers(c *gin.Context) {
+	session, authUser, ok := liveCallSession(c)
+	if !ok {
+		return
+	}
+	if !session.IsHost(authUser.Id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the host can list viewers"})
+		return
+	}
+	viewers := session.Viewers()
+	out := make([]gin.H, 0, len(viewers))
+	for _, v := range viewers {
+		out = append(out, gin.H{"id": v.ID, "userId": v.UserID, "joinedAt": v.JoinedAt})
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(out), "viewers": out})
+}
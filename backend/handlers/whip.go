@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSDPSize caps the size of SDP bodies accepted over HTTP.
+const maxSDPSize = 64 << 10
+
+// readSDPOffer reads an application/sdp request body. It writes the error response itself.
+func readSDPOffer(c *gin.Context) (string, bool) {
+	if !strings.HasPrefix(c.ContentType(), "application/sdp") {
+		c.String(http.StatusUnsupportedMediaType, "content type must be application/sdp")
+		return "", false
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSDPSize))
+	if err != nil || len(body) == 0 {
+		c.String(http.StatusBadRequest, "missing SDP offer")
+		return "", false
+	}
+	return string(body), true
+}
+
+// sdpCallSession resolves :id to a live session for the SDP (WHIP/WHEP) endpoints, which answer
+// with plain-text errors. It writes the error response itself.
+func sdpCallSession(c *gin.Context) (*ws.CallSession, models.User, bool) {
+	ai, ok := c.Get("authUser")
+	if !ok {
+		c.String(http.StatusUnauthorized, "unauthenticated")
+		return nil, models.User{}, false
+	}
+	authUser := ai.(models.User)
+
+	callId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid call id")
+		return nil, authUser, false
+	}
+	if wsHub == nil {
+		c.String(http.StatusServiceUnavailable, "call service unavailable")
+		return nil, authUser, false
+	}
+	session, ok := wsHub.GetCallSession(uint(callId))
+	if !ok {
+		c.String(http.StatusNotFound, "call session not found")
+		return nil, authUser, false
+	}
+	return session, authUser, true
+}
+
+// whipCallSession is sdpCallSession restricted to users invited to the call.
+func whipCallSession(c *gin.Context) (*ws.CallSession, models.User, bool) {
+	session, authUser, ok := sdpCallSession(c)
+	if !ok {
+		return nil, authUser, false
+	}
+	if !session.CanJoin(authUser.Id) {
+		c.String(http.StatusForbidden, "not invited to this call")
+		return nil, authUser, false
+	}
+	return session, authUser, true
+}
+
+// WHIPPublish implements the WHIP ingest endpoint: the body is an SDP offer, the response an SDP
+// answer with a Location header identifying the publishing session for a later DELETE.
+// ?source=screen publishes the encoder's video as a screen share.
+func WHIPPublish(c *gin.Context) {
+	session, authUser, ok := whipCallSession(c)
+	if !ok {
+		return
+	}
+	offer, ok := readSDPOffer(c)
+	if !ok {
+		return
+	}
+
+	source := models.TrackSource(c.Query("source"))
+	if source != "" && source != models.SourceCamera && source != models.SourceScreen {
+		c.String(http.StatusBadRequest, "source must be camera or screen")
+		return
+	}
+
+	pub, answer, err := session.AddWHIPPublisher(authUser.Id, offer, source)
+	if err != nil {
+		log.Printf("WHIP publish error for call %d: %v", session.ID, err)
+		c.String(http.StatusBadRequest, "could not negotiate offer")
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/calls/%d/whip/%s", session.ID, pub.ID))
+	c.Data(http.StatusCreated, "application/sdp", []byte(answer))
+}
+
+// WHIPStop tears down a WHIP publishing session.
+func WHIPStop(c *gin.Context) {
+	session, authUser, ok := whipCallSession(c)
+	if !ok {
+		return
+	}
+	pub, ok := session.GetWHIPPublisher(c.Param("resourceId"))
+	if !ok || pub.UserID != authUser.Id {
+		c.String(http.StatusNotFound, "resource not found")
+		return
+	}
+	if err := session.RemoveWHIPPublisher(pub.ID); err != nil && !errors.Is(err, ws.ErrWHIPResourceNotFound) {
+		log.Printf("WHIP stop error for call %d: %v", session.ID, err)
+	}
+	c.Status(http.StatusOK)
+}
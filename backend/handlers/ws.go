@@ -28,6 +28,12 @@ func WebSocketHandler(hub *ws.Hub) gin.HandlerFunc {
 			return
 		}
 
+		// the WS connection belongs to whoever holds the token, guests included
+		if ai, ok := c.Get("authUser"); !ok || ai.(models.User).Id != uint(userID) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
 		db := database.Db
 		var user models.User
 		if err := db.First(&user, userID).Error; err != nil {
@@ -51,6 +57,10 @@ func WebSocketHandler(hub *ws.Hub) gin.HandlerFunc {
 			Username: user.Name,
 			SessionID: sessionID,
 			IsAuthenticated: true,
+			Guest: user.Guest,
+		}
+		if scope, ok := c.Get("guestScope"); ok {
+			client.GuestScope = scope.(models.GuestScope)
 		}
 
 		hub.Register <- client
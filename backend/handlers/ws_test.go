@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/Neb-iyu/facetime-app/backend/ws"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketKeepsGuestScope(t *testing.T) {
+	testDB(t)
+	guest := models.User{Name: "guest", Guest: true}
+	database.Db.Create(&guest)
+	scope := models.GuestScope{CallId: 7}
+	hub := ws.NewHub()
+
+	r := gin.New()
+	r.GET("/ws", func(c *gin.Context) {
+		c.Set("authUser", guest)
+		c.Set("guestScope", scope)
+	}, WebSocketHandler(hub))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	url := fmt.Sprintf("ws%s/ws?user_id=%d", strings.TrimPrefix(srv.URL, "http"), guest.Id)
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	select {
+	case cl := <-hub.Register:
+		if !cl.Guest || cl.GuestScope != scope {
+			t.Errorf("client guest=%v scope=%+v, want guest in %+v", cl.Guest, cl.GuestScope, scope)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("client was not registered")
+	}
+}
@@ -0,0 +1,182 @@
+package hls
+
+import "encoding/binary"
+
+const (
+	videoTrackID = 1
+	audioTrackID = 2
+
+	videoTimescale = 90000
+	audioTimescale = 48000
+
+	// sample_flags from ISO/IEC 14496-12 8.8.3.1
+	flagsSync    = 0x02000000 // sample_depends_on = 2 (does not depend on others)
+	flagsNonSync = 0x01010000 // sample_depends_on = 1, sample_is_non_sync_sample = 1
+)
+
+// sample is one access unit (video) or Opus packet (audio) in its track's timescale.
+type sample struct {
+	dts      int64
+	duration uint32
+	key      bool
+	data     []byte
+}
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func u64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+
+// box serializes an ISO BMFF box.
+func box(typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	out := make([]byte, 0, size)
+	out = binary.BigEndian.AppendUint32(out, uint32(size))
+	out = append(out, typ...)
+	for _, p := range payload {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// fullBox serializes a box carrying a version and flags header.
+func fullBox(typ string, version byte, flags uint32, payload ...[]byte) []byte {
+	header := u32(uint32(version)<<24 | flags&0xffffff)
+	return box(typ, append([][]byte{header}, payload...)...)
+}
+
+var identityMatrix = []byte{
+	0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0x40, 0, 0, 0,
+}
+
+// initSegment builds the ftyp+moov header referenced by EXT-X-MAP. sps/pps are nil for audio-only
+// streams; an SPS too short to carry profile and level leaves the video track out.
+func initSegment(sps, pps []byte, hasAudio bool) []byte {
+	var traks [][]byte
+	var trexs [][]byte
+	if len(sps) >= minSPSLength {
+		traks = append(traks, videoTrak(sps, pps))
+		trexs = append(trexs, trex(videoTrackID))
+	}
+	if hasAudio {
+		traks = append(traks, audioTrak())
+		trexs = append(trexs, trex(audioTrackID))
+	}
+
+	mvhd := fullBox("mvhd", 0, 0,
+		u32(0), u32(0), // creation, modification time
+		u32(1000), u32(0), // timescale, duration
+		u32(0x00010000), u16(0x0100), make([]byte, 10), // rate, volume, reserved
+		identityMatrix, make([]byte, 24), // pre_defined
+		u32(audioTrackID+1), // next_track_ID
+	)
+	moov := box("moov", append(append([][]byte{mvhd}, traks...), box("mvex", trexs...))...)
+	ftyp := box("ftyp", []byte("iso5"), u32(1), []byte("iso5"), []byte("iso6"), []byte("mp41"))
+	return append(ftyp, moov...)
+}
+
+func trex(trackID uint32) []byte {
+	return fullBox("trex", 0, 0, u32(trackID), u32(1), u32(0), u32(0), u32(0))
+}
+
+func tkhd(trackID uint32, volume uint16, width, height int) []byte {
+	return fullBox("tkhd", 0, 3, // enabled | in_movie
+		u32(0), u32(0), u32(trackID), u32(0), u32(0), // times, id, reserved, duration
+		make([]byte, 8), u16(0), u16(0), u16(volume), u16(0), // reserved, layer, group, volume, reserved
+		identityMatrix, u32(uint32(width)<<16), u32(uint32(height)<<16),
+	)
+}
+
+func mdia(timescale uint32, handler, name string, minfHeader, stsdEntry []byte) []byte {
+	mdhd := fullBox("mdhd", 0, 0, u32(0), u32(0), u32(timescale), u32(0), u16(0x55c4), u16(0)) // language "und"
+	hdlr := fullBox("hdlr", 0, 0, u32(0), []byte(handler), make([]byte, 12), []byte(name+"\x00"))
+	dinf := box("dinf", fullBox("dref", 0, 0, u32(1), fullBox("url ", 0, 1)))
+	stbl := box("stbl",
+		fullBox("stsd", 0, 0, u32(1), stsdEntry),
+		fullBox("stts", 0, 0, u32(0)),
+		fullBox("stsc", 0, 0, u32(0)),
+		fullBox("stsz", 0, 0, u32(0), u32(0)),
+		fullBox("stco", 0, 0, u32(0)),
+	)
+	return box("mdia", mdhd, hdlr, box("minf", minfHeader, dinf, stbl))
+}
+
+func videoTrak(sps, pps []byte) []byte {
+	width, height := spsDimensions(sps)
+	avcC := box("avcC",
+		[]byte{1, sps[1], sps[2], sps[3], 0xff, 0xe1}, // version, profile, compat, level, 4-byte lengths, 1 SPS
+		u16(uint16(len(sps))), sps,
+		[]byte{1}, u16(uint16(len(pps))), pps,
+	)
+	avc1 := box("avc1",
+		make([]byte, 6), u16(1), // reserved, data_reference_index
+		make([]byte, 16), u16(uint16(width)), u16(uint16(height)),
+		u32(0x00480000), u32(0x00480000), u32(0), u16(1), // 72dpi, reserved, frame_count
+		make([]byte, 32), u16(0x0018), u16(0xffff), // compressorname, depth, pre_defined
+		avcC,
+	)
+	vmhd := fullBox("vmhd", 0, 1, make([]byte, 8))
+	return box("trak", tkhd(videoTrackID, 0, width, height), mdia(videoTimescale, "vide", "VideoHandler", vmhd, avc1))
+}
+
+func audioTrak() []byte {
+	dOps := box("dOps",
+		[]byte{0, 2}, u16(312), u32(audioTimescale), u16(0), []byte{0}, // version, channels, pre-skip, rate, gain, mapping family
+	)
+	opus := box("Opus",
+		make([]byte, 6), u16(1), // reserved, data_reference_index
+		make([]byte, 8), u16(2), u16(16), u16(0), u16(0), // reserved, channelcount, samplesize
+		u32(audioTimescale<<16),
+		dOps,
+	)
+	smhd := fullBox("smhd", 0, 0, u16(0), u16(0))
+	return box("trak", tkhd(audioTrackID, 0x0100, 0, 0), mdia(audioTimescale, "soun", "SoundHandler", smhd, opus))
+}
+
+// mediaSegment builds a moof+mdat fragment holding the given samples of each track.
+func mediaSegment(seq uint32, video, audio []sample) []byte {
+	build := func(videoOffset, audioOffset uint32) []byte {
+		trafs := [][]byte{fullBox("mfhd", 0, 0, u32(seq))}
+		if len(video) > 0 {
+			trafs = append(trafs, traf(videoTrackID, videoOffset, video))
+		}
+		if len(audio) > 0 {
+			trafs = append(trafs, traf(audioTrackID, audioOffset, audio))
+		}
+		return box("moof", trafs...)
+	}
+
+	var mdat [][]byte
+	videoSize := 0
+	for _, s := range video {
+		mdat = append(mdat, s.data)
+		videoSize += len(s.data)
+	}
+	for _, s := range audio {
+		mdat = append(mdat, s.data)
+	}
+
+	// offsets are relative to the start of moof, whose size doesn't depend on their values
+	moofSize := uint32(len(build(0, 0)))
+	moof := build(moofSize+8, moofSize+8+uint32(videoSize))
+	return append(moof, box("mdat", mdat...)...)
+}
+
+func traf(trackID, dataOffset uint32, samples []sample) []byte {
+	tfhd := fullBox("tfhd", 0, 0x020000, u32(trackID)) // default-base-is-moof
+	tfdt := fullBox("tfdt", 1, 0, u64(uint64(samples[0].dts)))
+	entries := [][]byte{u32(uint32(len(samples))), u32(dataOffset)}
+	for _, s := range samples {
+		flags := uint32(flagsNonSync)
+		if s.key || trackID == audioTrackID {
+			flags = flagsSync
+		}
+		entries = append(entries, u32(s.duration), u32(uint32(len(s.data))), u32(flags))
+	}
+	trun := fullBox("trun", 0, 0x000701, entries...) // data offset, sample duration, size and flags present
+	return box("traf", tfhd, tfdt, trun)
+}
@@ -0,0 +1,141 @@
+package hls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// sps640x480 is a baseline profile SPS for a 640x480 stream.
+var sps640x480 = []byte{0x67, 0x42, 0xc0, 0x1e, 0xd9, 0x00, 0xa0, 0x3d, 0xa1, 0x00, 0x00, 0x03,
+	0x00, 0x01, 0x00, 0x00, 0x03, 0x00, 0x3c, 0x8f, 0x16, 0x2e, 0x48}
+
+var pps = []byte{0x68, 0xce, 0x3c, 0x80}
+
+// children splits buf into its top-level boxes, keeping the first of each type, and fails on a
+// size that doesn't fit.
+func children(t *testing.T, buf []byte) map[string][]byte {
+	t.Helper()
+	out := make(map[string][]byte)
+	for len(buf) > 0 {
+		if len(buf) < 8 {
+			t.Fatalf("trailing %d bytes", len(buf))
+		}
+		size := int(binary.BigEndian.Uint32(buf))
+		if size < 8 || size > len(buf) {
+			t.Fatalf("box %q has size %d with %d bytes left", buf[4:8], size, len(buf))
+		}
+		if _, ok := out[string(buf[4:8])]; !ok {
+			out[string(buf[4:8])] = buf[8:size]
+		}
+		buf = buf[size:]
+	}
+	return out
+}
+
+// find descends through the boxes named by path.
+func find(t *testing.T, buf []byte, path ...string) []byte {
+	t.Helper()
+	for _, typ := range path {
+		b, ok := children(t, buf)[typ]
+		if !ok {
+			t.Fatalf("no %s box on path %v", typ, path)
+		}
+		buf = b
+	}
+	return buf
+}
+
+func TestBox(t *testing.T) {
+	got := box("free", []byte{1, 2}, []byte{3})
+	want := []byte{0, 0, 0, 11, 'f', 'r', 'e', 'e', 1, 2, 3}
+	if !bytes.Equal(got, want) {
+		t.Errorf("box = %v, want %v", got, want)
+	}
+	got = fullBox("test", 1, 0x000701)
+	want = []byte{0, 0, 0, 12, 't', 'e', 's', 't', 1, 0, 0x07, 0x01}
+	if !bytes.Equal(got, want) {
+		t.Errorf("fullBox = %v, want %v", got, want)
+	}
+}
+
+func TestInitSegment(t *testing.T) {
+	init := initSegment(sps640x480, pps, true)
+	top := children(t, init)
+	if _, ok := top["ftyp"]; !ok {
+		t.Fatal("missing ftyp")
+	}
+	moov := find(t, init, "moov")
+	var traks int
+	for b := moov; len(b) > 0; b = b[binary.BigEndian.Uint32(b):] {
+		if string(b[4:8]) == "trak" {
+			traks++
+		}
+	}
+	if traks != 2 {
+		t.Fatalf("got %d traks, want 2", traks)
+	}
+
+	tkhd := find(t, moov, "trak", "tkhd")
+	width := binary.BigEndian.Uint32(tkhd[len(tkhd)-8:]) >> 16
+	height := binary.BigEndian.Uint32(tkhd[len(tkhd)-4:]) >> 16
+	if width != 640 || height != 480 {
+		t.Errorf("tkhd size %dx%d, want 640x480", width, height)
+	}
+	// stsd: version/flags, entry count, then the avc1 entry with its 78-byte visual header
+	stsd := find(t, moov, "trak", "mdia", "minf", "stbl", "stsd")
+	avcC := find(t, stsd[8+8+78:], "avcC")
+	if !bytes.Equal(avcC[:4], []byte{1, 0x42, 0xc0, 0x1e}) {
+		t.Errorf("avcC header %x, want profile, compatibility and level from the SPS", avcC[:4])
+	}
+}
+
+func TestInitSegmentShortSPS(t *testing.T) {
+	for _, sps := range [][]byte{nil, {0x67}, {0x67, 0x42, 0xc0}} {
+		moov := find(t, initSegment(sps, pps, true), "moov")
+		var traks int
+		for b := moov; len(b) > 0; b = b[binary.BigEndian.Uint32(b):] {
+			if string(b[4:8]) == "trak" {
+				traks++
+			}
+		}
+		if traks != 1 {
+			t.Errorf("SPS %x: got %d traks, want only audio", sps, traks)
+		}
+	}
+}
+
+func TestMediaSegmentOffsets(t *testing.T) {
+	video := []sample{{dts: 0, duration: 3000, key: true, data: []byte("key")}, {dts: 3000, duration: 3000, data: []byte("delta")}}
+	audio := []sample{{dts: 0, duration: 960, data: []byte("opus")}}
+	seg := mediaSegment(7, video, audio)
+
+	top := children(t, seg)
+	moofSize := 8 + len(top["moof"])
+	if got := string(top["mdat"]); got != "keydeltaopus" {
+		t.Fatalf("mdat = %q", got)
+	}
+	if seq := binary.BigEndian.Uint32(find(t, top["moof"], "mfhd")[4:]); seq != 7 {
+		t.Errorf("sequence %d, want 7", seq)
+	}
+
+	// each trun's data offset, counted from the start of moof, must point at the track's samples
+	moof := top["moof"]
+	want := map[uint32]string{videoTrackID: "key", audioTrackID: "opus"}
+	for b := moof; len(b) > 0; b = b[binary.BigEndian.Uint32(b):] {
+		if string(b[4:8]) != "traf" {
+			continue
+		}
+		traf := b[8:binary.BigEndian.Uint32(b)]
+		id := binary.BigEndian.Uint32(find(t, traf, "tfhd")[4:])
+		trun := find(t, traf, "trun")
+		offset := int(binary.BigEndian.Uint32(trun[8:]))
+		first := int(binary.BigEndian.Uint32(trun[16:])) // duration, then size of the first sample
+		if got := string(seg[offset : offset+first]); got != want[id] {
+			t.Errorf("track %d: data at offset %d is %q, want %q", id, offset, got, want[id])
+		}
+		if offset < moofSize+8 {
+			t.Errorf("track %d: offset %d points inside moof", id, offset)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package hls
+
+import "errors"
+
+const (
+	naluTypeIDR = 5
+	naluTypeSPS = 7
+	naluTypePPS = 8
+	naluTypeAUD = 9
+
+	// minSPSLength covers the NAL header and the profile, compatibility and level bytes.
+	minSPSLength = 4
+)
+
+var errShortSPS = errors.New("truncated SPS")
+
+// bitReader reads an RBSP bit by bit, as needed for the Exp-Golomb coded SPS fields.
+type bitReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *bitReader) bit() (uint32, error) {
+	if r.pos >= len(r.buf)*8 {
+		return 0, errShortSPS
+	}
+	b := (r.buf[r.pos/8] >> (7 - r.pos%8)) & 1
+	r.pos++
+	return uint32(b), nil
+}
+
+func (r *bitReader) bits(n int) (uint32, error) {
+	var v uint32
+	for i := 0; i < n; i++ {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | b
+	}
+	return v, nil
+}
+
+func (r *bitReader) ue() (uint32, error) {
+	zeros := 0
+	for {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		if b == 1 {
+			break
+		}
+		zeros++
+		if zeros > 31 {
+			return 0, errShortSPS
+		}
+	}
+	v, err := r.bits(zeros)
+	return (1<<zeros - 1) + v, err
+}
+
+func (r *bitReader) se() (int32, error) {
+	v, err := r.ue()
+	if v%2 == 1 {
+		return int32(v/2 + 1), err
+	}
+	return -int32(v / 2), err
+}
+
+// unescapeRBSP strips the emulation prevention bytes (00 00 03) from a NAL unit.
+func unescapeRBSP(nalu []byte) []byte {
+	out := make([]byte, 0, len(nalu))
+	zeros := 0
+	for _, b := range nalu {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// spsDimensions returns the cropped picture size described by an SPS NAL unit, or 0x0 if it can't be parsed.
+func spsDimensions(sps []byte) (width, height int) {
+	w, h, err := parseSPS(sps)
+	if err != nil {
+		return 0, 0
+	}
+	return w, h
+}
+
+// parseSPS follows ITU-T H.264 7.3.2.1.1 far enough to reach the frame size and cropping.
+func parseSPS(sps []byte) (int, int, error) {
+	if len(sps) < minSPSLength {
+		return 0, 0, errShortSPS
+	}
+	r := &bitReader{buf: unescapeRBSP(sps[1:])}
+	profile, _ := r.bits(8)
+	// skip constraint flags and level, then seq_parameter_set_id
+	r.bits(16)
+	if _, err := r.ue(); err != nil {
+		return 0, 0, err
+	}
+
+	chromaFormat := uint32(1)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		var err error
+		if chromaFormat, err = r.ue(); err != nil {
+			return 0, 0, err
+		}
+		if chromaFormat == 3 {
+			r.bit() // separate_colour_plane_flag
+		}
+		r.ue()  // bit_depth_luma_minus8
+		r.ue()  // bit_depth_chroma_minus8
+		r.bit() // qpprime_y_zero_transform_bypass_flag
+		present, err := r.bit()
+		if err != nil {
+			return 0, 0, err
+		}
+		if present == 1 {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if f, _ := r.bit(); f == 1 {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					skipScalingList(r, size)
+				}
+			}
+		}
+	}
+
+	r.ue() // log2_max_frame_num_minus4
+	pocType, err := r.ue()
+	if err != nil {
+		return 0, 0, err
+	}
+	switch pocType {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.bit() // delta_pic_order_always_zero_flag
+		r.se()  // offset_for_non_ref_pic
+		r.se()  // offset_for_top_to_bottom_field
+		n, err := r.ue()
+		if err != nil {
+			return 0, 0, err
+		}
+		for i := uint32(0); i < n; i++ {
+			r.se()
+		}
+	}
+	r.ue()  // max_num_ref_frames
+	r.bit() // gaps_in_frame_num_value_allowed_flag
+	widthMbs, _ := r.ue()
+	heightMaps, _ := r.ue()
+	frameMbsOnly, err := r.bit()
+	if err != nil {
+		return 0, 0, err
+	}
+	if frameMbsOnly == 0 {
+		r.bit() // mb_adaptive_frame_field_flag
+	}
+	r.bit() // direct_8x8_inference_flag
+
+	width := int(widthMbs+1) * 16
+	height := int(2-frameMbsOnly) * int(heightMaps+1) * 16
+
+	cropping, err := r.bit()
+	if err != nil {
+		return 0, 0, err
+	}
+	if cropping == 1 {
+		left, _ := r.ue()
+		right, _ := r.ue()
+		top, _ := r.ue()
+		bottom, err := r.ue()
+		if err != nil {
+			return 0, 0, err
+		}
+		unitX, unitY := 1, int(2-frameMbsOnly)
+		switch chromaFormat {
+		case 1:
+			unitX, unitY = 2, 2*unitY
+		case 2:
+			unitX = 2
+		}
+		width -= int(left+right) * unitX
+		height -= int(top+bottom) * unitY
+	}
+	return width, height, nil
+}
+
+func skipScalingList(r *bitReader, size int) {
+	last, next := int32(8), int32(8)
+	for j := 0; j < size; j++ {
+		if next != 0 {
+			delta, err := r.se()
+			if err != nil {
+				return
+			}
+			next = (last + delta + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
@@ -0,0 +1,385 @@
+// Package hls packages passthrough H.264 and Opus RTP into a rolling fMP4 HLS playlist.
+// Segments are kept in memory; the HTTP layer serves them through Muxer.File.
+package hls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// PlaylistName is the media playlist players should load.
+const PlaylistName = "index.m3u8"
+
+const (
+	targetDuration  = 2 * time.Second
+	maxSegmentAudio = 3 * targetDuration // cut anyway if video stalls so audio doesn't pile up
+	playlistLength  = 6                  // segments kept in the rolling playlist
+
+	defaultVideoDuration = videoTimescale / 30
+	defaultAudioDuration = audioTimescale / 50 // 20ms Opus frames
+)
+
+type segment struct {
+	name          string
+	init          string
+	duration      time.Duration
+	discontinuity bool
+	data          []byte
+}
+
+// rtpClock turns 32-bit RTP timestamps into a monotonic decode time. The first packet is placed at
+// its wall-clock offset from the muxer start so tracks (and re-attached tracks) line up.
+type rtpClock struct {
+	started bool
+	last    uint32
+	ext     int64
+}
+
+func (c *rtpClock) dts(ts uint32, since time.Duration, rate int64) int64 {
+	if !c.started {
+		c.started = true
+		c.last = ts
+		c.ext = int64(since.Seconds() * float64(rate))
+		return c.ext
+	}
+	c.ext += int64(int32(ts - c.last))
+	c.last = ts
+	return c.ext
+}
+
+type videoState struct {
+	clock    rtpClock
+	depack   codecs.H264Packet
+	ts       uint32
+	nalus    [][]byte
+	key      bool
+	sps, pps []byte // latest in-band parameter sets
+	pending  *sample
+}
+
+type audioState struct {
+	clock   rtpClock
+	pending *sample
+}
+
+// Muxer builds the rolling playlist for one video and/or one audio track.
+type Muxer struct {
+	mu       sync.Mutex
+	start    time.Time
+	hasVideo bool
+	hasAudio bool
+	video    videoState
+	audio    audioState
+
+	sps, pps      []byte // parameter sets of the current init segment
+	initName      string
+	initCount     int
+	inits         map[string][]byte
+	discontinuity bool
+
+	videoSamples []sample
+	audioSamples []sample
+	segments     []*segment
+	nextSegment  uint32
+	mediaSeq     int
+	discSeq      int
+}
+
+// NewMuxer creates a muxer for a stream with the given tracks. Video must be H.264, audio Opus.
+func NewMuxer(hasVideo, hasAudio bool) *Muxer {
+	m := &Muxer{
+		start:    time.Now(),
+		hasVideo: hasVideo,
+		hasAudio: hasAudio,
+		inits:    make(map[string][]byte),
+	}
+	m.video.depack.IsAVC = true
+	if !hasVideo {
+		m.setInit(nil, nil)
+	}
+	return m
+}
+
+// input adapts one track of the muxer to media.Writer so it can be attached as a ForwardTrack sink.
+type input struct {
+	m     *Muxer
+	video bool
+}
+
+func (in *input) WriteRTP(pkt *rtp.Packet) error {
+	if in.video {
+		in.m.writeVideo(pkt)
+	} else {
+		in.m.writeAudio(pkt)
+	}
+	return nil
+}
+
+func (in *input) Close() error { return nil }
+
+// VideoInput returns the writer for H.264 RTP. Calling it again (e.g. when the publisher republishes)
+// resets the depacketizer and timestamp base.
+func (m *Muxer) VideoInput() media.Writer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.video.clock = rtpClock{}
+	m.video.depack = codecs.H264Packet{IsAVC: true}
+	m.video.nalus = nil
+	return &input{m: m, video: true}
+}
+
+// AudioInput returns the writer for Opus RTP; see VideoInput.
+func (m *Muxer) AudioInput() media.Writer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audio.clock = rtpClock{}
+	return &input{m: m}
+}
+
+func (m *Muxer) writeVideo(pkt *rtp.Packet) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v := &m.video
+	if len(v.nalus) > 0 && pkt.Timestamp != v.ts {
+		// the marker bit of the previous frame was lost
+		m.finishAccessUnit()
+	}
+	v.ts = pkt.Timestamp
+
+	payload, err := v.depack.Unmarshal(pkt.Payload)
+	if err != nil {
+		return
+	}
+	for len(payload) >= 4 {
+		n := int(binary.BigEndian.Uint32(payload))
+		if n == 0 || n > len(payload)-4 {
+			break
+		}
+		nalu := payload[4 : 4+n]
+		payload = payload[4+n:]
+		switch nalu[0] & 0x1f {
+		case naluTypeSPS:
+			if len(nalu) >= minSPSLength { // avcC copies profile, compatibility and level from it
+				v.sps = append([]byte(nil), nalu...)
+			}
+		case naluTypePPS:
+			v.pps = append([]byte(nil), nalu...)
+		case naluTypeAUD:
+		case naluTypeIDR:
+			v.key = true
+			v.nalus = append(v.nalus, nalu)
+		default:
+			v.nalus = append(v.nalus, nalu)
+		}
+	}
+	if pkt.Marker {
+		m.finishAccessUnit()
+	}
+}
+
+func (m *Muxer) finishAccessUnit() {
+	v := &m.video
+	nalus, key := v.nalus, v.key
+	v.nalus, v.key = nil, false
+	if len(nalus) == 0 {
+		return
+	}
+	dts := v.clock.dts(v.ts, time.Since(m.start), videoTimescale)
+
+	newParams := false
+	if key {
+		if v.sps == nil || v.pps == nil {
+			return
+		}
+		newParams = !bytes.Equal(v.sps, m.sps) || !bytes.Equal(v.pps, m.pps)
+	} else if m.sps == nil {
+		return // wait for the first keyframe
+	}
+
+	var data []byte
+	for _, n := range nalus {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(n)))
+		data = append(data, n...)
+	}
+	s := sample{dts: dts, key: key, data: data}
+
+	if p := v.pending; p != nil {
+		if s.dts <= p.dts {
+			s.dts = p.dts + defaultVideoDuration
+		}
+		p.duration = uint32(s.dts - p.dts)
+		m.videoSamples = append(m.videoSamples, *p)
+	}
+	if key && (newParams || m.duration() >= targetDuration) {
+		m.cutSegment()
+	}
+	if newParams {
+		m.setInit(v.sps, v.pps)
+	}
+	v.pending = &s
+}
+
+func (m *Muxer) writeAudio(pkt *rtp.Packet) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.initName == "" || len(pkt.Payload) == 0 {
+		return // video hasn't produced a keyframe yet
+	}
+	a := &m.audio
+	s := sample{
+		dts:  a.clock.dts(pkt.Timestamp, time.Since(m.start), audioTimescale),
+		key:  true,
+		data: append([]byte(nil), pkt.Payload...),
+	}
+	if p := a.pending; p != nil {
+		if s.dts <= p.dts {
+			s.dts = p.dts + defaultAudioDuration
+		}
+		p.duration = uint32(s.dts - p.dts)
+		m.audioSamples = append(m.audioSamples, *p)
+	}
+	a.pending = &s
+
+	if (!m.hasVideo && m.duration() >= targetDuration) || m.audioDuration() >= maxSegmentAudio {
+		m.cutSegment()
+	}
+}
+
+// setInit starts a new init segment; later segments are marked as a discontinuity.
+func (m *Muxer) setInit(sps, pps []byte) {
+	m.sps, m.pps = sps, pps
+	m.discontinuity = m.initName != ""
+	m.initName = fmt.Sprintf("init%d.mp4", m.initCount)
+	m.initCount++
+	m.inits[m.initName] = initSegment(sps, pps, m.hasAudio)
+}
+
+// duration returns the length of the segment being built, measured on its main track.
+func (m *Muxer) duration() time.Duration {
+	if !m.hasVideo {
+		return m.audioDuration()
+	}
+	var d int64
+	for _, s := range m.videoSamples {
+		d += int64(s.duration)
+	}
+	return time.Duration(d) * time.Second / videoTimescale
+}
+
+func (m *Muxer) audioDuration() time.Duration {
+	var d int64
+	for _, s := range m.audioSamples {
+		d += int64(s.duration)
+	}
+	return time.Duration(d) * time.Second / audioTimescale
+}
+
+func (m *Muxer) cutSegment() {
+	if len(m.videoSamples) == 0 && len(m.audioSamples) == 0 {
+		return
+	}
+	d := m.duration()
+	if len(m.videoSamples) == 0 {
+		d = m.audioDuration()
+	}
+	seg := &segment{
+		name:          fmt.Sprintf("segment%d.m4s", m.nextSegment),
+		init:          m.initName,
+		duration:      d,
+		discontinuity: m.discontinuity,
+		data:          mediaSegment(m.nextSegment+1, m.videoSamples, m.audioSamples),
+	}
+	m.nextSegment++
+	m.discontinuity = false
+	m.videoSamples, m.audioSamples = nil, nil
+
+	m.segments = append(m.segments, seg)
+	for len(m.segments) > playlistLength {
+		if m.segments[0].discontinuity {
+			m.discSeq++
+		}
+		m.segments = m.segments[1:]
+		m.mediaSeq++
+	}
+
+	// drop init segments no longer referenced by the playlist
+	used := map[string]bool{m.initName: true}
+	for _, s := range m.segments {
+		used[s.init] = true
+	}
+	for name := range m.inits {
+		if !used[name] {
+			delete(m.inits, name)
+		}
+	}
+}
+
+// Playlist renders the rolling media playlist. It reports false until the first segment is ready.
+func (m *Muxer) Playlist() ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.segments) == 0 {
+		return nil, false
+	}
+
+	target := 1
+	for _, s := range m.segments {
+		target = max(target, int(math.Ceil(s.duration.Seconds())))
+	}
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-INDEPENDENT-SEGMENTS\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", target)
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", m.mediaSeq)
+	fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", m.discSeq)
+	init := ""
+	for _, s := range m.segments {
+		if s.discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		if s.init != init {
+			fmt.Fprintf(&b, "#EXT-X-MAP:URI=%q\n", s.init)
+			init = s.init
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", s.duration.Seconds(), s.name)
+	}
+	return b.Bytes(), true
+}
+
+// File returns the playlist, an init segment or a media segment by name.
+func (m *Muxer) File(name string) ([]byte, bool) {
+	if name == PlaylistName {
+		return m.Playlist()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if data, ok := m.inits[name]; ok {
+		return data, true
+	}
+	for _, s := range m.segments {
+		if s.name == name {
+			return s.data, true
+		}
+	}
+	return nil, false
+}
+
+// ContentType returns the MIME type to serve a muxer file with.
+func ContentType(name string) string {
+	switch path.Ext(name) {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".m4s":
+		return "video/iso.segment"
+	default:
+		return "video/mp4"
+	}
+}
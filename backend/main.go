@@ -2,6 +2,9 @@ package main
 
 import (
 	"log"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/Neb-iyu/facetime-app/backend/database"
 	"github.com/Neb-iyu/facetime-app/backend/handlers"
@@ -11,23 +14,43 @@ import (
 
 func main() {
 	database.InitSqliteDB()
+	handlers.StartRecordingRetention(recordingRetention())
 	wsHub := ws.NewHub()
 	r := setupRouter(wsHub)
+	handlers.StartMeetingReminders(30 * time.Second)
+	wsHub.StartPresenceSweep(30 * time.Second)
 	if err := r.Run(); err != nil {
 		log.Fatal("Failed to run server:", err)
 	}
 }
 
+// recordingRetention reads RECORDING_RETENTION_DAYS (default 30).
+func recordingRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("RECORDING_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
 func setupRouter(hub *ws.Hub) *gin.Engine {
 	router := gin.Default()
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
-	router.Static("/uploads", "./uploads")
+	// only avatars are public; recordings go through the access-controlled routes below
+	router.Static("/uploads/avatars", "./uploads/avatars")
 
 	// public auth
 	router.POST("/auth/login", handlers.Login)
 	router.POST("/auth/register", handlers.Register)
 
+	// signed, expiring recording links (signature checked instead of bearer token)
+	router.GET("/recordings/:id/stream", handlers.StreamSignedRecording)
+
+	// signed guest invites, redeemed for a guest token scoped to one room or call
+	router.POST("/guests/rooms/:slug", handlers.RedeemRoomGuestInvite)
+	router.POST("/guests/calls/:id", handlers.RedeemCallGuestInvite)
+
 	// protected
 	auth := router.Group("/")
 	auth.Use(handlers.AuthMiddleware())
@@ -39,12 +62,19 @@ func setupRouter(hub *ws.Hub) *gin.Engine {
 		users := auth.Group("/users")
 		{
 			users.GET("", handlers.GetUsers)           // GET /users
+			users.GET("/search", handlers.SearchUsers) // GET /users/search
 			users.GET("/:id", handlers.GetUser)        // GET /users/:id
 			users.POST("", handlers.AddUser)           // POST /users
 			users.PUT("/:id", handlers.UpdateUser)     // PUT /users/:id (admin or self)
 			users.PUT("/me", handlers.UpdateMeProfile) // PUT /users/me (authenticated user)
 			users.GET("/:id/contacts", handlers.GetContacts)
 			users.GET("/:id/history", handlers.GetUserHistory)
+			users.PUT("/me/presence", handlers.SetPresence)
+			users.DELETE("/me/presence", handlers.ClearPresence)
+			users.PUT("/me/privacy", handlers.SetPrivacy)
+			users.GET("/me/forwarding", handlers.GetForwardingRules)
+			users.PUT("/me/forwarding/:condition", handlers.SetForwardingRule)
+			users.DELETE("/me/forwarding/:condition", handlers.DeleteForwardingRule)
 			//users.POST("/:id/avatar", handlers.UploadAvatar) // optional upload endpoint
 		}
 
@@ -56,7 +86,58 @@ func setupRouter(hub *ws.Hub) *gin.Engine {
 		}
 
 		// contacts
-		auth.POST("/contacts", handlers.AddContact)
+		contacts := auth.Group("/contacts")
+		{
+			contacts.POST("", handlers.SendContactRequest)
+			contacts.DELETE("/:userId", handlers.RemoveContact)
+			contacts.PUT("/:userId/favorite", handlers.SetFavorite)
+			contacts.GET("/requests", handlers.GetContactRequests)
+			contacts.POST("/requests/:requestId/accept", handlers.AcceptContactRequest)
+			contacts.POST("/requests/:requestId/decline", handlers.DeclineContactRequest)
+			contacts.DELETE("/requests/:requestId", handlers.CancelContactRequest)
+			contacts.GET("/blocked", handlers.GetBlockedUsers)
+			contacts.POST("/blocked", handlers.BlockUser)
+			contacts.DELETE("/blocked/:userId", handlers.UnblockUser)
+			contacts.GET("/groups", handlers.GetContactGroups)
+			contacts.POST("/groups", handlers.CreateContactGroup)
+			contacts.GET("/groups/:groupId", handlers.GetContactGroup)
+			contacts.PUT("/groups/:groupId", handlers.UpdateContactGroup)
+			contacts.DELETE("/groups/:groupId", handlers.DeleteContactGroup)
+		}
+
+		// messages
+		messages := auth.Group("/messages")
+		{
+			messages.POST("", handlers.SendMessage)
+			messages.GET("/conversations", handlers.GetConversations)
+			messages.GET("/direct/:userId", handlers.GetDirectMessages)
+			messages.POST("/direct/:userId/read", handlers.MarkDirectRead)
+		}
+
+		// scheduled meetings
+		meetings := auth.Group("/meetings")
+		{
+			meetings.POST("", handlers.CreateMeeting)
+			meetings.GET("", handlers.GetMeetings)
+			meetings.GET("/:id", handlers.GetMeeting)
+			meetings.PUT("/:id", handlers.UpdateMeeting)
+			meetings.DELETE("/:id", handlers.DeleteMeeting)
+			meetings.POST("/:id/start", handlers.StartMeeting)
+			meetings.GET("/:id/ics", handlers.GetMeetingICS)
+			meetings.GET("/:id/invites/:userId", handlers.GetMeetingInvite)
+		}
+
+		// rooms with reusable join links
+		rooms := auth.Group("/rooms")
+		{
+			rooms.POST("", handlers.CreateRoom)
+			rooms.GET("", handlers.GetMyRooms)
+			rooms.GET("/:slug", handlers.GetRoom)
+			rooms.PATCH("/:slug", handlers.UpdateRoom)
+			rooms.DELETE("/:slug", handlers.DeleteRoom)
+			rooms.POST("/:slug/join", handlers.JoinRoom)
+			rooms.POST("/:slug/guest-invites", handlers.CreateRoomGuestInvite)
+		}
 
 		// calls
 		calls := auth.Group("/calls")
@@ -68,12 +149,92 @@ func setupRouter(hub *ws.Hub) *gin.Engine {
 			calls.POST("/:id/leave", handlers.LeaveCall)
 			calls.POST("/:id/end", handlers.EndCall)
 			calls.GET("/:id/participants", handlers.GetCallParticipants)
+			calls.GET("/:id/stats", handlers.GetCallStats)
+			calls.POST("/:id/recording/start", handlers.StartRecording)
+			calls.POST("/:id/recording/stop", handlers.StopRecording)
+			calls.GET("/:id/recordings", handlers.GetCallRecordings)
+			calls.POST("/:id/voicemail", handlers.StartVoicemail)
+			calls.POST("/:id/voicemail/stop", handlers.StopVoicemail)
+			calls.DELETE("/:id/voicemail", handlers.CancelVoicemail)
+			calls.GET("/:id/messages", handlers.GetCallMessages)
+
+			// WHIP ingest from external encoders
+			calls.POST("/:id/whip", handlers.WHIPPublish)
+			calls.DELETE("/:id/whip/:resourceId", handlers.WHIPStop)
+
+			// WHEP egress for view-only subscribers
+			calls.POST("/:id/whep", handlers.WHEPView)
+			calls.DELETE("/:id/whep/:resourceId", handlers.WHEPStop)
+			calls.POST("/:id/whep/link", handlers.CreateViewerLink)
+			calls.GET("/:id/viewers", handlers.GetViewers)
+
+			// HLS output for large audiences
+			calls.POST("/:id/live/start", handlers.StartLiveStream)
+			calls.POST("/:id/live/stop", handlers.StopLiveStream)
+			calls.GET("/:id/live/:file", handlers.GetLiveStreamFile)
+
+			// server-side audio mixing for low-bandwidth participants
+			calls.PUT("/:id/audio-mixing", handlers.SetCallAudioMixing)
+			calls.PUT("/:id/audio-mixing/me", handlers.SetMyAudioMixing)
+
+			// screen sharing
+			calls.GET("/:id/screen-shares", handlers.GetScreenShares)
+			calls.PUT("/:id/screen-shares/limit", handlers.SetScreenShareLimit)
+			calls.DELETE("/:id/screen-shares/:userId", handlers.StopScreenShare)
+
+			// roles and moderation
+			calls.GET("/:id/roles", handlers.GetCallRoles)
+			calls.POST("/:id/moderate", handlers.ModerateCall)
+			calls.GET("/:id/lobby", handlers.GetLobby)
+			calls.POST("/:id/guest-invites", handlers.CreateCallGuestInvite)
+
+			// breakout rooms
+			calls.POST("/:id/breakouts", handlers.OpenBreakouts)
+			calls.GET("/:id/breakouts", handlers.GetBreakouts)
+			calls.POST("/:id/breakouts/assign", handlers.AssignBreakout)
+			calls.POST("/:id/breakouts/return", handlers.ReturnFromBreakout)
+			calls.POST("/:id/breakouts/broadcast", handlers.BroadcastToBreakouts)
+			calls.DELETE("/:id/breakouts", handlers.CloseBreakouts)
+
+			// hands, reactions and polls
+			calls.GET("/:id/hands", handlers.GetHandQueue)
+			calls.POST("/:id/hand", handlers.SetHand)
+			calls.POST("/:id/reactions", handlers.SendReaction)
+			calls.GET("/:id/polls", handlers.GetPolls)
+			calls.POST("/:id/polls", handlers.CreatePoll)
+			calls.POST("/:id/polls/:pollId/vote", handlers.VotePoll)
+			calls.POST("/:id/polls/:pollId/close", handlers.ClosePoll)
+
+			// transfer to another user
+			calls.POST("/:id/transfer", handlers.StartTransfer)
+			calls.GET("/:id/transfer", handlers.GetTransfer)
+			calls.POST("/:id/transfer/:action", handlers.UpdateTransfer)
 
 			// media control
 			calls.POST("/:id/publish", handlers.PublishTrack)
 			calls.POST("/:id/renegotiate", handlers.Renegotiate)
 		}
 
+		// recordings
+		recordings := auth.Group("/recordings")
+		{
+			recordings.GET("", handlers.GetRecordings)
+			recordings.GET("/:id", handlers.GetRecording)
+			recordings.GET("/:id/download", handlers.DownloadRecording)
+			recordings.POST("/:id/url", handlers.CreateRecordingURL)
+			recordings.DELETE("/:id", handlers.DeleteRecording)
+		}
+
+		// voicemail
+		voicemails := auth.Group("/voicemails")
+		{
+			voicemails.GET("", handlers.GetVoicemails)
+			voicemails.GET("/:id", handlers.GetVoicemail)
+			voicemails.GET("/:id/play", handlers.PlayVoicemail)
+			voicemails.POST("/:id/read", handlers.MarkVoicemailRead)
+			voicemails.DELETE("/:id", handlers.DeleteVoicemail)
+		}
+
 		// websocket upgrade - token validated during upgrade
 		auth.GET("/ws", handlers.WebSocketHandler(hub))
 	}
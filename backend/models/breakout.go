@@ -0,0 +1,31 @@
+package models
+
+// BreakoutRoom is one sub-group of a call; it runs as a call of its own.
+type BreakoutRoom struct {
+	CallId       uint   `json:"callId"`
+	Name         string `json:"name"`
+	Participants []uint `json:"participants"`
+}
+
+// BreakoutsMessage describes the breakout rooms of a call.
+type BreakoutsMessage struct {
+	CallId      uint           `json:"callId"` // the main call
+	AllowChoice bool           `json:"allowChoice"`
+	Rooms       []BreakoutRoom `json:"rooms"`
+}
+
+// BreakoutMoveMessage tells a participant to renegotiate media with another call of the same
+// meeting: a breakout room or, when CallId equals ParentCallId, the main call.
+type BreakoutMoveMessage struct {
+	ParentCallId uint   `json:"parentCallId"`
+	CallId       uint   `json:"callId"`
+	Name         string `json:"name,omitempty"`
+}
+
+// BreakoutNoticeMessage is a countdown or announcement sent to the main call and every room.
+type BreakoutNoticeMessage struct {
+	CallId  uint   `json:"callId"` // the main call
+	From    uint   `json:"from"`
+	Message string `json:"message,omitempty"`
+	Seconds int    `json:"seconds,omitempty"` // time left before the rooms close
+}
@@ -12,16 +12,25 @@ const (
 	Ongoing CallStatus = "ongoing"
 	Ended   CallStatus = "ended"
 	Missed  CallStatus = "missed"
+
+	// history-only statuses
+	Transferred CallStatus = "transferred"
+	Forwarded   CallStatus = "forwarded"
 )
 
 type Call struct {
-	Id        uint            `json:"id" gorm:"primaryKey;column:id"`
-	CallerId  uint            `json:"callerId" gorm:"column:caller_id"`
-	CalleeIds []uint          `json:"calleeIds" gorm:"-"`
-	StartTime time.Time       `json:"startTime" gorm:"column:start_time"`
-	EndTime   *time.Time      `json:"endTime,omitempty" gorm:"column:end_time"`
-	Status    CallStatus      `json:"status" gorm:"column:status"`
-	Offer     json.RawMessage `json:"offer,omitempty" gorm:"type:text;column:offer"`   // store JSON text
-	Answer    json.RawMessage `json:"answer,omitempty" gorm:"type:text;column:answer"` // store JSON text
+	Id              uint            `json:"id" gorm:"primaryKey;column:id"`
+	CallerId        uint            `json:"callerId" gorm:"column:caller_id"`
+	CalleeIds       []uint          `json:"calleeIds" gorm:"-"`
+	StartTime       time.Time       `json:"startTime" gorm:"column:start_time"`
+	EndTime         *time.Time      `json:"endTime,omitempty" gorm:"column:end_time"`
+	Status          CallStatus      `json:"status" gorm:"column:status"`
+	Offer           json.RawMessage `json:"offer,omitempty" gorm:"type:text;column:offer"`   // store JSON text
+	Answer          json.RawMessage `json:"answer,omitempty" gorm:"type:text;column:answer"` // store JSON text
+	Recordings      []Recording     `json:"recordings,omitempty" gorm:"-"`
+	Polls           []Poll          `json:"polls,omitempty" gorm:"-"`
+	RoomId          *uint           `json:"roomId,omitempty" gorm:"column:room_id;index"`             // set for calls held in a room
+	ParentId        *uint           `json:"parentId,omitempty" gorm:"column:parent_id"`               // main call of a breakout room
+	TransferredFrom *uint           `json:"transferredFrom,omitempty" gorm:"column:transferred_from"` // call this one took over by transfer
 	// midToBId left out of DB mapping (in-memory only)
 }
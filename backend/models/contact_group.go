@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ContactGroup is a named set of the owner's contacts that can be called together.
+type ContactGroup struct {
+	Id        uint      `json:"id" gorm:"primaryKey;column:id"`
+	OwnerId   uint      `json:"ownerId" gorm:"column:owner_id;index"`
+	Name      string    `json:"name" gorm:"column:name"`
+	MemberIds []uint    `json:"memberIds" gorm:"-"`
+	CreatedAt time.Time `json:"createdAt" gorm:"column:created_at"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"column:updated_at"`
+}
+
+// ContactGroupMember links a contact to a group.
+type ContactGroupMember struct {
+	Id      uint `json:"id" gorm:"primaryKey;column:id"`
+	GroupId uint `json:"groupId" gorm:"column:group_id;index"`
+	UserId  uint `json:"userId" gorm:"column:user_id;index"`
+}
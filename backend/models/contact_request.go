@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+type ContactRequestStatus string
+
+const (
+	ContactRequestPending   ContactRequestStatus = "pending"
+	ContactRequestAccepted  ContactRequestStatus = "accepted"
+	ContactRequestDeclined  ContactRequestStatus = "declined"
+	ContactRequestCancelled ContactRequestStatus = "cancelled"
+)
+
+// ContactRequest asks ToId to become a contact of FromId. Accepting it makes them mutual contacts.
+// Only one request from FromId to ToId can be pending at a time.
+type ContactRequest struct {
+	Id          uint                 `json:"id" gorm:"primaryKey;column:id"`
+	FromId      uint                 `json:"fromId" gorm:"column:from_id;index;uniqueIndex:idx_pending_request,where:status = 'pending'"`
+	ToId        uint                 `json:"toId" gorm:"column:to_id;index;uniqueIndex:idx_pending_request"`
+	Message     string               `json:"message,omitempty" gorm:"column:message"`
+	Status      ContactRequestStatus `json:"status" gorm:"column:status"`
+	CreatedAt   time.Time            `json:"createdAt" gorm:"column:created_at"`
+	RespondedAt *time.Time           `json:"respondedAt,omitempty" gorm:"column:responded_at"`
+}
+
+// UserBlock keeps BlockedId from calling, messaging or seeing the presence of UserId.
+type UserBlock struct {
+	Id        uint      `json:"id" gorm:"primaryKey;column:id"`
+	UserId    uint      `json:"userId" gorm:"column:user_id;uniqueIndex:idx_block_pair"`
+	BlockedId uint      `json:"blockedId" gorm:"column:blocked_id;uniqueIndex:idx_block_pair"`
+	CreatedAt time.Time `json:"createdAt" gorm:"column:created_at"`
+}
+
+// ContactRemovedMessage tells a user that UserId removed them as a contact.
+type ContactRemovedMessage struct {
+	UserId uint `json:"userId"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// DirectoryEntry is the public profile returned by directory search. Presence fields are only
+// filled in for mutual contacts.
+type DirectoryEntry struct {
+	Id          uint       `json:"id"`
+	Name        string     `json:"name"`
+	AvatarUrl   *string    `json:"avatarUrl,omitempty"`
+	Contact     bool       `json:"contact"`
+	Favorite    bool       `json:"favorite,omitempty"`
+	Status      UserStatus `json:"status,omitempty"`
+	Presence    Presence   `json:"presence,omitempty"`
+	StatusText  string     `json:"statusText,omitempty"`
+	StatusEmoji string     `json:"statusEmoji,omitempty"`
+}
+
+// DirectoryPage is one page of search results. NextCursor is empty on the last page.
+type DirectoryPage struct {
+	Users      []DirectoryEntry `json:"users"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
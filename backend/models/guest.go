@@ -0,0 +1,15 @@
+package models
+
+// GuestScope is what a guest token grants access to: a single room or a single call.
+type GuestScope struct {
+	RoomId uint `json:"roomId,omitempty"`
+	CallId uint `json:"callId,omitempty"`
+}
+
+// CallParticipant is one entry of a live call's participant list.
+type CallParticipant struct {
+	UserId uint     `json:"userId"`
+	Name   string   `json:"name"`
+	Role   CallRole `json:"role"`
+	Guest  bool     `json:"guest"`
+}
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// HandRaise is one entry in a call's raised-hand queue.
+type HandRaise struct {
+	UserId   uint      `json:"userId"`
+	RaisedAt time.Time `json:"raisedAt"`
+}
+
+// HandRequest raises or lowers a hand. UserId defaults to the sender; hosts and moderators can
+// set it to lower someone else's hand.
+type HandRequest struct {
+	CallId uint `json:"callId" binding:"required"`
+	UserId uint `json:"userId,omitempty"`
+	Raised bool `json:"raised"`
+}
+
+// HandMessage tells a call that a hand went up or down.
+type HandMessage struct {
+	CallId    uint `json:"callId"`
+	UserId    uint `json:"userId"`
+	Raised    bool `json:"raised"`
+	LoweredBy uint `json:"loweredBy,omitempty"` // set when a moderator lowered it
+}
+
+// HandQueueMessage is the ordered raised-hand queue, sent to hosts and moderators.
+type HandQueueMessage struct {
+	CallId uint        `json:"callId"`
+	Queue  []HandRaise `json:"queue"`
+}
+
+// ReactionMessage is an ephemeral emoji reaction; reactions are relayed, never stored.
+type ReactionMessage struct {
+	CallId uint      `json:"callId"`
+	UserId uint      `json:"userId"`
+	Emoji  string    `json:"emoji"`
+	Time   time.Time `json:"time"`
+}
+
+// Poll is a question asked during a call. Its options carry the final tallies once it is closed.
+type Poll struct {
+	Id          uint         `json:"id" gorm:"primaryKey;column:id"`
+	CallId      uint         `json:"callId" gorm:"column:call_id;index"`
+	CreatorId   uint         `json:"creatorId" gorm:"column:creator_id"`
+	Question    string       `json:"question" gorm:"column:question"`
+	MultiChoice bool         `json:"multiChoice" gorm:"column:multi_choice"`
+	Closed      bool         `json:"closed" gorm:"column:closed"`
+	Voters      int          `json:"voters" gorm:"column:voters"`
+	Options     []PollOption `json:"options" gorm:"foreignKey:PollId"`
+	CreatedAt   time.Time    `json:"createdAt" gorm:"column:created_at"`
+	ClosedAt    *time.Time   `json:"closedAt,omitempty" gorm:"column:closed_at"`
+}
+
+type PollOption struct {
+	Id       uint   `json:"id" gorm:"primaryKey;column:id"`
+	PollId   uint   `json:"pollId" gorm:"column:poll_id;index"`
+	Position int    `json:"position" gorm:"column:position"`
+	Text     string `json:"text" gorm:"column:text"`
+	Votes    int    `json:"votes" gorm:"column:votes"`
+}
+
+// PollVote records one user's choice; multi-choice polls have a row per chosen option.
+type PollVote struct {
+	Id        uint      `json:"id" gorm:"primaryKey;column:id"`
+	PollId    uint      `json:"pollId" gorm:"column:poll_id;index"`
+	OptionId  uint      `json:"optionId" gorm:"column:option_id"`
+	UserId    uint      `json:"userId" gorm:"column:user_id"`
+	CreatedAt time.Time `json:"createdAt" gorm:"column:created_at"`
+}
+
+// PollRequest creates a poll, over REST or as the "poll_create" WS message.
+type PollRequest struct {
+	CallId      uint     `json:"callId"`
+	Question    string   `json:"question" binding:"required"`
+	Options     []string `json:"options" binding:"required"`
+	MultiChoice bool     `json:"multiChoice"`
+}
+
+// PollVoteRequest votes in, or closes, a poll. A new vote replaces the sender's earlier one.
+type PollVoteRequest struct {
+	CallId    uint   `json:"callId"`
+	PollId    uint   `json:"pollId"`
+	OptionIds []uint `json:"optionIds"`
+}
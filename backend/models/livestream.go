@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// LiveStreamStatusMessage announces that a call's HLS output started or stopped.
+type LiveStreamStatusMessage struct {
+	CallId      uint      `json:"callId"`
+	UserId      uint      `json:"userId"` // who started or stopped the stream
+	PublisherId uint      `json:"publisherId"`
+	StartedAt   time.Time `json:"startedAt"`
+	PlaylistUrl string    `json:"playlistUrl"`
+}
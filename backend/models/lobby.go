@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// LobbyEntry is someone waiting, without media, for a host or moderator to let them in.
+type LobbyEntry struct {
+	CallId      uint      `json:"callId"`
+	UserId      uint      `json:"userId"`
+	Name        string    `json:"name"`
+	Guest       bool      `json:"guest"`
+	RequestedAt time.Time `json:"requestedAt"`
+}
+
+// LobbyDecisionMessage tells a waiting user whether they were let in.
+type LobbyDecisionMessage struct {
+	CallId  uint `json:"callId"`
+	ActorId uint `json:"actorId,omitempty"` // 0 when admitted by an auto-admit rule or admit_all
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Meeting is a call planned ahead. Starting it rings the invitees like an ad-hoc call.
+type Meeting struct {
+	Id              uint       `json:"id" gorm:"primaryKey;column:id"`
+	HostId          uint       `json:"hostId" gorm:"column:host_id;index"`
+	Title           string     `json:"title" gorm:"column:title"`
+	Description     string     `json:"description" gorm:"column:description"`
+	StartTime       time.Time  `json:"startTime" gorm:"column:start_time"` // first occurrence
+	DurationMinutes int        `json:"durationMinutes" gorm:"column:duration_minutes"`
+	Recurrence      string     `json:"recurrence,omitempty" gorm:"column:recurrence"` // RFC 5545 RRULE value, e.g. FREQ=WEEKLY;BYDAY=MO,WE
+	TimeZone        string     `json:"timeZone,omitempty" gorm:"column:time_zone"`    // IANA zone the recurrence follows; empty for UTC
+	ReminderMinutes int        `json:"reminderMinutes" gorm:"column:reminder_minutes"`
+	Sequence        int        `json:"sequence" gorm:"column:sequence"` // bumped on every change, for calendar clients
+	CallId          *uint      `json:"callId,omitempty" gorm:"column:call_id"`
+	InviteeIds      []uint     `json:"inviteeIds" gorm:"-"`
+	NextStart       *time.Time `json:"nextStart,omitempty" gorm:"-"`
+	RemindedFor     *time.Time `json:"-" gorm:"column:reminded_for"` // occurrence the last reminder was sent for
+	CreatedAt       time.Time  `json:"createdAt" gorm:"column:created_at"`
+	UpdatedAt       time.Time  `json:"updatedAt" gorm:"column:updated_at"`
+}
+
+// MeetingInvitee links an invited user to a meeting.
+type MeetingInvitee struct {
+	Id        uint `json:"id" gorm:"primaryKey;column:id"`
+	MeetingId uint `json:"meetingId" gorm:"column:meeting_id;index"`
+	UserId    uint `json:"userId" gorm:"column:user_id;index"`
+}
+
+// MeetingNotification is pushed to the host and invitees when they are invited, when a meeting
+// is about to start and when it is cancelled.
+type MeetingNotification struct {
+	MeetingId uint      `json:"meetingId"`
+	Title     string    `json:"title"`
+	StartTime time.Time `json:"startTime"` // the occurrence concerned
+	HostId    uint      `json:"hostId"`
+	CallId    uint      `json:"callId,omitempty"`
+	Calendar  string    `json:"calendar,omitempty"` // iCalendar CANCEL for cancellations, to remove the event from calendars
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Message is a text message. Direct messages set RecipientId; call messages set CallId and go to
+// everyone in the call, unless RecipientId is also set for a private in-call message.
+// DeliveredAt and ReadAt are the recipient's receipts and are only tracked for direct messages.
+type Message struct {
+	Id          uint       `json:"id" gorm:"primaryKey;column:id"`
+	SenderId    uint       `json:"senderId" gorm:"column:sender_id;index"`
+	RecipientId *uint      `json:"recipientId,omitempty" gorm:"column:recipient_id;index"`
+	CallId      *uint      `json:"callId,omitempty" gorm:"column:call_id;index"`
+	Body        string     `json:"body" gorm:"type:text;column:body"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"column:created_at"`
+	DeliveredAt *time.Time `json:"deliveredAt,omitempty" gorm:"column:delivered_at"`
+	ReadAt      *time.Time `json:"readAt,omitempty" gorm:"column:read_at"`
+}
+
+// IsDirect reports whether m belongs to a one-to-one conversation rather than a call.
+func (m Message) IsDirect() bool {
+	return m.CallId == nil && m.RecipientId != nil
+}
+
+// Conversation summarizes a direct or call conversation for the conversation list.
+type Conversation struct {
+	PeerId      *uint   `json:"peerId,omitempty"` // direct conversations
+	CallId      *uint   `json:"callId,omitempty"` // call conversations
+	LastMessage Message `json:"lastMessage"`
+	Unread      int64   `json:"unread"`
+}
+
+// MessageReceiptMessage tells a sender that their direct messages were delivered or read.
+type MessageReceiptMessage struct {
+	UserId     uint      `json:"userId"` // the recipient
+	MessageIds []uint    `json:"messageIds"`
+	At         time.Time `json:"at"`
+}
+
+// DataChannelMessage is the JSON framing of everything relayed over participant data channels.
+// Clients set Type, To and Payload; the server fills in the rest.
+type DataChannelMessage struct {
+	Id      uint            `json:"id,omitempty"` // Message id, for persisted chat messages
+	Type    string          `json:"type"`
+	From    uint            `json:"from"`
+	To      []uint          `json:"to,omitempty"` // empty broadcasts to the whole call; chat allows at most one
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Time    time.Time       `json:"time"`
+	Error   string          `json:"error,omitempty"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Recording is one media file captured from a single published track of a call.
+type Recording struct {
+	Id        uint       `json:"id" gorm:"primaryKey;column:id"`
+	CallId    uint       `json:"callId" gorm:"column:call_id;index"`
+	UserId    uint       `json:"userId" gorm:"column:user_id"` // publisher of the recorded track
+	StartedBy uint       `json:"startedBy" gorm:"column:started_by"`
+	TrackId   string     `json:"trackId" gorm:"column:track_id"`
+	Kind      string     `json:"kind" gorm:"column:kind"`
+	MimeType  string     `json:"mimeType" gorm:"column:mime_type"`
+	Path      string     `json:"-" gorm:"column:path"`  // file path on disk
+	Url       string     `json:"url" gorm:"column:url"` // authenticated download route
+	Size      int64      `json:"size" gorm:"column:size"`
+	StartTime time.Time  `json:"startTime" gorm:"column:start_time"`
+	EndTime   *time.Time `json:"endTime,omitempty" gorm:"column:end_time"`
+}
+
+type RecordingStatusMessage struct {
+	CallId     uint        `json:"callId"`
+	UserId     uint        `json:"userId"` // who started or stopped the recording
+	StartedAt  time.Time   `json:"startedAt"`
+	Recordings []Recording `json:"recordings,omitempty"`
+}
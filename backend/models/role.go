@@ -0,0 +1,79 @@
+package models
+
+// CallRole is what a participant may do within a call. The caller hosts by default.
+type CallRole string
+
+const (
+	RoleHost        CallRole = "host"
+	RoleModerator   CallRole = "moderator"
+	RoleParticipant CallRole = "participant"
+	RoleViewer      CallRole = "viewer" // receives media but may not publish
+)
+
+// Valid reports whether r is one of the known roles.
+func (r CallRole) Valid() bool {
+	switch r {
+	case RoleHost, RoleModerator, RoleParticipant, RoleViewer:
+		return true
+	}
+	return false
+}
+
+// Rank orders roles so moderators can act on participants but not on the host or each other.
+func (r CallRole) Rank() int {
+	switch r {
+	case RoleHost:
+		return 3
+	case RoleModerator:
+		return 2
+	case RoleParticipant:
+		return 1
+	}
+	return 0
+}
+
+type ModerationAction string
+
+const (
+	ActionRequestMute  ModerationAction = "request_mute"  // ask the target to mute themselves
+	ActionStopMedia    ModerationAction = "stop_media"    // stop forwarding the target's audio or video at the SFU
+	ActionResumeMedia  ModerationAction = "resume_media"  // undo stop_media
+	ActionRemove       ModerationAction = "remove"        // remove the target; they can't rejoin
+	ActionLock         ModerationAction = "lock"          // refuse new joiners
+	ActionUnlock       ModerationAction = "unlock"        // accept new joiners again
+	ActionSetRole      ModerationAction = "set_role"      // make the target a moderator, participant or viewer
+	ActionTransferHost ModerationAction = "transfer_host" // hand the host role to the target
+	ActionEndCall      ModerationAction = "end_call"      // end the call for everyone
+	ActionEnableLobby  ModerationAction = "enable_lobby"  // new joiners wait in the lobby
+	ActionDisableLobby ModerationAction = "disable_lobby" // let new joiners straight in, admitting everyone waiting
+	ActionAdmit        ModerationAction = "admit"         // let the target in from the lobby
+	ActionDeny         ModerationAction = "deny"          // turn the target away from the lobby
+	ActionAdmitAll     ModerationAction = "admit_all"     // let everyone in the lobby in
+)
+
+// ModerationRequest is sent by a host or moderator over WS ("moderate") or REST.
+type ModerationRequest struct {
+	CallId   uint             `json:"callId"`
+	Action   ModerationAction `json:"action" binding:"required"`
+	TargetId uint             `json:"targetId,omitempty"`
+	Kind     string           `json:"kind,omitempty"` // "audio" or "video" for request_mute, stop_media and resume_media
+	Role     CallRole         `json:"role,omitempty"` // for set_role
+}
+
+// ModerationMessage announces a moderation action to the call.
+type ModerationMessage struct {
+	CallId   uint             `json:"callId"`
+	Action   ModerationAction `json:"action"`
+	ActorId  uint             `json:"actorId"`
+	TargetId uint             `json:"targetId,omitempty"`
+	Kind     string           `json:"kind,omitempty"`
+	Role     CallRole         `json:"role,omitempty"`
+}
+
+// CallRolesMessage lists the roles of everyone in a call.
+type CallRolesMessage struct {
+	CallId uint              `json:"callId"`
+	HostId uint              `json:"hostId"`
+	Locked bool              `json:"locked"`
+	Roles  map[uint]CallRole `json:"roles"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Room is a persistent meeting place reachable through the same link every time. Joining it
+// starts a call, or attaches to the one already running.
+type Room struct {
+	Id              uint   `json:"id" gorm:"primaryKey;column:id"`
+	Slug            string `json:"slug" gorm:"uniqueIndex;column:slug"`
+	Name            string `json:"name" gorm:"column:name"`
+	OwnerId         uint   `json:"ownerId" gorm:"column:owner_id;index"` // hosts every call in the room
+	Passcode        string `json:"-" gorm:"column:passcode"`             // bcrypt hash, empty when the room is open
+	HasPasscode     bool   `json:"hasPasscode" gorm:"-"`
+	MaxParticipants int    `json:"maxParticipants" gorm:"column:max_participants"` // 0 means no limit
+	MaxScreenShares int    `json:"maxScreenShares" gorm:"column:max_screen_shares"`
+	AudioMixing     bool   `json:"audioMixing" gorm:"column:audio_mixing"`
+	// joiners wait in the lobby unless an auto-admit rule lets them in
+	Lobby                 bool      `json:"lobby" gorm:"column:lobby"`
+	AutoAdmitContacts     bool      `json:"autoAdmitContacts" gorm:"column:auto_admit_contacts"`         // the owner's contacts
+	AutoAdmitOrganization bool      `json:"autoAdmitOrganization" gorm:"column:auto_admit_organization"` // same email domain as the owner
+	CreatedAt             time.Time `json:"createdAt" gorm:"column:created_at"`
+	UpdatedAt             time.Time `json:"updatedAt" gorm:"column:updated_at"`
+}
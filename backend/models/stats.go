@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+type NetworkQuality string
+
+const (
+	QualityGood NetworkQuality = "good"
+	QualityFair NetworkQuality = "fair"
+	QualityPoor NetworkQuality = "poor"
+)
+
+// TrackStats describes one RTP stream between a participant and the SFU.
+// Direction is "inbound" for media the participant publishes and "outbound" for media it receives.
+type TrackStats struct {
+	TrackId         string  `json:"trackId"`
+	Mid             string  `json:"mid,omitempty"`
+	Kind            string  `json:"kind"`
+	Direction       string  `json:"direction"`
+	PublisherId     uint    `json:"publisherId,omitempty"`
+	Packets         uint64  `json:"packets"`
+	PacketsLost     int64   `json:"packetsLost"`
+	FractionLost    float64 `json:"fractionLost"`
+	JitterMs        float64 `json:"jitterMs"`
+	RttMs           float64 `json:"rttMs"`
+	Bitrate         float64 `json:"bitrate"` // bits per second since the previous sample
+	Frames          uint64  `json:"frames,omitempty"`
+	NackCount       uint32  `json:"nackCount"`
+	PliCount        uint32  `json:"pliCount"`
+	Retransmissions uint64  `json:"retransmissions,omitempty"`
+}
+
+type ParticipantStats struct {
+	UserId           uint           `json:"userId"`
+	Quality          NetworkQuality `json:"quality"`
+	RttMs            float64        `json:"rttMs"`
+	AvailableBitrate float64        `json:"availableBitrate,omitempty"`
+	Tracks           []TrackStats   `json:"tracks"`
+	UpdatedAt        time.Time      `json:"updatedAt"`
+}
+
+type NetworkQualityMessage struct {
+	CallId       uint                    `json:"callId"`
+	Participants map[uint]NetworkQuality `json:"participants"` // userId -> quality
+}
+
+type ViewerCountMessage struct {
+	CallId uint `json:"callId"`
+	Count  int  `json:"count"`
+}
+
+// AudioMixingMessage tells participants whether they receive one mixed audio track instead of one per publisher.
+type AudioMixingMessage struct {
+	CallId    uint `json:"callId"`
+	Enabled   bool `json:"enabled"`
+	WholeCall bool `json:"wholeCall"` // false when only the recipient switched modes
+}
@@ -0,0 +1,35 @@
+package models
+
+// TrackSource says what a published track carries, so layouts can tell a camera from a screen share.
+type TrackSource string
+
+const (
+	SourceCamera TrackSource = "camera"
+	SourceScreen TrackSource = "screen"
+	SourceAudio  TrackSource = "audio"
+)
+
+// Valid reports whether s is one of the known sources.
+func (s TrackSource) Valid() bool {
+	switch s {
+	case SourceCamera, SourceScreen, SourceAudio:
+		return true
+	}
+	return false
+}
+
+// TrackInfo describes a published track to subscribers; it is the value type of the track_map message.
+type TrackInfo struct {
+	PublisherId uint        `json:"publisherId"`
+	TrackId     string      `json:"trackId"`
+	Source      TrackSource `json:"source"`
+}
+
+// ScreenShareMessage announces that a screen share started, stopped or was refused.
+type ScreenShareMessage struct {
+	CallId    uint   `json:"callId"`
+	UserId    uint   `json:"userId"` // the presenter
+	TrackId   string `json:"trackId"`
+	StoppedBy uint   `json:"stoppedBy,omitempty"` // set when someone else ended the share
+	Reason    string `json:"reason,omitempty"`    // set when the share was refused
+}
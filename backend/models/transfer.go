@@ -0,0 +1,91 @@
+package models
+
+import "time"
+
+// TransferMode says whether the transferring user drops out as soon as the target answers (blind)
+// or talks to the target first and completes the transfer afterwards (attended).
+type TransferMode string
+
+const (
+	TransferBlind    TransferMode = "blind"
+	TransferAttended TransferMode = "attended"
+)
+
+func (m TransferMode) Valid() bool {
+	return m == TransferBlind || m == TransferAttended
+}
+
+type TransferStatus string
+
+const (
+	TransferRinging    TransferStatus = "ringing"
+	TransferConsulting TransferStatus = "consulting" // attended: transferor and target talk, the other party waits
+	TransferCompleted  TransferStatus = "completed"
+	TransferDeclined   TransferStatus = "declined"
+	TransferUnanswered TransferStatus = "unanswered"
+	TransferCancelled  TransferStatus = "cancelled"
+)
+
+// CallTransfer hands the other party of a 1:1 call over to a target user. It is the payload of
+// "call_transfer" messages, sent to all three users whenever the transfer changes status.
+type CallTransfer struct {
+	CallId      uint           `json:"callId"`    // call being transferred
+	NewCallId   uint           `json:"newCallId"` // call with the target; the party moves here
+	Mode        TransferMode   `json:"mode"`
+	FromId      uint           `json:"fromId"`  // user handing the call over
+	PartyId     uint           `json:"partyId"` // user being transferred
+	TargetId    uint           `json:"targetId"`
+	Status      TransferStatus `json:"status"`
+	RequestedAt time.Time      `json:"requestedAt"`
+}
+
+// TransferAction is a step of a transfer requested over the "transfer" WS message.
+type TransferAction string
+
+const (
+	TransferStart    TransferAction = "start"    // transferor
+	TransferAccept   TransferAction = "accept"   // target
+	TransferDecline  TransferAction = "decline"  // target
+	TransferComplete TransferAction = "complete" // transferor, attended only
+	TransferCancel   TransferAction = "cancel"   // transferor
+)
+
+// TransferRequest is the "transfer" WS payload. CallId is always the call being transferred.
+type TransferRequest struct {
+	CallId   uint           `json:"callId"`
+	Action   TransferAction `json:"action" binding:"required"`
+	TargetId uint           `json:"targetId,omitempty"`
+	Mode     TransferMode   `json:"mode,omitempty"`
+}
+
+// ForwardCondition is when a forwarding rule sends incoming calls elsewhere.
+type ForwardCondition string
+
+const (
+	ForwardAlways     ForwardCondition = "always"
+	ForwardBusy       ForwardCondition = "busy"
+	ForwardUnanswered ForwardCondition = "unanswered" // also applies while offline
+)
+
+func (c ForwardCondition) Valid() bool {
+	return c == ForwardAlways || c == ForwardBusy || c == ForwardUnanswered
+}
+
+// CallForwardRule forwards a user's incoming calls to TargetId; a user has at most one rule per condition.
+type CallForwardRule struct {
+	Id           uint             `json:"id" gorm:"primaryKey;column:id"`
+	UserId       uint             `json:"userId" gorm:"column:user_id;uniqueIndex:idx_forward_user_condition"`
+	Condition    ForwardCondition `json:"condition" gorm:"column:condition;uniqueIndex:idx_forward_user_condition"`
+	TargetId     uint             `json:"targetId" gorm:"column:target_id"`
+	AfterSeconds int              `json:"afterSeconds,omitempty" gorm:"column:after_seconds"` // unanswered only
+	CreatedAt    time.Time        `json:"createdAt" gorm:"column:created_at"`
+	UpdatedAt    time.Time        `json:"updatedAt" gorm:"column:updated_at"`
+}
+
+// CallForwardedMessage tells a caller that a callee's rule sent the call on to someone else.
+type CallForwardedMessage struct {
+	CallId      uint             `json:"callId"`
+	UserId      uint             `json:"userId"`
+	ForwardedTo uint             `json:"forwardedTo"`
+	Condition   ForwardCondition `json:"condition"`
+}
@@ -10,6 +10,20 @@ const (
 	Busy    UserStatus = "busy"
 )
 
+// Presence is the availability a user chooses for themselves, on top of the connection status
+// the hub keeps in UserStatus.
+type Presence string
+
+const (
+	Available    Presence = "available"
+	Away         Presence = "away"
+	DoNotDisturb Presence = "dnd"
+)
+
+func (p Presence) Valid() bool {
+	return p == Available || p == Away || p == DoNotDisturb
+}
+
 type User struct {
 	Id        uint       `json:"id" gorm:"primaryKey;column:id"`
 	Name      string     `json:"name" binding:"required" gorm:"uniqueIndex;column:name"`
@@ -18,6 +32,14 @@ type User struct {
 	Status    UserStatus `json:"status" gorm:"column:status;default:'offline'"`
 	AvatarUrl *string    `json:"avatarUrl,omitempty" gorm:"column:avatar_url"`
 	LastSeen  time.Time  `json:"lastSeen" gorm:"column:last_seen"`
+	Guest     bool       `json:"guest" gorm:"column:guest;default:false"` // joined through a guest invite; can't sign in
+
+	Presence          Presence   `json:"presence" gorm:"column:presence;default:'available'"`
+	StatusText        string     `json:"statusText,omitempty" gorm:"column:status_text"`
+	StatusEmoji       string     `json:"statusEmoji,omitempty" gorm:"column:status_emoji"`
+	StatusExpiresAt   *time.Time `json:"statusExpiresAt,omitempty" gorm:"column:status_expires_at"`         // presence and custom status reset after this
+	DndAllowFavorites bool       `json:"dndAllowFavorites" gorm:"column:dnd_allow_favorites;default:false"` // favorites still ring through do-not-disturb
+	HideLastSeen      bool       `json:"hideLastSeen" gorm:"column:hide_last_seen;default:false"`
 }
 
 type UserStatusMessage struct {
@@ -25,5 +47,69 @@ type UserStatusMessage struct {
 	Username string     `json:"username"`
 	Status   UserStatus `json:"status"`
 	LastSeen time.Time  `json:"last_seen,omitempty"`
+
+	Presence        Presence   `json:"presence"`
+	AutoAway        bool       `json:"auto_away,omitempty"` // away because the user went idle, not by choice
+	StatusText      string     `json:"status_text,omitempty"`
+	StatusEmoji     string     `json:"status_emoji,omitempty"`
+	StatusExpiresAt *time.Time `json:"status_expires_at,omitempty"`
+
+	dndAllowFavorites bool
+	hideLastSeen      bool
+}
+
+// PresenceDiffMessage is what changed in the presence a client can see since the last update.
+type PresenceDiffMessage struct {
+	Updated []UserStatusMessage `json:"updated,omitempty"`
+	Removed []uint              `json:"removed,omitempty"` // no longer visible, e.g. removed as a contact
+}
+
+// NewUserStatusMessage builds the presence entry for user with the given connection status.
+func NewUserStatusMessage(user User, status UserStatus) *UserStatusMessage {
+	m := &UserStatusMessage{UserID: user.Id, Username: user.Name, Status: status, LastSeen: user.LastSeen}
+	m.SetPresence(user)
+	return m
+}
+
+// SetPresence copies the user-chosen presence and privacy fields of user.
+func (m *UserStatusMessage) SetPresence(user User) {
+	m.Presence = user.Presence
+	if m.Presence == "" {
+		m.Presence = Available
+	}
+	m.StatusText = user.StatusText
+	m.StatusEmoji = user.StatusEmoji
+	m.StatusExpiresAt = user.StatusExpiresAt
+	m.dndAllowFavorites = user.DndAllowFavorites
+	m.hideLastSeen = user.HideLastSeen
 }
 
+// Public returns the entry as other users see it, without last seen if the user hides it.
+func (m UserStatusMessage) Public() UserStatusMessage {
+	if m.hideLastSeen {
+		m.LastSeen = time.Time{}
+	}
+	return m
+}
+
+// Equal reports whether m and o are the same entry, comparing times by value.
+func (m UserStatusMessage) Equal(o UserStatusMessage) bool {
+	if !m.LastSeen.Equal(o.LastSeen) {
+		return false
+	}
+	if (m.StatusExpiresAt == nil) != (o.StatusExpiresAt == nil) ||
+		(m.StatusExpiresAt != nil && !m.StatusExpiresAt.Equal(*o.StatusExpiresAt)) {
+		return false
+	}
+	m.LastSeen, o.LastSeen = time.Time{}, time.Time{}
+	m.StatusExpiresAt, o.StatusExpiresAt = nil, nil
+	return m == o
+}
+
+// DoNotDisturb reports whether calls should be held back at now, and whether favorites are exempt.
+func (m *UserStatusMessage) DoNotDisturb(now time.Time) (dnd bool, allowFavorites bool) {
+	if m.Presence != DoNotDisturb || (m.StatusExpiresAt != nil && !now.Before(*m.StatusExpiresAt)) {
+		return false, false
+	}
+	return true, m.dndAllowFavorites
+}
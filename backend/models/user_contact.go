@@ -4,5 +4,5 @@ type UserContact struct {
 	Id        uint `json:"id" gorm:"primaryKey;column:id"`
 	UserId    uint `json:"userId" gorm:"column:user_id"`
 	ContactId uint `json:"contactId" gorm:"column:contact_id"`
+	Favorite  bool `json:"favorite" gorm:"column:favorite;default:false"` // rings through do-not-disturb when the user allows it
 }
-
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Voicemail is an audio/video message a caller left for a callee who didn't pick up. It holds
+// at most one audio and one video file.
+type Voicemail struct {
+	Id              uint       `json:"id" gorm:"primaryKey;column:id"`
+	CallId          uint       `json:"callId" gorm:"column:call_id;index"`
+	FromId          uint       `json:"fromId" gorm:"column:from_id;index"`
+	ToId            uint       `json:"toId" gorm:"column:to_id;index"`
+	AudioPath       string     `json:"-" gorm:"column:audio_path"`
+	AudioMimeType   string     `json:"audioMimeType,omitempty" gorm:"column:audio_mime_type"`
+	VideoPath       string     `json:"-" gorm:"column:video_path"`
+	VideoMimeType   string     `json:"videoMimeType,omitempty" gorm:"column:video_mime_type"`
+	Size            int64      `json:"size" gorm:"column:size"`
+	DurationSeconds int        `json:"durationSeconds" gorm:"column:duration_seconds"`
+	StartTime       time.Time  `json:"startTime" gorm:"column:start_time"`
+	EndTime         *time.Time `json:"endTime,omitempty" gorm:"column:end_time"` // nil while being recorded
+	DeliveredAt     *time.Time `json:"deliveredAt,omitempty" gorm:"column:delivered_at"`
+	ReadAt          *time.Time `json:"readAt,omitempty" gorm:"column:read_at"`
+}
+
+// VoicemailList is a page of a user's voicemail with their unread count.
+type VoicemailList struct {
+	Voicemails []Voicemail `json:"voicemails"`
+	Unread     int64       `json:"unread"`
+}
+
+type VoicemailRequest struct {
+	UserId uint `json:"userId"` // callee; may be left out in a 1:1 call
+}
+
+type UnavailableReason string
+
+const (
+	CalleeOffline    UnavailableReason = "offline"
+	CalleeBusy       UnavailableReason = "busy"
+	CalleeUnanswered UnavailableReason = "unanswered"
+)
+
+// CalleeUnavailableMessage tells the caller that UserId can't take the call, so they can leave a
+// voicemail instead.
+type CalleeUnavailableMessage struct {
+	CallId uint              `json:"callId"`
+	UserId uint              `json:"userId"`
+	Reason UnavailableReason `json:"reason"`
+}
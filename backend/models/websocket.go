@@ -6,14 +6,75 @@ type WebSocketMessage struct {
 	Type    WSMessageType
 	Payload interface{} //*UserStatusMessage
 	Time    time.Time
+	From    uint `json:"-"` // sender, set by the server for messages read from a client
 }
 
 type WSMessageType string
 
 const (
-	MessageTypeUserOnline  WSMessageType = "user_online"
-	MessageTypeUserOffline WSMessageType = "user_offline"
-	MessageTypeUserBusy    WSMessageType = "user_busy"
-	MessageTypeUserStatus  WSMessageType = "user_status"
-	MessageTypeUsersList   WSMessageType = "users_list"
+	MessageTypeUserOnline   WSMessageType = "user_online"
+	MessageTypeUserOffline  WSMessageType = "user_offline"
+	MessageTypeUserBusy     WSMessageType = "user_busy"
+	MessageTypeUserStatus   WSMessageType = "user_status"
+	MessageTypeUsersList    WSMessageType = "users_list"
+	MessageTypePresenceDiff WSMessageType = "presence_diff"
+
+	MessageTypeNetworkQuality    WSMessageType = "network_quality"
+	MessageTypeRecordingStarted  WSMessageType = "recording_started"
+	MessageTypeRecordingStopped  WSMessageType = "recording_stopped"
+	MessageTypeViewerCount       WSMessageType = "viewer_count"
+	MessageTypeLiveStreamStarted WSMessageType = "live_stream_started"
+	MessageTypeLiveStreamStopped WSMessageType = "live_stream_stopped"
+	MessageTypeAudioMixing       WSMessageType = "audio_mixing"
+
+	MessageTypeMessageNew       WSMessageType = "message_new"
+	MessageTypeMessageDelivered WSMessageType = "message_delivered"
+	MessageTypeMessageRead      WSMessageType = "message_read"
+
+	MessageTypeTrackMap            WSMessageType = "track_map"
+	MessageTypeScreenShareStarted  WSMessageType = "screen_share_started"
+	MessageTypeScreenShareStopped  WSMessageType = "screen_share_stopped"
+	MessageTypeScreenShareRejected WSMessageType = "screen_share_rejected"
+
+	MessageTypeModerate   WSMessageType = "moderate" // client -> server moderation request
+	MessageTypeModeration WSMessageType = "moderation"
+	MessageTypeError      WSMessageType = "error"
+
+	MessageTypeLobbyJoinRequest WSMessageType = "lobby_join_request"
+	MessageTypeLobbyAdmitted    WSMessageType = "lobby_admitted"
+	MessageTypeLobbyDenied      WSMessageType = "lobby_denied"
+
+	MessageTypeMeetingInvitation WSMessageType = "meeting_invitation"
+	MessageTypeMeetingReminder   WSMessageType = "meeting_reminder"
+	MessageTypeMeetingCancelled  WSMessageType = "meeting_cancelled"
+
+	MessageTypeBreakoutsOpened   WSMessageType = "breakouts_opened"
+	MessageTypeBreakoutMoved     WSMessageType = "breakout_moved"
+	MessageTypeBreakoutBroadcast WSMessageType = "breakout_broadcast"
+	MessageTypeBreakoutsClosing  WSMessageType = "breakouts_closing"
+	MessageTypeBreakoutsClosed   WSMessageType = "breakouts_closed"
+
+	MessageTypeRaiseHand   WSMessageType = "raise_hand" // client -> server
+	MessageTypeHandRaised  WSMessageType = "hand_raised"
+	MessageTypeHandQueue   WSMessageType = "hand_queue"
+	MessageTypeReaction    WSMessageType = "reaction"
+	MessageTypePollCreate  WSMessageType = "poll_create" // client -> server
+	MessageTypePollVote    WSMessageType = "poll_vote"   // client -> server
+	MessageTypePollClose   WSMessageType = "poll_close"  // client -> server
+	MessageTypePollStarted WSMessageType = "poll_started"
+	MessageTypePollUpdated WSMessageType = "poll_updated"
+	MessageTypePollClosed  WSMessageType = "poll_closed"
+
+	MessageTypeTransfer      WSMessageType = "transfer" // client -> server
+	MessageTypeCallTransfer  WSMessageType = "call_transfer"
+	MessageTypeCallForwarded WSMessageType = "call_forwarded"
+
+	MessageTypeContactRequest          WSMessageType = "contact_request"
+	MessageTypeContactRequestAccepted  WSMessageType = "contact_request_accepted"
+	MessageTypeContactRequestDeclined  WSMessageType = "contact_request_declined"
+	MessageTypeContactRequestCancelled WSMessageType = "contact_request_cancelled"
+	MessageTypeContactRemoved          WSMessageType = "contact_removed"
+
+	MessageTypeCalleeUnavailable WSMessageType = "callee_unavailable"
+	MessageTypeVoicemailNew      WSMessageType = "voicemail_new"
 )
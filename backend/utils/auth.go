@@ -2,6 +2,7 @@ package utils
 
 import (
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -11,6 +12,10 @@ var jwtKey = []byte("my_secret_key")
 
 type Claims struct {
 	Username string `json:"username"`
+	// guest tokens are only good for the room or call they were issued for
+	Guest  bool `json:"guest,omitempty"`
+	RoomId uint `json:"roomId,omitempty"`
+	CallId uint `json:"callId,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -43,6 +48,23 @@ func GenerateTokenFor(username string) (string, error) {
 	return token.SignedString(jwtKey)
 }
 
+// GenerateGuestToken creates a signed JWT for a guest user, scoped to one room or call.
+func GenerateGuestToken(userID, roomID, callID uint, ttl time.Duration) (string, error) {
+	claims := &Claims{
+		Username: strconv.FormatUint(uint64(userID), 10),
+		Guest:    true,
+		RoomId:   roomID,
+		CallId:   callID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtKey)
+}
+
 // ValidateToken parses and validates the provided JWT string and returns the claims.
 func ValidateToken(tokenStr string) (*Claims, error) {
 	if tokenStr == "" {
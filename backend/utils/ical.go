@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	icsTimeFormat      = "20060102T150405Z"
+	icsLocalTimeFormat = "20060102T150405"
+)
+
+// ICSPerson is an organizer or attendee of a calendar event.
+type ICSPerson struct {
+	Name  string
+	Email string
+}
+
+// ICSEvent is the data needed to render a single VEVENT. Start and End are written in the time
+// zone of Start's location, so a recurring event keeps its local time across daylight saving.
+type ICSEvent struct {
+	UID         string
+	Sequence    int
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	URL         string
+	RRule       string
+	Organizer   ICSPerson
+	Attendees   []ICSPerson
+	Cancelled   bool
+}
+
+// RenderICS writes an RFC 5545 calendar holding ev. method is PUBLISH for a plain export,
+// REQUEST for an invitation and CANCEL for a cancellation.
+func RenderICS(method string, ev ICSEvent) string {
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldICSLine(s))
+		b.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//facetime-app//meetings//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:" + method)
+	line("BEGIN:VEVENT")
+	line("UID:" + ev.UID)
+	line(fmt.Sprintf("SEQUENCE:%d", ev.Sequence))
+	line("DTSTAMP:" + time.Now().UTC().Format(icsTimeFormat))
+	line("DTSTART" + icsTime(ev.Start, ev.Start.Location()))
+	line("DTEND" + icsTime(ev.End, ev.Start.Location()))
+	line("SUMMARY:" + escapeICSText(ev.Summary))
+	if ev.Description != "" {
+		line("DESCRIPTION:" + escapeICSText(ev.Description))
+	}
+	if ev.URL != "" {
+		line("URL:" + ev.URL)
+	}
+	if ev.RRule != "" {
+		line("RRULE:" + ev.RRule)
+	}
+	line(fmt.Sprintf("ORGANIZER;CN=%s:mailto:%s", quoteICSParam(ev.Organizer.Name), ev.Organizer.Email))
+	for _, a := range ev.Attendees {
+		line(fmt.Sprintf("ATTENDEE;CN=%s;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:%s", quoteICSParam(a.Name), a.Email))
+	}
+	if ev.Cancelled {
+		line("STATUS:CANCELLED")
+	} else {
+		line("STATUS:CONFIRMED")
+	}
+	line("END:VEVENT")
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+// icsTime formats t as a property value in loc, with a TZID parameter unless loc is UTC.
+func icsTime(t time.Time, loc *time.Location) string {
+	if name := loc.String(); name != "UTC" && name != "Local" {
+		return ";TZID=" + name + ":" + t.In(loc).Format(icsLocalTimeFormat)
+	}
+	return ":" + t.UTC().Format(icsTimeFormat)
+}
+
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// quoteICSParam quotes parameter values that contain separators; quotes themselves aren't allowed.
+func quoteICSParam(s string) string {
+	s = strings.ReplaceAll(s, `"`, "'")
+	if strings.ContainsAny(s, ";:,") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// foldICSLine splits lines longer than 75 octets, continuing them with a leading space,
+// without cutting a UTF-8 sequence in half.
+func foldICSLine(s string) string {
+	if len(s) <= 75 {
+		return s
+	}
+	var b strings.Builder
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // the leading space counts towards the next line
+	}
+	b.WriteString(s)
+	return b.String()
+}
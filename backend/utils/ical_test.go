@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderICSCancel(t *testing.T) {
+	ev := ICSEvent{
+		UID:       "meeting-1@facetime-app",
+		Sequence:  3,
+		Start:     time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+		End:       time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC),
+		Summary:   "Standup; daily, short",
+		Organizer: ICSPerson{Name: "Host", Email: "host@example.com"},
+		Attendees: []ICSPerson{{Name: "Doe, Jane", Email: "jane@example.com"}},
+		Cancelled: true,
+	}
+	out := RenderICS("CANCEL", ev)
+	for _, want := range []string{
+		"METHOD:CANCEL\r\n", "STATUS:CANCELLED\r\n", "SEQUENCE:3\r\n",
+		"DTSTART:20260105T090000Z\r\n", "DTEND:20260105T100000Z\r\n",
+		`SUMMARY:Standup\; daily\, short` + "\r\n",
+		`ATTENDEE;CN="Doe, Jane";`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderICSTimeZone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	start := time.Date(2026, 3, 23, 9, 0, 0, 0, berlin)
+	out := RenderICS("PUBLISH", ICSEvent{UID: "x", Start: start, End: start.Add(time.Hour), RRule: "FREQ=WEEKLY"})
+	for _, want := range []string{"DTSTART;TZID=Europe/Berlin:20260323T090000\r\n", "DTEND;TZID=Europe/Berlin:20260323T100000\r\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestFoldICSLine(t *testing.T) {
+	long := "DESCRIPTION:" + strings.Repeat("é", 60)
+	for _, line := range strings.Split(foldICSLine(long), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets", len(line))
+		}
+	}
+	if got := strings.ReplaceAll(foldICSLine(long), "\r\n ", ""); got != long {
+		t.Errorf("unfolded %q, want %q", got, long)
+	}
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRecurrencePeriods bounds how far Next searches past from, e.g. about 27 years of a daily rule.
+const maxRecurrencePeriods = 10000
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// RRule is the subset of an RFC 5545 recurrence rule meetings support: FREQ (DAILY, WEEKLY,
+// MONTHLY or YEARLY), INTERVAL, COUNT, UNTIL and, for weekly rules, BYDAY.
+type RRule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []time.Weekday
+}
+
+// ParseRRule parses an RRULE value such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=10".
+func ParseRRule(s string) (RRule, error) {
+	r := RRule{Interval: 1}
+	for _, part := range strings.Split(strings.TrimPrefix(strings.ToUpper(s), "RRULE:"), ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return r, fmt.Errorf("invalid rule part %q", part)
+		}
+		switch key {
+		case "FREQ":
+			switch val {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				r.Freq = val
+			default:
+				return r, fmt.Errorf("unsupported FREQ %q", val)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return r, errors.New("INTERVAL must be a positive number")
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return r, errors.New("COUNT must be a positive number")
+			}
+			r.Count = n
+		case "UNTIL":
+			t, err := time.Parse("20060102T150405Z", val)
+			if err != nil {
+				return r, errors.New("UNTIL must be a UTC date-time like 20260131T090000Z")
+			}
+			r.Until = t
+		case "BYDAY":
+			for _, code := range strings.Split(val, ",") {
+				d, ok := weekdayCodes[code]
+				if !ok {
+					return r, fmt.Errorf("invalid BYDAY %q", code)
+				}
+				r.ByDay = append(r.ByDay, d)
+			}
+		default:
+			return r, fmt.Errorf("unsupported rule part %s", key)
+		}
+	}
+	if r.Freq == "" {
+		return r, errors.New("FREQ is required")
+	}
+	if len(r.ByDay) > 0 && r.Freq != "WEEKLY" {
+		return r, errors.New("BYDAY is only supported with FREQ=WEEKLY")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return r, errors.New("COUNT and UNTIL cannot both be set")
+	}
+	return r, nil
+}
+
+// Next returns the first occurrence of the rule starting at dtstart that is not before from.
+// Occurrences are expanded in dtstart's location, so a meeting at 9:00 stays at 9:00 local time
+// across daylight saving changes.
+func (r RRule) Next(dtstart, from time.Time) (time.Time, bool) {
+	if !r.Until.IsZero() && from.After(r.Until) {
+		return time.Time{}, false
+	}
+	// without COUNT nothing before from needs counting, so skip straight to the periods around it
+	first := 0
+	if r.Count == 0 {
+		first = max(r.periodsBetween(dtstart, from)-1, 0)
+	}
+	n := 0
+	for period := first; period < first+maxRecurrencePeriods; period++ {
+		for _, t := range r.period(dtstart, period) {
+			if t.Before(dtstart) {
+				continue
+			}
+			n++
+			if (r.Count > 0 && n > r.Count) || (!r.Until.IsZero() && t.After(r.Until)) {
+				return time.Time{}, false
+			}
+			if !t.Before(from) {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// periodsBetween returns how many whole intervals fit between dtstart and t, give or take the
+// daylight saving hour. Next starts a period earlier, which also covers BYDAY weeks whose days
+// fall before dtstart's weekday.
+func (r RRule) periodsBetween(dtstart, t time.Time) int {
+	if !t.After(dtstart) {
+		return 0
+	}
+	var units int
+	switch r.Freq {
+	case "DAILY":
+		units = int(t.Sub(dtstart).Hours() / 24)
+	case "WEEKLY":
+		units = int(t.Sub(dtstart).Hours() / 24 / 7)
+	case "MONTHLY":
+		t = t.In(dtstart.Location())
+		units = (t.Year()-dtstart.Year())*12 + int(t.Month()) - int(dtstart.Month())
+	case "YEARLY":
+		units = t.In(dtstart.Location()).Year() - dtstart.Year()
+	}
+	return units / r.Interval
+}
+
+// period returns the occurrences in the period-th interval after dtstart, in order.
+func (r RRule) period(dtstart time.Time, period int) []time.Time {
+	step := period * r.Interval
+	switch r.Freq {
+	case "DAILY":
+		return []time.Time{dtstart.AddDate(0, 0, step)}
+	case "WEEKLY":
+		if len(r.ByDay) == 0 {
+			return []time.Time{dtstart.AddDate(0, 0, 7*step)}
+		}
+		// weeks start on Monday, the RFC 5545 default WKST
+		monday := dtstart.AddDate(0, 0, -((int(dtstart.Weekday())+6)%7)+7*step)
+		out := make([]time.Time, 0, len(r.ByDay))
+		for _, d := range r.ByDay {
+			out = append(out, monday.AddDate(0, 0, (int(d)+6)%7))
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+		return out
+	case "MONTHLY", "YEARLY":
+		t := dtstart.AddDate(0, step, 0)
+		if r.Freq == "YEARLY" {
+			t = dtstart.AddDate(step, 0, 0)
+		}
+		// months without the start's day (e.g. the 31st) are skipped rather than rolled over
+		if t.Day() != dtstart.Day() {
+			return nil
+		}
+		return []time.Time{t}
+	}
+	return nil
+}
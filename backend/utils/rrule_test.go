@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func mustRule(t *testing.T, s string) RRule {
+	t.Helper()
+	r, err := ParseRRule(s)
+	if err != nil {
+		t.Fatalf("ParseRRule(%q): %v", s, err)
+	}
+	return r
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	for _, s := range []string{
+		"", "INTERVAL=2", "FREQ=HOURLY", "FREQ=DAILY;INTERVAL=0", "FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;BYDAY=MO", "FREQ=WEEKLY;BYDAY=XX", "FREQ=DAILY;COUNT=2;UNTIL=20260101T000000Z",
+		"FREQ=DAILY;UNTIL=2026-01-01", "FREQ=DAILY;BYMONTH=1",
+	} {
+		if _, err := ParseRRule(s); err == nil {
+			t.Errorf("ParseRRule(%q) succeeded", s)
+		}
+	}
+}
+
+func TestRRuleNext(t *testing.T) {
+	// Thursday 1 January 2026, 09:00 UTC
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 9, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		rule string
+		from time.Time
+		want time.Time // zero when there is none
+	}{
+		{"FREQ=DAILY", start, start},
+		{"FREQ=DAILY;INTERVAL=3", day(1, 2), day(1, 4)},
+		{"FREQ=DAILY;COUNT=3", day(1, 3), day(1, 3)},
+		{"FREQ=DAILY;COUNT=3", day(1, 3).Add(time.Minute), time.Time{}},
+		{"FREQ=DAILY;UNTIL=20260105T090000Z", day(1, 5), day(1, 5)},
+		{"FREQ=DAILY;UNTIL=20260105T090000Z", day(1, 6), time.Time{}},
+		// BYDAY days before the start's weekday in its first week are skipped
+		{"FREQ=WEEKLY;BYDAY=MO,TH", start, start},
+		{"FREQ=WEEKLY;BYDAY=MO,TH", day(1, 2), day(1, 5)},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", day(1, 6), day(1, 12)},
+		{"FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3", day(1, 9), time.Time{}},
+		{"FREQ=MONTHLY", day(1, 2), day(2, 1)},
+		{"FREQ=YEARLY", day(1, 2), time.Date(2027, 1, 1, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, ok := mustRule(t, tt.rule).Next(start, tt.from)
+		if ok != !tt.want.IsZero() || !got.Equal(tt.want) {
+			t.Errorf("%s from %v = %v, %v; want %v", tt.rule, tt.from, got, ok, tt.want)
+		}
+	}
+}
+
+func TestRRuleMonthlySkipsShortMonths(t *testing.T) {
+	start := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	got, ok := mustRule(t, "FREQ=MONTHLY").Next(start, start.Add(time.Hour))
+	if want := time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC); !ok || !got.Equal(want) {
+		t.Errorf("next after 31 January = %v, want %v", got, want)
+	}
+}
+
+func TestRRuleKeepsLocalTimeAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	// 09:00 CET every Monday; clocks go forward on 29 March 2026
+	start := time.Date(2026, 3, 23, 9, 0, 0, 0, berlin)
+	got, ok := mustRule(t, "FREQ=WEEKLY").Next(start, start.Add(time.Hour))
+	if !ok || got.In(berlin).Hour() != 9 || got.UTC().Hour() != 7 {
+		t.Errorf("after DST: %v (%v UTC), want 09:00 local", got.In(berlin), got.UTC())
+	}
+}
+
+// TestRRuleSkipsAhead checks that starting near from finds what walking every period from
+// dtstart does, for rules going back years.
+func TestRRuleSkipsAhead(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	start := time.Date(2001, 1, 31, 23, 30, 0, 0, berlin)
+	for _, s := range []string{"FREQ=DAILY", "FREQ=DAILY;INTERVAL=7", "FREQ=WEEKLY;BYDAY=MO,SU",
+		"FREQ=WEEKLY;INTERVAL=3;BYDAY=TU,WE,FR", "FREQ=MONTHLY;INTERVAL=5", "FREQ=YEARLY"} {
+		r := mustRule(t, s)
+		for from := start; from.Year() < 2027; from = from.Add(97*24*time.Hour + 13*time.Hour) {
+			got, ok := r.Next(start, from)
+			want, wantOk := walk(r, start, from)
+			if ok != wantOk || !got.Equal(want) {
+				t.Fatalf("%s from %v = %v, want %v", s, from, got, want)
+			}
+		}
+	}
+}
+
+// walk expands r from dtstart one period at a time.
+func walk(r RRule, dtstart, from time.Time) (time.Time, bool) {
+	for period := 0; period < 100000; period++ {
+		for _, t := range r.period(dtstart, period) {
+			if !t.Before(dtstart) && !t.Before(from) {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// SignPath returns an HMAC signature granting access to path until expires.
+func SignPath(path string, expires time.Time) string {
+	mac := hmac.New(sha256.New, jwtKey)
+	mac.Write([]byte(path + "|" + strconv.FormatInt(expires.Unix(), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignedPath checks a signature produced by SignPath; expires is the unix timestamp from the URL.
+func VerifySignedPath(path, expires, sig string) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errors.New("invalid expiry")
+	}
+	if time.Now().Unix() > exp {
+		return errors.New("link expired")
+	}
+	want := SignPath(path, time.Unix(exp, 0))
+	if !hmac.Equal([]byte(want), []byte(sig)) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
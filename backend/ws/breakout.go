@@ -0,0 +1,258 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"maps"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+// maxBreakouts bounds how many rooms a call can be split into.
+const maxBreakouts = 50
+
+var (
+	ErrBreakoutsOpen    = errors.New("breakout rooms are already open")
+	ErrNoBreakouts      = errors.New("call has no breakout rooms")
+	ErrUnknownBreakout  = errors.New("breakout room not found")
+	ErrInvalidBreakouts = errors.New("a call can be split into 1 to 50 breakout rooms")
+)
+
+// Breakouts describes the breakout rooms of the call and who is in each.
+func (s *CallSession) Breakouts() models.BreakoutsMessage {
+	s.Mu.RLock()
+	rooms := append([]*CallSession(nil), s.breakouts...)
+	out := models.BreakoutsMessage{CallId: s.ID, AllowChoice: s.breakoutChoice}
+	s.Mu.RUnlock()
+
+	out.Rooms = make([]models.BreakoutRoom, 0, len(rooms))
+	for _, r := range rooms {
+		r.Mu.RLock()
+		room := models.BreakoutRoom{CallId: r.ID, Name: r.BreakoutName, Participants: make([]uint, 0, len(r.Participants))}
+		for uid := range r.Participants {
+			room.Participants = append(room.Participants, uid)
+		}
+		r.Mu.RUnlock()
+		out.Rooms = append(out.Rooms, room)
+	}
+	return out
+}
+
+// HasMember reports whether userID is in the call or in one of its breakout rooms.
+func (s *CallSession) HasMember(userID uint) bool {
+	if s.HasParticipant(userID) {
+		return true
+	}
+	s.Mu.RLock()
+	rooms := append([]*CallSession(nil), s.breakouts...)
+	s.Mu.RUnlock()
+	for _, r := range rooms {
+		if r.HasParticipant(userID) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenBreakouts splits the call into one breakout room per name. Each room is a call of its own
+// with the same host and moderators; participants stay in the main call until they are moved.
+func (h *Hub) OpenBreakouts(s *CallSession, actorID uint, names []string, allowChoice bool) error {
+	if !s.CanModerate(actorID) {
+		return ErrNotPermitted
+	}
+	if len(names) == 0 || len(names) > maxBreakouts {
+		return ErrInvalidBreakouts
+	}
+	// the hub lock keeps two moderators from opening rooms at the same time
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+
+	s.Mu.RLock()
+	open := len(s.breakouts) > 0
+	host := s.hostID()
+	roles := make(map[uint]models.CallRole, len(s.Roles))
+	for uid, r := range s.Roles {
+		roles[uid] = r
+	}
+	s.Mu.RUnlock()
+	if open {
+		return ErrBreakoutsOpen
+	}
+
+	rooms := make([]*CallSession, 0, len(names))
+	for _, name := range names {
+		parentID := s.ID
+		call := models.Call{
+			CallerId:  host,
+			StartTime: time.Now(),
+			Status:    models.Ongoing,
+			ParentId:  &parentID,
+		}
+		if err := database.Db.Create(&call).Error; err != nil {
+			return err
+		}
+		room := NewCallSession(call)
+		room.hub = h
+		room.Parent = s
+		room.BreakoutName = name
+		room.Roles = maps.Clone(roles) // a promotion in one room stays in that room
+		h.CallSessions[call.Id] = room
+		go room.runStats()
+		rooms = append(rooms, room)
+	}
+
+	s.Mu.Lock()
+	s.breakouts = rooms
+	s.breakoutChoice = allowChoice
+	s.Mu.Unlock()
+
+	s.Broadcast(models.WebSocketMessage{Type: models.MessageTypeBreakoutsOpened, Payload: s.Breakouts(), Time: time.Now()}, 0)
+	return nil
+}
+
+// MoveToBreakout moves userID into the breakout room roomID, or back to the main call when roomID
+// is the call's own id. Moderators can move anyone; participants can move themselves back to the
+// main call, and into a room when the host lets them pick.
+func (s *CallSession) MoveToBreakout(actorID, userID, roomID uint) error {
+	s.Mu.RLock()
+	rooms := append([]*CallSession(nil), s.breakouts...)
+	choice := s.breakoutChoice
+	s.Mu.RUnlock()
+	if len(rooms) == 0 {
+		return ErrNoBreakouts
+	}
+	moderator := s.CanModerate(actorID)
+	if actorID != userID && !moderator {
+		return ErrNotPermitted
+	}
+	if !moderator && !choice && roomID != s.ID {
+		return ErrNotPermitted
+	}
+
+	target := s
+	if roomID != s.ID {
+		target = nil
+		for _, r := range rooms {
+			if r.ID == roomID {
+				target = r
+			}
+		}
+		if target == nil {
+			return ErrUnknownBreakout
+		}
+	}
+	from := s.sessionOf(userID, rooms)
+	if from == nil {
+		return ErrNotInCall
+	}
+	if from != target {
+		s.move(userID, from, target)
+	}
+	return nil
+}
+
+// CloseBreakouts brings everyone back to the main call, after countdown if it is positive. The
+// countdown is announced to every room.
+func (h *Hub) CloseBreakouts(s *CallSession, actorID uint, countdown time.Duration) error {
+	if !s.CanModerate(actorID) {
+		return ErrNotPermitted
+	}
+	s.Mu.Lock()
+	if len(s.breakouts) == 0 {
+		s.Mu.Unlock()
+		return ErrNoBreakouts
+	}
+	if s.breakoutTimer != nil {
+		s.breakoutTimer.Stop()
+		s.breakoutTimer = nil
+	}
+	if countdown > 0 {
+		s.breakoutTimer = time.AfterFunc(countdown, func() { h.closeBreakouts(s) })
+	}
+	s.Mu.Unlock()
+
+	if countdown > 0 {
+		s.NotifyBreakouts(models.WebSocketMessage{
+			Type:    models.MessageTypeBreakoutsClosing,
+			Payload: models.BreakoutNoticeMessage{CallId: s.ID, From: actorID, Seconds: int(countdown / time.Second)},
+			Time:    time.Now(),
+		})
+		return nil
+	}
+	h.closeBreakouts(s)
+	return nil
+}
+
+// NotifyBreakouts sends msg to the main call and every breakout room.
+func (s *CallSession) NotifyBreakouts(msg models.WebSocketMessage) {
+	s.Mu.RLock()
+	rooms := append([]*CallSession(nil), s.breakouts...)
+	s.Mu.RUnlock()
+	s.Broadcast(msg, 0)
+	for _, r := range rooms {
+		r.Broadcast(msg, 0)
+	}
+}
+
+func (h *Hub) closeBreakouts(s *CallSession) {
+	s.Mu.Lock()
+	rooms := s.breakouts
+	s.breakouts = nil
+	s.breakoutChoice = false
+	s.breakoutTimer = nil
+	s.Mu.Unlock()
+	if len(rooms) == 0 {
+		return
+	}
+
+	for _, r := range rooms {
+		r.Mu.RLock()
+		ids := make([]uint, 0, len(r.Participants))
+		for uid := range r.Participants {
+			ids = append(ids, uid)
+		}
+		r.Mu.RUnlock()
+		for _, uid := range ids {
+			s.move(uid, r, s)
+		}
+		h.finishCall(r, models.Ended)
+	}
+	s.Broadcast(models.WebSocketMessage{
+		Type:    models.MessageTypeBreakoutsClosed,
+		Payload: models.BreakoutsMessage{CallId: s.ID, Rooms: []models.BreakoutRoom{}},
+		Time:    time.Now(),
+	}, 0)
+}
+
+// sessionOf returns the session among s and rooms that userID is connected to.
+func (s *CallSession) sessionOf(userID uint, rooms []*CallSession) *CallSession {
+	for _, cand := range append([]*CallSession{s}, rooms...) {
+		cand.Mu.RLock()
+		_, ok := cand.Participants[userID]
+		cand.Mu.RUnlock()
+		if ok {
+			return cand
+		}
+	}
+	return nil
+}
+
+// move takes userID out of from and tells them to negotiate media with to.
+func (s *CallSession) move(userID uint, from, to *CallSession) {
+	cl := moveParticipant(userID, from, to)
+	if cl == nil {
+		return
+	}
+	msg := models.WebSocketMessage{
+		Type:    models.MessageTypeBreakoutMoved,
+		Payload: models.BreakoutMoveMessage{ParentCallId: s.ID, CallId: to.ID, Name: to.BreakoutName},
+		Time:    time.Now(),
+	}
+	select {
+	case cl.Send <- msg:
+	default:
+		log.Printf("move: send channel full for user %d", userID)
+	}
+}
@@ -0,0 +1,108 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+// openBreakouts opens rooms named names in s and returns them.
+func openBreakouts(t *testing.T, h *Hub, s *CallSession, names ...string) []*CallSession {
+	t.Helper()
+	if err := h.OpenBreakouts(s, s.Call.CallerId, names, false); err != nil {
+		t.Fatal(err)
+	}
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return append([]*CallSession(nil), s.breakouts...)
+}
+
+func TestBreakoutRolesAreSeparate(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "host", "mod", "alice")
+	host, mod, alice := users[0], users[1], users[2]
+	h, s := moderatedCall(t, users)
+	if err := s.Moderate(host, models.ModerationRequest{Action: models.ActionSetRole, TargetId: mod, Role: models.RoleModerator}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.OpenBreakouts(s, alice, []string{"A"}, false); err != ErrNotPermitted {
+		t.Fatalf("participant opening rooms = %v, want ErrNotPermitted", err)
+	}
+	rooms := openBreakouts(t, h, s, "A", "B")
+	if err := h.OpenBreakouts(s, host, []string{"C"}, false); err != ErrBreakoutsOpen {
+		t.Fatalf("opening twice = %v, want ErrBreakoutsOpen", err)
+	}
+
+	// moderators of the main call moderate every room
+	for _, r := range rooms {
+		if r.Role(mod) != models.RoleModerator {
+			t.Errorf("room %s: mod is %s", r.BreakoutName, r.Role(mod))
+		}
+	}
+	rooms[0].Mu.Lock()
+	rooms[0].Participants[alice] = &Client{UserID: alice, Send: make(chan models.WebSocketMessage, 8)}
+	rooms[0].Mu.Unlock()
+	if err := rooms[0].Moderate(host, models.ModerationRequest{Action: models.ActionSetRole, TargetId: alice, Role: models.RoleModerator}); err != nil {
+		t.Fatal(err)
+	}
+	if rooms[1].Role(alice) != models.RoleParticipant || s.Role(alice) != models.RoleParticipant {
+		t.Errorf("promotion in room A leaked: room B %s, main call %s", rooms[1].Role(alice), s.Role(alice))
+	}
+}
+
+func TestClosingCallEndsBreakouts(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "host", "alice")
+	h, s := moderatedCall(t, users)
+	rooms := openBreakouts(t, h, s, "A", "B")
+
+	s.Close()
+	for _, r := range rooms {
+		var call models.Call
+		database.Db.First(&call, r.ID)
+		if call.Status != models.Ended || call.EndTime == nil {
+			t.Errorf("room %s saved as %s", r.BreakoutName, call.Status)
+		}
+		if !r.Closed() {
+			t.Errorf("room %s is still open", r.BreakoutName)
+		}
+	}
+	// the hub forgets them in the background, since Close may run under its lock
+	deadline := time.Now().Add(time.Second)
+	for _, r := range rooms {
+		for {
+			if _, ok := h.GetCallSession(r.ID); !ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("room %s is still in the hub", r.BreakoutName)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+func TestCloseBreakoutsEndsRooms(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "host", "alice")
+	h, s := moderatedCall(t, users)
+	rooms := openBreakouts(t, h, s, "A")
+
+	if err := h.CloseBreakouts(s, users[1], 0); err != ErrNotPermitted {
+		t.Fatalf("participant closing rooms = %v, want ErrNotPermitted", err)
+	}
+	if err := h.CloseBreakouts(s, users[0], 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.GetCallSession(rooms[0].ID); ok {
+		t.Error("closed room is still in the hub")
+	}
+	if _, ok := h.GetCallSession(s.ID); !ok {
+		t.Error("main call was forgotten")
+	}
+	if err := h.CloseBreakouts(s, users[0], 0); err != ErrNoBreakouts {
+		t.Errorf("closing again = %v, want ErrNoBreakouts", err)
+	}
+}
@@ -1,8 +1,6 @@
 package ws
 
 import (
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"log"
 	"sync"
@@ -15,24 +13,81 @@ import (
 
 // CallSession manages per-call participants and session state.
 type CallSession struct {
-	ID              uint
-	Call            models.Call
-	Participants    map[uint]*Client // userID -> client
-	Mu              sync.RWMutex
-	PublishedTracks map[string]*webrtc.TrackLocalStaticRTP // trackID -> track
-	PublishedOwners map[string]uint                        // trackID -> publisherID
-	TrackPublishers map[string]uint                        // mid -> userId
+	ID               uint
+	Call             models.Call
+	Participants     map[uint]*Client // userID -> client
+	Mu               sync.RWMutex
+	PublishedTracks  map[string]*ForwardTrack      // trackID -> track
+	PublishedOwners  map[string]uint               // trackID -> publisherID
+	PublishedSources map[string]models.TrackSource // trackID -> camera, screen or audio
+	TrackPublishers  map[string]uint               // mid -> userId
+	Recorder         *Recorder                     // non-nil while the call is being recorded
+	LiveStream       *LiveStream                   // non-nil while the call is streamed over HLS
+	WHIPPublishers   map[string]*WHIPPublisher     // resource id -> external publisher
+	WHEPViewers      map[string]*WHEPViewer        // resource id -> view-only subscriber
+	AudioMixing      bool                          // every participant receives mixed audio
+	MaxScreenShares  int                           // screen shares allowed at once
+	HostId           uint                          // starts as the caller; changes on host transfer
+	Roles            map[uint]models.CallRole      // userID -> role, for anyone not a plain participant
+	Locked           bool                          // no new joiners while set
+	MaxParticipants  int                           // 0 means no limit
+	LobbyEnabled     bool                          // joiners wait for admission
+	AutoAdmit        AutoAdmitRules                // who skips the lobby
+	Lobby            map[uint]models.LobbyEntry    // userID -> waiting joiner
+	Parent           *CallSession                  // main call of a breakout room
+	BreakoutName     string                        // set on breakout rooms
+
+	Stats        map[uint]*models.ParticipantStats // userID -> latest stats sample
+	statsSamples map[string]trackSample
+	done         chan struct{}
+	closeOnce    sync.Once
+
+	mixedAudio   map[uint]bool                           // participants that opted into mixed audio
+	mixer        *AudioMixer                             // non-nil while anyone receives mixed audio
+	audioCodec   audioCodec                              // nil when the build has no Opus support
+	dataChannels map[uint]map[string]*webrtc.DataChannel // userID -> label -> open channel
+	removed      map[uint]bool                           // users removed by a moderator
+	admitted     map[uint]bool                           // users let in from the lobby, past a lock or a full room
+	hub          *Hub                                    // reaches users who aren't participants yet
+
+	breakouts      []*CallSession // open breakout rooms, in creation order
+	breakoutChoice bool           // participants may pick their own room
+	breakoutTimer  *time.Timer    // pending close-all countdown
+
+	hands     []models.HandRaise   // raised hands, oldest first
+	reactions map[uint][]time.Time // userID -> recent reaction times, for rate limiting
+	polls     map[uint]*livePoll   // poll id -> poll
+	answered  map[uint]bool        // users who negotiated media in this call
+	voicemail *voicemailRecorder   // non-nil while the caller leaves a voicemail
 }
 
 // NewCallSession constructs a CallSession.
 func NewCallSession(call models.Call) *CallSession {
 	return &CallSession{
-		ID:              call.Id,
-		Call:			 call,
-		Participants:    make(map[uint]*Client),
-		PublishedTracks: make(map[string]*webrtc.TrackLocalStaticRTP),
-		PublishedOwners: make(map[string]uint),
-		TrackPublishers: make(map[string]uint),
+		ID:               call.Id,
+		Call:             call,
+		Participants:     make(map[uint]*Client),
+		PublishedTracks:  make(map[string]*ForwardTrack),
+		PublishedOwners:  make(map[string]uint),
+		PublishedSources: make(map[string]models.TrackSource),
+		TrackPublishers:  make(map[string]uint),
+		WHIPPublishers:   make(map[string]*WHIPPublisher),
+		WHEPViewers:      make(map[string]*WHEPViewer),
+		MaxScreenShares:  DefaultMaxScreenShares,
+		HostId:           call.CallerId,
+		Roles:            make(map[uint]models.CallRole),
+		Stats:            make(map[uint]*models.ParticipantStats),
+		statsSamples:     make(map[string]trackSample),
+		done:             make(chan struct{}),
+		mixedAudio:       make(map[uint]bool),
+		audioCodec:       defaultAudioCodec,
+		dataChannels:     make(map[uint]map[string]*webrtc.DataChannel),
+		removed:          make(map[uint]bool),
+		admitted:         make(map[uint]bool),
+		Lobby:            make(map[uint]models.LobbyEntry),
+		reactions:        make(map[uint][]time.Time),
+		polls:            make(map[uint]*livePoll),
+		answered:         make(map[uint]bool),
 	}
 }
 
@@ -41,7 +96,95 @@ func (s *CallSession) AddParticipant(c *Client) {
 	defer s.Mu.Unlock()
 	if c != nil {
 		s.Participants[c.UserID] = c
+		if s.Recorder != nil {
+			// late joiners must also be told the call is being recorded
+			msg := models.WebSocketMessage{
+				Type: models.MessageTypeRecordingStarted,
+				Payload: models.RecordingStatusMessage{
+					CallId:    s.ID,
+					UserId:    s.Recorder.StartedBy,
+					StartedAt: s.Recorder.StartedAt,
+				},
+				Time: time.Now(),
+			}
+			select {
+			case c.Send <- msg:
+			default:
+			}
+		}
+		// and who is presenting
+		for id, src := range s.PublishedSources {
+			if src != models.SourceScreen {
+				continue
+			}
+			select {
+			case c.Send <- screenShareMessage(models.MessageTypeScreenShareStarted, s.ID, s.trackInfo(id), 0, ""):
+			default:
+			}
+		}
+	}
+}
+
+// Broadcast sends msg to every participant except exceptID (0 sends to everyone).
+func (s *CallSession) Broadcast(msg models.WebSocketMessage, exceptID uint) {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	for uid, p := range s.Participants {
+		if uid == exceptID || p == nil {
+			continue
+		}
+		select {
+		case p.Send <- msg:
+		default:
+			log.Printf("Broadcast: send channel full for user %d", uid)
+		}
+	}
+}
+
+// HasParticipant reports whether userID is currently in the call or is its caller.
+func (s *CallSession) HasParticipant(userID uint) bool {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	_, ok := s.Participants[userID]
+	return ok || s.hostID() == userID
+}
+
+// IsHost reports whether userID hosts the call.
+func (s *CallSession) IsHost(userID uint) bool {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.hostID() == userID
+}
+
+// hostID returns the host's user id; callers must hold s.Mu.
+func (s *CallSession) hostID() uint {
+	return s.HostId
+}
+
+// CanJoin reports whether userID hosts the call, was invited to it or is already in it. Nobody
+// new may join a locked call unless a moderator admitted them from the lobby, and users removed
+// by a moderator may not come back.
+func (s *CallSession) CanJoin(userID uint) bool {
+	if s.HasParticipant(userID) {
+		return true
+	}
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	if s.removed[userID] {
+		return false
 	}
+	if s.admitted[userID] || userID == s.hostID() {
+		return true
+	}
+	if s.Locked {
+		return false
+	}
+	for _, id := range s.Call.CalleeIds {
+		if id == userID {
+			return true
+		}
+	}
+	return false
 }
 
 // RemoveParticipant removes a participant from the call session
@@ -53,6 +196,15 @@ func (s *CallSession) RemoveParticipant(userID uint, msg *models.WebSocketMessag
 			c.PeerConn.Close()
 		}
 		delete(s.Participants, userID)
+		delete(s.dataChannels, userID)
+		delete(s.reactions, userID)
+		if i := s.handIndex(userID); i >= 0 {
+			s.hands = append(s.hands[:i], s.hands[i+1:]...)
+		}
+		if s.mixer != nil {
+			s.mixer.removeOutput(userID)
+			s.releaseMixer()
+		}
 
 		if msg != nil {
 			for _, p := range s.Participants {
@@ -65,31 +217,146 @@ func (s *CallSession) RemoveParticipant(userID uint, msg *models.WebSocketMessag
 	}
 }
 
+// moveParticipant takes userID out of from, closing their peer connection there, and makes them a
+// participant of to, bypassing its lock and lobby. The caller decides how to tell the client, which
+// has to send a new offer for to; it is nil if the user wasn't connected.
+func moveParticipant(userID uint, from, to *CallSession) *Client {
+	from.Mu.RLock()
+	cl := from.Participants[userID]
+	from.Mu.RUnlock()
+
+	type Payload struct {
+		CallId uint `json:"callId"`
+		UserId uint `json:"userId"`
+	}
+	leave := models.WebSocketMessage{Type: "user_leave", Payload: Payload{CallId: from.ID, UserId: userID}, Time: time.Now()}
+	from.RemoveParticipant(userID, &leave)
+
+	to.Mu.Lock()
+	if !to.invited(userID) {
+		to.Call.CalleeIds = append(to.Call.CalleeIds, userID)
+	}
+	to.Mu.Unlock()
+	if cl != nil {
+		to.AddParticipant(cl)
+	}
+	return cl
+}
+
 // Close closes all the participants peer connection and remove all particpiants
 func (s *CallSession) Close() {
 	s.Mu.Lock()
-	defer s.Mu.Unlock()
 	for _, p := range s.Participants {
 		if p.PeerConn != nil {
 			p.PeerConn.Close()
 		}
 	}
 	s.Participants = make(map[uint]*Client)
+	s.dataChannels = make(map[uint]map[string]*webrtc.DataChannel)
+	rec := s.Recorder
+	s.Recorder = nil
+	live := s.LiveStream
+	if s.mixer != nil {
+		s.mixer.stop()
+		s.mixer = nil
+	}
+	whip := make([]string, 0, len(s.WHIPPublishers))
+	for id := range s.WHIPPublishers {
+		whip = append(whip, id)
+	}
+	viewers := s.WHEPViewers
+	s.WHEPViewers = make(map[string]*WHEPViewer)
+	vm := s.voicemail
+	s.voicemail = nil
+	rooms := s.breakouts
+	s.breakouts = nil
+	if s.breakoutTimer != nil {
+		s.breakoutTimer.Stop()
+	}
+	s.Mu.Unlock()
+
+	// breakout rooms end with their call
+	for _, r := range rooms {
+		r.end(models.Ended)
+	}
+	if len(rooms) > 0 && s.hub != nil {
+		// Close may be called under the hub lock
+		go s.hub.forgetCallSessions(rooms...)
+	}
+
+	for _, v := range viewers {
+		v.PeerConn.Close()
+	}
+
+	if rec != nil {
+		rec.Stop()
+	}
+	// a voicemail cut off by the call ending is still delivered
+	if vm != nil {
+		if _, err := s.saveVoicemail(vm); err != nil {
+			log.Printf("Voicemail: failed to save voicemail of call %d: %v", s.ID, err)
+		}
+	}
+	if live != nil {
+		s.StopLiveStream(live.StartedBy)
+	}
+	for _, id := range whip {
+		s.RemoveWHIPPublisher(id)
+	}
+	s.closePolls()
+	s.closeOnce.Do(func() { close(s.done) })
 }
 
-// PublishTrack stores a publisher's local track in call session
-func (s *CallSession) PublishTrack(publisherID uint, trackID string, track *webrtc.TrackLocalStaticRTP, renegotiate bool) {
+// PublishTrack stores a publisher's local track in call session. Screen tracks are refused with
+// ErrScreenShareLimit once the call has MaxScreenShares of them, and viewers may not publish at all.
+func (s *CallSession) PublishTrack(publisherID uint, trackID string, track *ForwardTrack, source models.TrackSource, renegotiate bool) error {
 	// record ownership
 	s.Mu.Lock()
+	if s.role(publisherID) == models.RoleViewer {
+		s.Mu.Unlock()
+		return ErrViewerPublish
+	}
+	if source == models.SourceScreen && s.screenShareCount(trackID) >= s.MaxScreenShares {
+		s.Mu.Unlock()
+		return ErrScreenShareLimit
+	}
 	s.PublishedTracks[trackID] = track
 	s.PublishedOwners[trackID] = publisherID
+	s.PublishedSources[trackID] = source
+	info := s.trackInfo(trackID)
+	rec := s.Recorder
+	var vm *voicemailRecorder
+	if s.voicemail != nil && s.voicemail.vm.FromId == publisherID {
+		vm = s.voicemail
+	}
+	s.attachLiveStream(publisherID, trackID, track)
+	mixed := isCodec(track, webrtc.MimeTypeOpus)
+	if mixed && s.mixer != nil {
+		if err := s.mixer.addSource(publisherID, trackID, track); err != nil {
+			log.Printf("AudioMixer: cannot decode track %s: %v", trackID, err)
+		}
+	}
 
 	// snapshot participants to avoid holding lock while doing AddTrack
 	parts := make(map[uint]*Client, len(s.Participants))
 	for uid, cl := range s.Participants {
+		// participants in mixing mode hear this track through the mixer
+		if mixed && s.mixesAudioFor(uid) {
+			continue
+		}
 		parts[uid] = cl
 	}
 	s.Mu.Unlock()
+	if rec != nil {
+		rec.Attach(publisherID, trackID, track)
+	}
+	// a voicemail finished meanwhile ignores the track
+	if vm != nil {
+		vm.attach(trackID, track, source)
+	}
+	if source == models.SourceScreen {
+		s.Broadcast(screenShareMessage(models.MessageTypeScreenShareStarted, s.ID, info, 0, ""), 0)
+	}
 	needRenego := make(map[uint]*Client)
 
 	// Add to all viewers (except publisher). Note: if participant already negotiated,
@@ -98,10 +365,12 @@ func (s *CallSession) PublishTrack(publisherID uint, trackID string, track *webr
 		if cl == nil || cl.PeerConn == nil || uid == publisherID {
 			continue
 		}
-		if _, err := cl.PeerConn.AddTrack(track); err != nil {
+		sender, err := cl.PeerConn.AddTrack(track)
+		if err != nil {
 			log.Printf("AddTrack error for participant %d: %v", uid, err)
 			continue
 		}
+		go track.ServeRTCP(sender)
 		needRenego[uid] = cl
 	}
 	// caller/flow should trigger renegotiation for affected participants when needed
@@ -118,27 +387,109 @@ func (s *CallSession) PublishTrack(publisherID uint, trackID string, track *webr
 			}(p)
 		}
 	}
+	return nil
+}
+
+// UnpublishTrack removes a published track from the session and from every subscriber,
+// renegotiating the subscribers that were receiving it.
+func (s *CallSession) UnpublishTrack(trackID string) {
+	s.unpublishTrack(trackID, nil, 0)
 }
 
-// AddPublishedTracksToPeer adds all published tracks to the provided peer connection.
-func (s *CallSession) AddPublishedTracksToPeer(pc *webrtc.PeerConnection, trackID string) error {
+// unpublishTrack is UnpublishTrack for callers that only want to remove a specific ForwardTrack
+// (expect; nil removes whatever is published as trackID) or that stop it on someone's behalf.
+func (s *CallSession) unpublishTrack(trackID string, expect *ForwardTrack, stoppedBy uint) {
+	s.Mu.Lock()
+	track, ok := s.PublishedTracks[trackID]
+	if !ok || (expect != nil && track != expect) {
+		s.Mu.Unlock()
+		return
+	}
+	info := s.trackInfo(trackID)
+	delete(s.PublishedTracks, trackID)
+	delete(s.PublishedOwners, trackID)
+	delete(s.PublishedSources, trackID)
+	if s.mixer != nil {
+		s.mixer.removeSource(trackID)
+	}
+	parts := make([]*Client, 0, len(s.Participants))
+	for _, cl := range s.Participants {
+		parts = append(parts, cl)
+	}
+	viewers := make([]*WHEPViewer, 0, len(s.WHEPViewers))
+	for _, v := range s.WHEPViewers {
+		viewers = append(viewers, v)
+	}
+	s.Mu.Unlock()
+
+	if info.Source == models.SourceScreen {
+		if stoppedBy == info.PublisherId {
+			stoppedBy = 0
+		}
+		s.Broadcast(screenShareMessage(models.MessageTypeScreenShareStopped, s.ID, info, stoppedBy, ""), 0)
+	}
+
+	// WHEP viewers can't renegotiate; just stop sending the track to them
+	for _, v := range viewers {
+		for _, sender := range v.PeerConn.GetSenders() {
+			if sender.Track() == webrtc.TrackLocal(track) {
+				v.PeerConn.RemoveTrack(sender)
+			}
+		}
+	}
+
+	for _, cl := range parts {
+		if cl == nil || cl.PeerConn == nil {
+			continue
+		}
+		removed := false
+		for _, sender := range cl.PeerConn.GetSenders() {
+			if sender.Track() != webrtc.TrackLocal(track) {
+				continue
+			}
+			if err := cl.PeerConn.RemoveTrack(sender); err != nil {
+				log.Printf("RemoveTrack error for participant %d: %v", cl.UserID, err)
+				continue
+			}
+			removed = true
+		}
+		if removed {
+			go func(part *Client) {
+				if err := s.RenegotiateParticipant(part); err != nil {
+					log.Printf("renegotiate error for %d: %v", part.UserID, err)
+				}
+			}(cl)
+		}
+	}
+}
+
+// AddPublishedTracksToPeer adds all published tracks to userID's peer connection. Opus tracks are
+// left out for participants in mixing mode; they get the mixed track from syncMixedAudio instead.
+func (s *CallSession) AddPublishedTracksToPeer(pc *webrtc.PeerConnection, trackID string, userID uint) error {
 	s.Mu.RLock()
 	defer s.Mu.RUnlock()
 
 	for id, t := range s.PublishedTracks {
+		if s.mixesAudioFor(userID) && isCodec(t, webrtc.MimeTypeOpus) {
+			continue
+		}
 		// skip the track that is currently being processed (trackID param)
 		if id != trackID {
-			if _, err := pc.AddTrack(t); err != nil {
+			sender, err := pc.AddTrack(t)
+			if err != nil {
 				return err
 			}
+			go t.ServeRTCP(sender)
 		}
 	}
 	return nil
 }
 
 // MapMIDsForParticipant scans a participant PeerConnection's transceivers after negotiation,
-// matches sender.Track() pointers to PublishedTracks and builds a mid -> publisherId map.
-// It persists the mapping in session.TrackPublishers and sends one "mid-map" WS message to the participant.
+// matches sender.Track() pointers to PublishedTracks and builds a mid -> track info map.
+// It persists the mapping in session.TrackPublishers and sends the participant a "mid-map" WS message
+// (mid -> publisher id) followed by a track_map message (mid -> track info), so clients can lay out
+// screen shares apart from cameras.
 func (s *CallSession) MapMIDsForParticipant(participant *Client) {
 	pc := participant.PeerConn
 	if pc == nil || participant == nil {
@@ -148,15 +499,15 @@ func (s *CallSession) MapMIDsForParticipant(participant *Client) {
 	// build pointer -> trackID and trackID -> owner maps
 	s.Mu.RLock()
 	ptrToTrackID := make(map[string]string, len(s.PublishedTracks))
-	trackOwners := make(map[string]uint, len(s.PublishedTracks))
+	trackInfos := make(map[string]models.TrackInfo, len(s.PublishedTracks))
 	for id, tr := range s.PublishedTracks {
 		ptrToTrackID[fmt.Sprintf("%p", tr)] = id
-		trackOwners[id] = s.PublishedOwners[id]
+		trackInfos[id] = s.trackInfo(id)
 	}
 	s.Mu.RUnlock()
 
-	collectMidMap := func() map[string]uint {
-		midMap := make(map[string]uint)
+	collectMidMap := func() map[string]models.TrackInfo {
+		midMap := make(map[string]models.TrackInfo)
 		for _, t := range pc.GetTransceivers() {
 			if t == nil || t.Sender() == nil || t.Sender().Track() == nil {
 				continue
@@ -164,7 +515,7 @@ func (s *CallSession) MapMIDsForParticipant(participant *Client) {
 			trPtr := fmt.Sprintf("%p", t.Sender().Track())
 			if trackID, ok := ptrToTrackID[trPtr]; ok {
 				if mid := t.Mid(); mid != "" {
-					midMap[mid] = trackOwners[trackID]
+					midMap[mid] = trackInfos[trackID]
 				}
 			}
 		}
@@ -184,22 +535,25 @@ func (s *CallSession) MapMIDsForParticipant(participant *Client) {
 	}
 
 	// persist mapping
+	publishers := make(map[string]uint, len(midMap))
 	s.Mu.Lock()
-	for mid, uid := range midMap {
-		s.TrackPublishers[mid] = uid
+	for mid, info := range midMap {
+		s.TrackPublishers[mid] = info.PublisherId
+		publishers[mid] = info.PublisherId
 	}
 	s.Mu.Unlock()
 
-	// send consolidated mid map to participant
-	msg := models.WebSocketMessage{
-		Type:    "mid-map",
-		Payload: midMap,
-		Time:    time.Now(),
-	}
-	select {
-	case participant.Send <- msg:
-	default:
-		log.Printf("MapMIDsForParticipant: send channel full for user %d", participant.UserID)
+	// send consolidated mid maps to participant
+	now := time.Now()
+	for _, msg := range []models.WebSocketMessage{
+		{Type: "mid-map", Payload: publishers, Time: now},
+		{Type: models.MessageTypeTrackMap, Payload: midMap, Time: now},
+	} {
+		select {
+		case participant.Send <- msg:
+		default:
+			log.Printf("MapMIDsForParticipant: send channel full for user %d", participant.UserID)
+		}
 	}
 }
 
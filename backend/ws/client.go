@@ -4,14 +4,13 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"io"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/Neb-iyu/facetime-app/backend/models"
 	"github.com/gorilla/websocket"
-	"github.com/pion/interceptor"
-	"github.com/pion/interceptor/pkg/intervalpli"
+	"github.com/pion/interceptor/pkg/stats"
 	"github.com/pion/webrtc/v4"
 )
 
@@ -23,7 +22,15 @@ type Client struct {
 	Username        string
 	SessionID       string
 	IsAuthenticated bool
+	Guest           bool              // signed in with a guest token
+	GuestScope      models.GuestScope // for guests, the room or call the token is limited to
 	PeerConn        *webrtc.PeerConnection
+
+	lastActive  atomic.Int64                 // unix nanos of the last message read
+	autoAway    atomic.Bool                  // the user went away by idling, so activity brings them back
+	statsGetter atomic.Pointer[stats.Getter] // set by the stats interceptor, read by the stats ticker
+	// presence last sent to this client, by user id; guarded by Hub.Mutex
+	presenceSeen map[uint]models.UserStatusMessage
 }
 
 func (c *Client) WritePump() {
@@ -64,45 +71,40 @@ func (c *Client) ReadPump() {
 			}
 			break
 		}
+		msg.From = c.UserID
+		c.Hub.touch(c)
 		c.Hub.HandleMessage <- msg
 		log.Printf("Received message from client %s: %v", c.Username, msg)
 
 	}
 }
 
-func (c *Client) ProcessOffer(off json.RawMessage, callId uint) {
-	pcConfig := webrtc.Configuration{
-		ICEServers: []webrtc.ICEServer{
-			{
-				URLs: []string{"stun:stun.l.google.com:19302"},
-			},
-		},
-	}
-
-	offer := webrtc.SessionDescription{}
-	decode(off, &offer)
-	mediaEngine := &webrtc.MediaEngine{}
-	if err := mediaEngine.RegisterDefaultCodecs(); err != nil {
-		panic(err)
+// StatsGetter returns the stats interceptor of the client's peer connection, or nil before one exists.
+func (c *Client) StatsGetter() stats.Getter {
+	if g := c.statsGetter.Load(); g != nil {
+		return *g
 	}
+	return nil
+}
 
-	interceptorRegistry := &interceptor.Registry{}
-
-	if err := webrtc.RegisterDefaultInterceptors(mediaEngine, interceptorRegistry); err != nil {
-		panic(err)
+// ProcessOffer answers a participant's SDP offer for callId; callers must hold Hub.Mutex.
+// Failures are logged and leave the client without a peer connection.
+func (c *Client) ProcessOffer(off json.RawMessage, callId uint) {
+	session := c.Hub.CallSessions[callId]
+	if session == nil {
+		log.Printf("ProcessOffer: call %d session does not exist", callId)
+		return
 	}
-	intervalPliFactory, err := intervalpli.NewReceiverInterceptor()
-	if err != nil {
-		panic(err)
+	offer := webrtc.SessionDescription{}
+	if err := decode(off, &offer); err != nil {
+		log.Printf("ProcessOffer: invalid offer from user %d: %v", c.UserID, err)
+		return
 	}
-	interceptorRegistry.Add(intervalPliFactory)
 
-	peerConnection, err := webrtc.NewAPI(
-		webrtc.WithMediaEngine(mediaEngine),
-		webrtc.WithInterceptorRegistry(interceptorRegistry),
-	).NewPeerConnection(pcConfig)
+	peerConnection, err := newPeerConnection(peerConnectionConfig, func(g stats.Getter) { c.statsGetter.Store(&g) })
 	if err != nil {
-		panic(err)
+		log.Printf("ProcessOffer: peer connection for user %d: %v", c.UserID, err)
+		return
 	}
 	peerConnection.OnICECandidate(func(ic *webrtc.ICECandidate) {
 		if ic == nil {
@@ -122,57 +124,66 @@ func (c *Client) ProcessOffer(off json.RawMessage, callId uint) {
 	})
 
 	if _, err = peerConnection.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo); err != nil {
-		panic(err)
+		log.Printf("ProcessOffer: add transceiver for user %d: %v", c.UserID, err)
+		peerConnection.Close()
+		return
 	}
 
-	session := c.Hub.CallSessions[callId]
+	peerConnection.OnDataChannel(func(dc *webrtc.DataChannel) {
+		session.attachDataChannel(c.UserID, dc)
+	})
 
+	sources := offerTrackSources(off)
 	rTrack := ""
 	peerConnection.OnTrack(func(remoteTrack *webrtc.TrackRemote, reciever *webrtc.RTPReceiver) {
-		localTrack, newTrackErr := webrtc.NewTrackLocalStaticRTP(remoteTrack.Codec().RTPCodecCapability, "video", "pion")
-		if newTrackErr != nil {
-			panic(newTrackErr)
-		}
+		// keep the publisher's ids so subscribers can tell a screen share from the camera of the same stream
+		localTrack := NewForwardTrack(remoteTrack.Codec().RTPCodecCapability, remoteTrack.ID(), remoteTrack.StreamID())
+		localTrack.SetKeyframeRequester(pliRequester(peerConnection, remoteTrack.SSRC()))
+		source := trackSource(sources, remoteTrack.ID(), remoteTrack.Kind())
 
 		// publish the local track to Hub (key by remoteTrack.ID())
-		session.PublishTrack(c.UserID, remoteTrack.ID(), localTrack, true)
-		rTrack = remoteTrack.ID()
-		rtpBuf := make([]byte, 1400)
-		for {
-			i, _, readErr := remoteTrack.Read(rtpBuf)
-			if readErr != nil {
-				// stop forwarding on read error
-				if !errors.Is(readErr, io.EOF) {
-					log.Printf("remoteTrack read error: %v", readErr)
-				}
-				return
-			}
-
-			if _, err = localTrack.Write(rtpBuf[:i]); err != nil && !errors.Is(err, io.ErrClosedPipe) {
-				log.Printf("localTrack write error: %v", err)
-				return
+		if err := session.PublishTrack(c.UserID, remoteTrack.ID(), localTrack, source, true); err != nil {
+			if errors.Is(err, ErrScreenShareLimit) {
+				session.rejectScreenShare(c, remoteTrack.ID(), err)
+			} else {
+				log.Printf("track %s of user %d refused: %v", remoteTrack.ID(), c.UserID, err)
 			}
+			stopRefusedTrack(reciever)
+			return
 		}
+		rTrack = remoteTrack.ID()
+		forwardRTP(remoteTrack, localTrack)
+		session.unpublishTrack(remoteTrack.ID(), localTrack, 0)
 	})
 
 	err = peerConnection.SetRemoteDescription(offer)
 	if err != nil {
-		panic(err)
+		log.Printf("ProcessOffer: set remote description for user %d: %v", c.UserID, err)
+		peerConnection.Close()
+		return
 	}
 
-	session.AddPublishedTracksToPeer(peerConnection, rTrack)
+	session.AddPublishedTracksToPeer(peerConnection, rTrack, c.UserID)
+	if _, err := session.syncMixedAudio(c.UserID, peerConnection); err != nil {
+		log.Printf("audio mixing unavailable for user %d: %v", c.UserID, err)
+	}
 
 	answer, err := peerConnection.CreateAnswer(nil)
 	if err != nil {
-		panic(err)
+		log.Printf("ProcessOffer: create answer for user %d: %v", c.UserID, err)
+		peerConnection.Close()
+		return
 	}
 
 	gatherComplete := webrtc.GatheringCompletePromise(peerConnection)
 
 	err = peerConnection.SetLocalDescription(answer)
 	if err != nil {
-		panic(err)
+		log.Printf("ProcessOffer: set local description for user %d: %v", c.UserID, err)
+		peerConnection.Close()
+		return
 	}
+	session.markAnswered(c.UserID)
 
 	msg := models.WebSocketMessage{
 		Type:    "answer",
@@ -191,22 +202,20 @@ func (c *Client) ProcessOffer(off json.RawMessage, callId uint) {
 	//_ = c.Hub.AddPublishedTracksToPeer(peerConnection, callerId)
 }
 
-func decode(in json.RawMessage, obj *webrtc.SessionDescription) {
+func decode(in json.RawMessage, obj *webrtc.SessionDescription) error {
 	// try direct JSON first (expected)
 	if err := json.Unmarshal(in, obj); err == nil {
-		return
+		return nil
 	}
 
 	// fallback: maybe it's a quoted base64 string -> decode to string then base64-decode
 	var s string
 	if err := json.Unmarshal(in, &s); err != nil {
-		panic(err)
+		return err
 	}
 	b, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
-		panic(err)
-	}
-	if err := json.Unmarshal(b, obj); err != nil {
-		panic(err)
+		return err
 	}
+	return json.Unmarshal(b, obj)
 }
@@ -0,0 +1,154 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	// maxDataMessageSize bounds a single relayed message; larger messages are rejected.
+	maxDataMessageSize = 16 << 10
+
+	// chat messages are persisted as Message rows, which have at most one recipient, so chat goes to
+	// the whole call or to one participant; every other type is relayed only
+	dataMessageChat  = "chat"
+	dataMessageError = "error"
+)
+
+// attachDataChannel registers a data channel opened by a participant. Messages are relayed to the
+// other participants on their channel with the same label, so the ordering and reliability the
+// client chose for a label (e.g. an unordered, maxRetransmits=0 channel for cursors) apply end to end.
+func (s *CallSession) attachDataChannel(userID uint, dc *webrtc.DataChannel) {
+	dc.OnOpen(func() {
+		s.Mu.Lock()
+		defer s.Mu.Unlock()
+		if s.dataChannels[userID] == nil {
+			s.dataChannels[userID] = make(map[string]*webrtc.DataChannel)
+		}
+		s.dataChannels[userID][dc.Label()] = dc
+	})
+	dc.OnClose(func() {
+		s.Mu.Lock()
+		defer s.Mu.Unlock()
+		if s.dataChannels[userID][dc.Label()] == dc {
+			delete(s.dataChannels[userID], dc.Label())
+		}
+	})
+	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+		s.relayDataMessage(userID, dc, msg.Data)
+	})
+}
+
+// relayDataMessage validates a message from userID, persists chat and forwards it to its recipients.
+func (s *CallSession) relayDataMessage(userID uint, dc *webrtc.DataChannel, data []byte) {
+	if len(data) > maxDataMessageSize {
+		sendDataError(dc, "message too large")
+		return
+	}
+	var msg models.DataChannelMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type == "" {
+		sendDataError(dc, "invalid message")
+		return
+	}
+	msg.Id = 0
+	msg.Error = ""
+	msg.From = userID
+	msg.Time = time.Now()
+	if !s.participantsIn(msg.To) {
+		sendDataError(dc, "recipient is not in this call")
+		return
+	}
+
+	if msg.Type == dataMessageChat {
+		var body string
+		if err := json.Unmarshal(msg.Payload, &body); err != nil || body == "" {
+			sendDataError(dc, "chat payload must be a non-empty string")
+			return
+		}
+		if len(msg.To) > 1 {
+			sendDataError(dc, "chat goes to the whole call or a single participant")
+			return
+		}
+		callID := s.ID
+		row := models.Message{
+			SenderId:  userID,
+			CallId:    &callID,
+			Body:      body,
+			CreatedAt: msg.Time,
+		}
+		if len(msg.To) == 1 {
+			row.RecipientId = &msg.To[0]
+		}
+		if err := database.Db.Create(&row).Error; err != nil {
+			log.Printf("relayDataMessage: failed to save chat message: %v", err)
+			sendDataError(dc, "failed to save message")
+			return
+		}
+		msg.Id = row.Id
+	}
+
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	for _, ch := range s.dataChannelTargets(userID, dc.Label(), msg.To) {
+		if err := ch.SendText(string(out)); err != nil {
+			log.Printf("relayDataMessage: send on %q failed: %v", ch.Label(), err)
+		}
+	}
+	if msg.Id != 0 {
+		// echo persisted chat so the sender learns its id and server time
+		dc.SendText(string(out))
+	}
+}
+
+// participantsIn reports whether every user in ids is a participant of the call.
+func (s *CallSession) participantsIn(ids []uint) bool {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	for _, id := range ids {
+		if _, ok := s.Participants[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// dataChannelTargets picks one channel per recipient, preferring the sender's label.
+func (s *CallSession) dataChannelTargets(from uint, label string, to []uint) []*webrtc.DataChannel {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+
+	recipients := to
+	if len(recipients) == 0 {
+		for uid := range s.dataChannels {
+			recipients = append(recipients, uid)
+		}
+	}
+	out := make([]*webrtc.DataChannel, 0, len(recipients))
+	for _, uid := range recipients {
+		if uid == from {
+			continue
+		}
+		chans := s.dataChannels[uid]
+		if ch, ok := chans[label]; ok {
+			out = append(out, ch)
+			continue
+		}
+		for _, ch := range chans {
+			out = append(out, ch)
+			break
+		}
+	}
+	return out
+}
+
+func sendDataError(dc *webrtc.DataChannel, reason string) {
+	b, _ := json.Marshal(models.DataChannelMessage{Type: dataMessageError, Error: reason, Time: time.Now()})
+	dc.SendText(string(b))
+}
@@ -0,0 +1,162 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/webrtc/v4"
+)
+
+// dataChannelPeer connects a client data channel labelled label to s as userID over loopback and
+// returns the client's end, with every message it receives sent on the returned channel.
+func dataChannelPeer(t *testing.T, s *CallSession, userID uint, label string) (*webrtc.DataChannel, <-chan models.DataChannelMessage) {
+	t.Helper()
+	var se webrtc.SettingEngine
+	se.SetIncludeLoopbackCandidate(true)
+	se.SetNetworkTypes([]webrtc.NetworkType{webrtc.NetworkTypeUDP4})
+	api := webrtc.NewAPI(webrtc.WithSettingEngine(se))
+	client, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	server.OnDataChannel(func(dc *webrtc.DataChannel) { s.attachDataChannel(userID, dc) })
+	dc, err := client.CreateDataChannel(label, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan models.DataChannelMessage, 16)
+	dc.OnMessage(func(m webrtc.DataChannelMessage) {
+		var msg models.DataChannelMessage
+		if err := json.Unmarshal(m.Data, &msg); err == nil {
+			received <- msg
+		}
+	})
+
+	offer, err := client.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered := webrtc.GatheringCompletePromise(client)
+	if err := client.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+	if err := server.SetRemoteDescription(*client.LocalDescription()); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := server.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered = webrtc.GatheringCompletePromise(server)
+	if err := server.SetLocalDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+	if err := client.SetRemoteDescription(*server.LocalDescription()); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		s.Mu.RLock()
+		open := s.dataChannels[userID][label] != nil
+		s.Mu.RUnlock()
+		if open && dc.ReadyState() == webrtc.DataChannelStateOpen {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("data channel did not open")
+		}
+	}
+	return dc, received
+}
+
+// receive waits for the next message on ch.
+func receive(t *testing.T, ch <-chan models.DataChannelMessage) models.DataChannelMessage {
+	t.Helper()
+	select {
+	case m := <-ch:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no data channel message")
+		return models.DataChannelMessage{}
+	}
+}
+
+// expectNothing fails if a message arrives on ch within a short wait.
+func expectNothing(t *testing.T, ch <-chan models.DataChannelMessage, who string) {
+	t.Helper()
+	select {
+	case m := <-ch:
+		t.Errorf("%s unexpectedly got %+v", who, m)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestDataChannelRelayAndChat(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "alice", "bob", "carol", "eve")
+	alice, bob, carol, eve := users[0], users[1], users[2], users[3]
+	_, s := moderatedCall(t, users[:3])
+
+	aliceDC, aliceIn := dataChannelPeer(t, s, alice, "chat")
+	_, bobIn := dataChannelPeer(t, s, bob, "chat")
+	_, carolIn := dataChannelPeer(t, s, carol, "cursors")
+
+	// other types are relayed to everyone without being saved
+	if err := aliceDC.SendText(`{"type":"cursor","payload":{"x":1}}`); err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range []<-chan models.DataChannelMessage{bobIn, carolIn} {
+		if m := receive(t, in); m.Type != "cursor" || m.From != alice || m.Id != 0 {
+			t.Errorf("relayed %+v, want alice's cursor", m)
+		}
+	}
+
+	// private chat is saved, echoed to the sender and only reaches its recipient
+	if err := aliceDC.SendText(fmt.Sprintf(`{"type":"chat","to":[%d],"payload":"hi bob"}`, bob)); err != nil {
+		t.Fatal(err)
+	}
+	got := receive(t, bobIn)
+	if got.Type != "chat" || got.Id == 0 {
+		t.Fatalf("bob got %+v, want a saved chat message", got)
+	}
+	if echo := receive(t, aliceIn); echo.Id != got.Id {
+		t.Errorf("alice's echo has id %d, want %d", echo.Id, got.Id)
+	}
+	expectNothing(t, carolIn, "carol")
+	var row models.Message
+	if err := database.Db.First(&row, got.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.Body != "hi bob" || row.SenderId != alice || row.CallId == nil || *row.CallId != s.ID ||
+		row.RecipientId == nil || *row.RecipientId != bob {
+		t.Errorf("saved %+v", row)
+	}
+
+	// someone outside the call is refused before anything is saved
+	if err := aliceDC.SendText(fmt.Sprintf(`{"type":"chat","to":[%d],"payload":"psst"}`, eve)); err != nil {
+		t.Fatal(err)
+	}
+	if m := receive(t, aliceIn); m.Type != dataMessageError {
+		t.Errorf("alice got %+v, want an error", m)
+	}
+	var n int64
+	database.Db.Model(&models.Message{}).Count(&n)
+	if n != 1 {
+		t.Errorf("%d messages saved, want 1", n)
+	}
+	expectNothing(t, bobIn, "bob")
+}
@@ -0,0 +1,179 @@
+package ws
+
+import (
+	"log"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+const (
+	// DefaultForwardAfter is how long an unanswered call rings before it is forwarded.
+	DefaultForwardAfter = 20 * time.Second
+	MaxForwardAfter     = 2 * time.Minute
+)
+
+// forwardRules loads userID's forwarding rules keyed by condition.
+func forwardRules(userID uint) map[models.ForwardCondition]models.CallForwardRule {
+	var rules []models.CallForwardRule
+	if err := database.Db.Where("user_id = ?", userID).Find(&rules).Error; err != nil {
+		log.Printf("forwardRules: failed to load rules of user %d: %v", userID, err)
+		return nil
+	}
+	out := make(map[models.ForwardCondition]models.CallForwardRule, len(rules))
+	for _, r := range rules {
+		out[r.Condition] = r
+	}
+	return out
+}
+
+// Ring sends incoming_call to every callee of the session, applying blocks, do-not-disturb and
+// their forwarding rules; every call rings through here. Forwarding goes one hop only: the
+// target's own rules are not consulted, so rules can't loop. Callers must not hold h.Mutex:
+// blocks, rules and favorites are loaded before it is taken.
+func (h *Hub) Ring(session *CallSession) {
+	session.Mu.RLock()
+	call := session.Call
+	session.Mu.RUnlock()
+
+	type callee struct {
+		id       uint
+		rules    map[models.ForwardCondition]models.CallForwardRule
+		favorite bool
+	}
+	callees := make([]callee, 0, len(call.CalleeIds))
+	for _, id := range call.CalleeIds {
+		if BlockedBetween(id, call.CallerId) {
+			log.Printf("Call %d not rung for user %d: blocked", call.Id, id)
+			continue
+		}
+		c := callee{id: id, favorite: isFavorite(id, call.CallerId)}
+		// a transfer is meant for its target, so it isn't forwarded
+		if call.TransferredFrom == nil {
+			c.rules = forwardRules(id)
+		}
+		callees = append(callees, c)
+	}
+
+	type forward struct {
+		id   uint
+		rule models.CallForwardRule
+	}
+	var forwards []forward
+	var held []uint
+	unavailable := make(map[uint]models.UnavailableReason)
+
+	h.Mutex.RLock()
+	for _, c := range callees {
+		id, rules := c.id, c.rules
+		if r, ok := rules[models.ForwardAlways]; ok {
+			forwards = append(forwards, forward{id, r})
+			continue
+		}
+		// do-not-disturb holds the call back without telling the caller; they are offered
+		// voicemail as if it went unanswered
+		if h.holdsCall(id, c.favorite) {
+			held = append(held, id)
+			continue
+		}
+		cl, connected := h.UserClients[id]
+		status := h.UserStatuses[id]
+		if !connected || status == nil || status.Status != models.Online {
+			if r, ok := rules[models.ForwardBusy]; ok && connected && status != nil && status.Status == models.Busy {
+				forwards = append(forwards, forward{id, r})
+			} else if r, ok := rules[models.ForwardUnanswered]; ok && !connected {
+				forwards = append(forwards, forward{id, r})
+			} else {
+				log.Printf("User %d is not available for call %d", id, call.Id)
+				unavailable[id] = models.CalleeOffline
+				if connected && status != nil && status.Status == models.Busy {
+					unavailable[id] = models.CalleeBusy
+				}
+			}
+			continue
+		}
+
+		select {
+		case cl.Send <- models.WebSocketMessage{Type: "incoming_call", Payload: call, Time: time.Now()}:
+		default:
+			log.Printf("Ring: send channel full for user %d", id)
+		}
+		if r, ok := rules[models.ForwardUnanswered]; ok {
+			after := time.Duration(r.AfterSeconds) * time.Second
+			if after <= 0 || after > MaxForwardAfter {
+				after = DefaultForwardAfter
+			}
+			time.AfterFunc(after, func() { h.forwardIfUnanswered(session, id, r) })
+		} else {
+			time.AfterFunc(unansweredAfter, func() { h.reportIfUnanswered(session, id) })
+		}
+	}
+	h.Mutex.RUnlock()
+
+	for _, f := range forwards {
+		h.forwardCall(session, f.id, f.rule)
+	}
+	for _, id := range held {
+		log.Printf("User %d is in do-not-disturb, call %d logged as missed", id, call.Id)
+		if err := database.Db.Create(&models.History{
+			UserId: id, CallId: call.Id, Status: models.Missed, Role: "callee", EndTime: time.Now(),
+		}).Error; err != nil {
+			log.Printf("Ring: failed to record missed call %d: %v", call.Id, err)
+		}
+		time.AfterFunc(unansweredAfter, func() { h.reportIfUnanswered(session, id) })
+	}
+	for id, reason := range unavailable {
+		h.notifyUnavailable(call, id, reason)
+	}
+}
+
+// forwardIfUnanswered forwards the call if calleeID still hasn't picked up.
+func (h *Hub) forwardIfUnanswered(session *CallSession, calleeID uint, rule models.CallForwardRule) {
+	h.Mutex.RLock()
+	ringing := h.CallSessions[session.ID] == session && !session.Answered(calleeID)
+	h.Mutex.RUnlock()
+	if ringing {
+		h.forwardCall(session, calleeID, rule)
+	}
+}
+
+// forwardCall rings rule's target in place of calleeID, tells the caller, and records the
+// forward in calleeID's history. Callers must not hold h.Mutex.
+func (h *Hub) forwardCall(session *CallSession, calleeID uint, rule models.CallForwardRule) {
+	target := rule.TargetId
+	session.Mu.RLock()
+	callerID := session.Call.CallerId
+	session.Mu.RUnlock()
+	if BlockedBetween(target, callerID) {
+		return
+	}
+	session.Mu.Lock()
+	if target == session.hostID() {
+		session.Mu.Unlock()
+		return
+	}
+	if !session.invited(target) {
+		session.Call.CalleeIds = append(session.Call.CalleeIds, target)
+	}
+	call := session.Call
+	session.Mu.Unlock()
+
+	if err := database.Db.Create(&models.History{
+		UserId:        calleeID,
+		CallId:        call.Id,
+		Status:        models.Forwarded,
+		Role:          "callee",
+		EndTime:       time.Now(),
+		TransferredTo: &target,
+	}).Error; err != nil {
+		log.Printf("forwardCall: failed to record history for call %d: %v", call.Id, err)
+	}
+
+	h.SendToUser(target, models.WebSocketMessage{Type: "incoming_call", Payload: call, Time: time.Now()})
+	h.SendToUser(call.CallerId, models.WebSocketMessage{
+		Type:    models.MessageTypeCallForwarded,
+		Payload: models.CallForwardedMessage{CallId: call.Id, UserId: calleeID, ForwardedTo: target, Condition: rule.Condition},
+		Time:    time.Now(),
+	})
+}
@@ -0,0 +1,127 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+// clientMessage builds msg as the hub receives it from a client: the payload is decoded JSON.
+func clientMessage(t *testing.T, from uint, typ models.WSMessageType, payload string) models.WebSocketMessage {
+	t.Helper()
+	var msg models.WebSocketMessage
+	if err := json.Unmarshal([]byte(fmt.Sprintf(`{"type":%q,"payload":%s}`, typ, payload)), &msg); err != nil {
+		t.Fatal(err)
+	}
+	msg.From = from
+	return msg
+}
+
+func TestIncomingCallRingsAndForwards(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "caller", "bob", "carol", "dave")
+	caller, bob, carol, dave := users[0], users[1], users[2], users[3]
+	database.Db.Create(&models.CallForwardRule{UserId: carol, Condition: models.ForwardAlways, TargetId: dave})
+
+	h := NewHub()
+	clients := make(map[uint]*Client)
+	for _, uid := range users {
+		clients[uid] = testClient(h, uid)
+	}
+	call := models.Call{CallerId: caller, CalleeIds: []uint{bob, carol}, Status: models.Ringing}
+	database.Db.Create(&call)
+	h.CreateCallSession(&call)
+	payload := fmt.Sprintf(`{"callId":%d,"userId":%d}`, call.Id, caller)
+
+	// only the caller can ring the callees
+	h.handleMessage(clientMessage(t, bob, "incoming_call", payload))
+	for _, uid := range users {
+		if n := len(drain(clients[uid])); n != 0 {
+			t.Fatalf("user %d got %d messages after an impostor rang", uid, n)
+		}
+	}
+
+	h.handleMessage(clientMessage(t, caller, "incoming_call", payload))
+	for uid, want := range map[uint]int{bob: 1, carol: 0, dave: 1} {
+		if got := len(messagesOfType(drain(clients[uid]), "incoming_call")); got != want {
+			t.Errorf("user %d got %d incoming_call, want %d", uid, got, want)
+		}
+	}
+	if got := messagesOfType(drain(clients[caller]), models.MessageTypeCallForwarded); len(got) != 1 {
+		t.Errorf("caller got %d call_forwarded, want 1", len(got))
+	}
+	var forwarded int64
+	database.Db.Model(&models.History{}).Where("call_id = ? AND user_id = ? AND status = ?", call.Id, carol, models.Forwarded).Count(&forwarded)
+	if forwarded != 1 {
+		t.Errorf("%d forwarded history rows for carol, want 1", forwarded)
+	}
+}
+
+func TestCallRejectedActsForSender(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "caller", "bob", "carol")
+	caller, bob, carol := users[0], users[1], users[2]
+	h := NewHub()
+	for _, uid := range users {
+		testClient(h, uid)
+	}
+	call := models.Call{CallerId: caller, CalleeIds: []uint{bob, carol}, Status: models.Ringing}
+	database.Db.Create(&call)
+	s := h.CreateCallSession(&call)
+
+	// bob claims to reject on carol's behalf; the rejection is his
+	h.handleMessage(clientMessage(t, bob, "call_rejected", fmt.Sprintf(`{"callId":%d,"userId":%d}`, call.Id, carol)))
+
+	var missed []models.History
+	database.Db.Where("call_id = ? AND status = ?", call.Id, models.Missed).Find(&missed)
+	if len(missed) != 1 || missed[0].UserId != bob {
+		t.Fatalf("missed history = %+v, want one row for bob", missed)
+	}
+	if s.HasParticipant(bob) || !s.HasParticipant(carol) {
+		t.Errorf("bob still in call: %v, carol in call: %v", s.HasParticipant(bob), s.HasParticipant(carol))
+	}
+}
+
+func TestBlockedUsersAreNotRung(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "caller", "bob", "carol", "dave")
+	caller, bob, carol, dave := users[0], users[1], users[2], users[3]
+	database.Db.Create(&models.UserBlock{UserId: bob, BlockedId: caller})
+	database.Db.Create(&models.UserBlock{UserId: caller, BlockedId: dave})
+	// carol forwards to dave, whom the caller blocked
+	database.Db.Create(&models.CallForwardRule{UserId: carol, Condition: models.ForwardAlways, TargetId: dave})
+
+	h := NewHub()
+	clients := make(map[uint]*Client)
+	for _, uid := range users {
+		clients[uid] = testClient(h, uid)
+	}
+	call := models.Call{CallerId: caller, CalleeIds: []uint{bob, carol}, Status: models.Ringing}
+	database.Db.Create(&call)
+	s := h.CreateCallSession(&call)
+	h.Ring(s)
+
+	for _, uid := range []uint{bob, carol, dave} {
+		if n := len(messagesOfType(drain(clients[uid]), "incoming_call")); n != 0 {
+			t.Errorf("user %d got %d incoming_call", uid, n)
+		}
+	}
+
+	h.handleMessage(clientMessage(t, caller, "add_callee", fmt.Sprintf(`{"callId":%d,"userId":%d}`, call.Id, dave)))
+	if n := len(messagesOfType(drain(clients[dave]), "incoming_call")); n != 0 {
+		t.Errorf("blocked dave was added to the call")
+	}
+	if s.HasParticipant(dave) {
+		t.Error("blocked dave is a participant")
+	}
+
+	erin := testUsers(t, "erin")[0]
+	ce := testClient(h, erin)
+	h.handleMessage(clientMessage(t, caller, "add_callee", fmt.Sprintf(`{"callId":%d,"userId":%d}`, call.Id, erin)))
+	if n := len(messagesOfType(drain(ce), "incoming_call")); n != 1 {
+		t.Errorf("erin got %d incoming_call when added, want 1", n)
+	}
+}
@@ -0,0 +1,295 @@
+package ws
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// forwardBinding is one subscriber PeerConnection a ForwardTrack is bound to.
+type forwardBinding struct {
+	id                          string
+	ssrc, ssrcRTX               webrtc.SSRC
+	payloadType, payloadTypeRTX webrtc.PayloadType
+	writeStream                 webrtc.TrackLocalWriter
+	rtxSeq                      uint16
+	retransmits                 uint64
+}
+
+// ForwardTrack is a TrackLocal that forwards a publisher's RTP packets to every bound subscriber.
+// Unlike webrtc.TrackLocalStaticRTP it keeps a packet cache so NACKs from a single subscriber can
+// be answered for that subscriber only, using RTX when it was negotiated.
+type ForwardTrack struct {
+	mu       sync.RWMutex
+	bindings []*forwardBinding
+	codec    webrtc.RTPCodecCapability
+	id       string
+	streamID string
+	cache    *packetCache
+	frames   atomic.Uint64
+	stopped  atomic.Bool                  // set by a moderator; packets are dropped until cleared
+	keyframe atomic.Pointer[func() error] // asks the publisher for a keyframe, when known
+	sinks    map[string]media.Writer      // e.g. recorders, keyed by the attaching component
+}
+
+// NewForwardTrack constructs a ForwardTrack for the given codec.
+func NewForwardTrack(codec webrtc.RTPCodecCapability, id, streamID string) *ForwardTrack {
+	return &ForwardTrack{
+		codec:    codec,
+		id:       id,
+		streamID: streamID,
+		cache:    newPacketCache(),
+		sinks:    make(map[string]media.Writer),
+	}
+}
+
+// Bind is called by the PeerConnection once negotiation has picked a codec for this track.
+func (t *ForwardTrack) Bind(ctx webrtc.TrackLocalContext) (webrtc.RTPCodecParameters, error) {
+	codec, ok := matchCodec(t.codec, ctx.CodecParameters())
+	if !ok {
+		return webrtc.RTPCodecParameters{}, webrtc.ErrUnsupportedCodec
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bindings = append(t.bindings, &forwardBinding{
+		id:             ctx.ID(),
+		ssrc:           ctx.SSRC(),
+		ssrcRTX:        ctx.SSRCRetransmission(),
+		payloadType:    codec.PayloadType,
+		payloadTypeRTX: findRTXPayloadType(codec.PayloadType, ctx.CodecParameters()),
+		writeStream:    ctx.WriteStream(),
+	})
+	return codec, nil
+}
+
+// Unbind removes the subscriber binding when its sender is stopped.
+func (t *ForwardTrack) Unbind(ctx webrtc.TrackLocalContext) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, b := range t.bindings {
+		if b.id == ctx.ID() {
+			t.bindings = append(t.bindings[:i], t.bindings[i+1:]...)
+			return nil
+		}
+	}
+	return webrtc.ErrUnbindFailed
+}
+
+func (t *ForwardTrack) ID() string       { return t.id }
+func (t *ForwardTrack) RID() string      { return "" }
+func (t *ForwardTrack) StreamID() string { return t.streamID }
+
+// Kind reports whether this track carries audio or video.
+func (t *ForwardTrack) Kind() webrtc.RTPCodecType {
+	switch {
+	case strings.HasPrefix(t.codec.MimeType, "audio/"):
+		return webrtc.RTPCodecTypeAudio
+	case strings.HasPrefix(t.codec.MimeType, "video/"):
+		return webrtc.RTPCodecTypeVideo
+	default:
+		return webrtc.RTPCodecType(0)
+	}
+}
+
+// Codec returns the codec the publisher is sending.
+func (t *ForwardTrack) Codec() webrtc.RTPCodecCapability {
+	return t.codec
+}
+
+// WriteRTP caches pkt for retransmission and sends it to every subscriber.
+func (t *ForwardTrack) WriteRTP(pkt *rtp.Packet) error {
+	if t.stopped.Load() {
+		return nil
+	}
+	t.cache.Push(pkt)
+	if pkt.Marker && t.Kind() == webrtc.RTPCodecTypeVideo {
+		t.frames.Add(1)
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for name, sink := range t.sinks {
+		if err := sink.WriteRTP(pkt); err != nil {
+			log.Printf("ForwardTrack %s: sink %s write error: %v", t.id, name, err)
+		}
+	}
+
+	var errs []error
+	for _, b := range t.bindings {
+		header := pkt.Header
+		header.SSRC = uint32(b.ssrc)
+		header.PayloadType = uint8(b.payloadType)
+		if _, err := b.writeStream.WriteRTP(&header, pkt.Payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AddSink tees every packet forwarded on this track into w until RemoveSink is called.
+func (t *ForwardTrack) AddSink(name string, w media.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sinks[name] = w
+}
+
+// RemoveSink detaches a sink; it does not close it.
+func (t *ForwardTrack) RemoveSink(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.sinks, name)
+}
+
+// SetStopped stops or resumes forwarding without unpublishing, so subscribers keep their
+// transceivers and need no renegotiation either way.
+func (t *ForwardTrack) SetStopped(stopped bool) {
+	t.stopped.Store(stopped)
+}
+
+// SetKeyframeRequester sets how to ask the publisher for a keyframe, usually by sending a PLI.
+func (t *ForwardTrack) SetKeyframeRequester(fn func() error) {
+	t.keyframe.Store(&fn)
+}
+
+// RequestKeyframe asks the publisher of a video track for a keyframe, so subscribers can decode
+// again after a gap such as a moderator stopping the track.
+func (t *ForwardTrack) RequestKeyframe() {
+	fn := t.keyframe.Load()
+	if fn == nil || t.Kind() != webrtc.RTPCodecTypeVideo {
+		return
+	}
+	if err := (*fn)(); err != nil {
+		log.Printf("ForwardTrack %s: keyframe request failed: %v", t.id, err)
+	}
+}
+
+// pliRequester returns a keyframe requester that sends a PLI for the remote track ssrc over pc.
+func pliRequester(pc *webrtc.PeerConnection, ssrc webrtc.SSRC) func() error {
+	return func() error {
+		return pc.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: uint32(ssrc)}})
+	}
+}
+
+// Stopped reports whether forwarding was stopped with SetStopped.
+func (t *ForwardTrack) Stopped() bool {
+	return t.stopped.Load()
+}
+
+// Frames returns the number of complete video frames forwarded so far.
+func (t *ForwardTrack) Frames() uint64 {
+	return t.frames.Load()
+}
+
+// Retransmissions returns how many packets were resent to the subscriber bound with ssrc.
+func (t *ForwardTrack) Retransmissions(ssrc webrtc.SSRC) uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, b := range t.bindings {
+		if b.ssrc == ssrc {
+			return b.retransmits
+		}
+	}
+	return 0
+}
+
+// ServeRTCP reads RTCP from a subscriber's sender for this track and answers NACKs from the cache.
+// It returns once the sender is stopped.
+func (t *ForwardTrack) ServeRTCP(sender *webrtc.RTPSender) {
+	for {
+		pkts, _, err := sender.ReadRTCP()
+		if err != nil {
+			return
+		}
+		for _, p := range pkts {
+			if nack, ok := p.(*rtcp.TransportLayerNack); ok {
+				t.retransmit(nack)
+			}
+		}
+	}
+}
+
+func (t *ForwardTrack) retransmit(nack *rtcp.TransportLayerNack) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var b *forwardBinding
+	for _, cand := range t.bindings {
+		if uint32(cand.ssrc) == nack.MediaSSRC {
+			b = cand
+			break
+		}
+	}
+	if b == nil {
+		return
+	}
+
+	for _, pair := range nack.Nacks {
+		pair.Range(func(seq uint16) bool {
+			pkt := t.cache.Get(seq)
+			if pkt == nil {
+				return true
+			}
+			header := pkt.Header
+			payload := pkt.Payload
+			if b.ssrcRTX != 0 && b.payloadTypeRTX != 0 {
+				// RFC 4588: the RTX payload is the original sequence number followed by the original payload.
+				rtxPayload := make([]byte, 2+len(pkt.Payload))
+				binary.BigEndian.PutUint16(rtxPayload, seq)
+				copy(rtxPayload[2:], pkt.Payload)
+				header.SSRC = uint32(b.ssrcRTX)
+				header.PayloadType = uint8(b.payloadTypeRTX)
+				header.SequenceNumber = b.rtxSeq
+				b.rtxSeq++
+				payload = rtxPayload
+			} else {
+				header.SSRC = uint32(b.ssrc)
+				header.PayloadType = uint8(b.payloadType)
+			}
+			if _, err := b.writeStream.WriteRTP(&header, payload); err == nil {
+				b.retransmits++
+			}
+			return true
+		})
+	}
+}
+
+// matchCodec picks the negotiated codec matching the publisher's codec, preferring an exact fmtp match.
+func matchCodec(needle webrtc.RTPCodecCapability, haystack []webrtc.RTPCodecParameters) (webrtc.RTPCodecParameters, bool) {
+	var fallback *webrtc.RTPCodecParameters
+	for i, c := range haystack {
+		if !strings.EqualFold(c.MimeType, needle.MimeType) || c.ClockRate != needle.ClockRate || c.Channels != needle.Channels {
+			continue
+		}
+		if c.SDPFmtpLine == needle.SDPFmtpLine {
+			return c, true
+		}
+		if fallback == nil {
+			fallback = &haystack[i]
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return webrtc.RTPCodecParameters{}, false
+}
+
+func findRTXPayloadType(pt webrtc.PayloadType, haystack []webrtc.RTPCodecParameters) webrtc.PayloadType {
+	apt := fmt.Sprintf("apt=%d", pt)
+	for _, c := range haystack {
+		if strings.EqualFold(c.MimeType, webrtc.MimeTypeRTX) && c.SDPFmtpLine == apt {
+			return c.PayloadType
+		}
+	}
+	return 0
+}
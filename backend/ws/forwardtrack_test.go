@@ -0,0 +1,98 @@
+package ws
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+// recordingWriter is a subscriber's write stream that keeps what it was sent.
+type recordingWriter struct {
+	headers  []rtp.Header
+	payloads [][]byte
+}
+
+func (w *recordingWriter) WriteRTP(header *rtp.Header, payload []byte) (int, error) {
+	w.headers = append(w.headers, *header)
+	w.payloads = append(w.payloads, append([]byte(nil), payload...))
+	return len(payload), nil
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+func TestPacketCache(t *testing.T) {
+	c := newPacketCache()
+	pkt := &rtp.Packet{Header: rtp.Header{SequenceNumber: 5}, Payload: []byte{1, 2, 3}}
+	c.Push(pkt)
+	pkt.Payload[0] = 9 // the cache keeps its own copy
+
+	if got := c.Get(5); got == nil || got.Payload[0] != 1 {
+		t.Fatalf("Get(5) = %+v, want the pushed packet", got)
+	}
+	if got := c.Get(6); got != nil {
+		t.Errorf("Get(6) = %+v, want nil", got)
+	}
+	c.Push(&rtp.Packet{Header: rtp.Header{SequenceNumber: 5 + packetCacheSize}})
+	if got := c.Get(5); got != nil {
+		t.Errorf("Get(5) after the ring wrapped = %+v, want nil", got)
+	}
+}
+
+func TestRetransmitAnswersOnlyTheNackingSubscriber(t *testing.T) {
+	track := vp8Track("cam")
+	rtx, plain := &recordingWriter{}, &recordingWriter{}
+	track.bindings = []*forwardBinding{
+		{id: "rtx", ssrc: 10, ssrcRTX: 11, payloadType: 96, payloadTypeRTX: 97, writeStream: rtx},
+		{id: "plain", ssrc: 20, payloadType: 100, writeStream: plain},
+	}
+	for seq := uint16(1); seq <= 3; seq++ {
+		if err := track.WriteRTP(&rtp.Packet{Header: rtp.Header{SequenceNumber: seq}, Payload: []byte{byte(seq)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(rtx.headers) != 3 || len(plain.headers) != 3 {
+		t.Fatalf("forwarded %d and %d packets, want 3 each", len(rtx.headers), len(plain.headers))
+	}
+
+	// seq 7 was never sent and is skipped
+	track.retransmit(&rtcp.TransportLayerNack{MediaSSRC: 10, Nacks: []rtcp.NackPair{{PacketID: 2}, {PacketID: 7}}})
+	if len(rtx.headers) != 4 || len(plain.headers) != 3 {
+		t.Fatalf("after a NACK from the RTX subscriber: %d and %d packets, want 4 and 3", len(rtx.headers), len(plain.headers))
+	}
+	h, p := rtx.headers[3], rtx.payloads[3]
+	if h.SSRC != 11 || h.PayloadType != 97 || binary.BigEndian.Uint16(p) != 2 || p[2] != 2 {
+		t.Errorf("RTX retransmission header %+v payload %v, want ssrc 11, pt 97, original seq 2", h, p)
+	}
+
+	track.retransmit(&rtcp.TransportLayerNack{MediaSSRC: 20, Nacks: []rtcp.NackPair{{PacketID: 1}}})
+	if len(plain.headers) != 4 {
+		t.Fatalf("plain subscriber got %d packets, want 4", len(plain.headers))
+	}
+	if h := plain.headers[3]; h.SSRC != 20 || h.PayloadType != 100 || h.SequenceNumber != 1 {
+		t.Errorf("plain retransmission header %+v, want the original packet on ssrc 20", h)
+	}
+	if track.Retransmissions(10) != 1 || track.Retransmissions(20) != 1 {
+		t.Errorf("retransmissions %d and %d, want 1 each", track.Retransmissions(10), track.Retransmissions(20))
+	}
+}
+
+func TestMatchCodec(t *testing.T) {
+	offered := []webrtc.RTPCodecParameters{
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000, SDPFmtpLine: "profile-level-id=640032"}, PayloadType: 102},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000, SDPFmtpLine: "profile-level-id=42e01f"}, PayloadType: 106},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeRTX, ClockRate: 90000, SDPFmtpLine: "apt=106"}, PayloadType: 107},
+	}
+	got, ok := matchCodec(webrtc.RTPCodecCapability{MimeType: "video/h264", ClockRate: 90000, SDPFmtpLine: "profile-level-id=42e01f"}, offered)
+	if !ok || got.PayloadType != 106 {
+		t.Errorf("matchCodec = %d, %v, want the exact fmtp match 106", got.PayloadType, ok)
+	}
+	if pt := findRTXPayloadType(106, offered); pt != 107 {
+		t.Errorf("RTX payload type for 106 = %d, want 107", pt)
+	}
+	if _, ok := matchCodec(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, offered); ok {
+		t.Error("matched VP8 against H264 only")
+	}
+}
@@ -0,0 +1,93 @@
+package ws
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+// guestErrors counts the ErrGuestNotAllowed errors queued for c.
+func guestErrors(c *Client) int {
+	n := 0
+	for _, m := range messagesOfType(drain(c), models.MessageTypeError) {
+		if m.Payload.(map[string]string)["error"] == ErrGuestNotAllowed.Error() {
+			n++
+		}
+	}
+	return n
+}
+
+func TestGuestsStayInTheirCall(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "host", "bob", "guest")
+	host, bob, guest := users[0], users[1], users[2]
+	h, s := moderatedCall(t, users[:2])
+	other := models.Call{CallerId: bob, Status: models.Ongoing}
+	database.Db.Create(&other)
+	h.CreateCallSession(&other)
+	gc := testClient(h, guest)
+	gc.Guest = true
+	gc.GuestScope = models.GuestScope{CallId: s.ID}
+	bc := h.UserClients[bob]
+	drain(bc)
+
+	for _, m := range []models.WebSocketMessage{
+		clientMessage(t, guest, "incoming_call", fmt.Sprintf(`{"callId":%d}`, s.ID)),
+		clientMessage(t, guest, "add_callee", fmt.Sprintf(`{"callId":%d,"userId":%d}`, s.ID, bob)),
+		clientMessage(t, guest, models.MessageTypeTransfer, fmt.Sprintf(`{"callId":%d,"targetId":%d}`, s.ID, bob)),
+		clientMessage(t, guest, "whatever", fmt.Sprintf(`{"callId":%d}`, s.ID)),
+		clientMessage(t, guest, models.MessageTypeRaiseHand, fmt.Sprintf(`{"callId":%d,"raised":true}`, other.Id)),
+		clientMessage(t, guest, "call_offer", fmt.Sprintf(`{"callId":%d,"offer":{}}`, other.Id)),
+	} {
+		h.handleMessage(m)
+		if n := guestErrors(gc); n != 1 {
+			t.Errorf("guest sending %s: %d refusals, want 1", m.Type, n)
+		}
+	}
+	if msgs := drain(bc); len(msgs) != 0 {
+		t.Errorf("bob received %+v from the guest", msgs)
+	}
+
+	for _, m := range []models.WebSocketMessage{
+		clientMessage(t, guest, models.MessageTypeRaiseHand, fmt.Sprintf(`{"callId":%d,"raised":true}`, s.ID)),
+		clientMessage(t, guest, "ice-candidate", fmt.Sprintf(`{"callId":%d,"candidate":{"candidate":""}}`, s.ID)),
+	} {
+		if !h.guestMaySend(m) {
+			t.Errorf("guest may not send %s in their own call", m.Type)
+		}
+	}
+	if !h.guestMaySend(clientMessage(t, host, "whatever", `{}`)) {
+		t.Error("a regular user's broadcast was refused")
+	}
+}
+
+func TestRoomGuestsStayInTheRoomsCall(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "owner", "guest")
+	owner, guest := users[0], users[1]
+	room := models.Room{Slug: "standup", Name: "standup", OwnerId: owner}
+	database.Db.Create(&room)
+	h := NewHub()
+	testClient(h, owner)
+	gc := testClient(h, guest)
+	gc.Guest = true
+	gc.GuestScope = models.GuestScope{RoomId: room.Id}
+
+	s, _, err := h.JoinRoom(room, models.User{Id: owner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := models.Call{CallerId: owner, Status: models.Ongoing}
+	database.Db.Create(&other)
+	h.CreateCallSession(&other)
+
+	reaction := `{"callId":%d,"emoji":"👍"}`
+	if !h.guestMaySend(clientMessage(t, guest, models.MessageTypeReaction, fmt.Sprintf(reaction, s.ID))) {
+		t.Error("room guest may not react in the room's call")
+	}
+	if h.guestMaySend(clientMessage(t, guest, models.MessageTypeReaction, fmt.Sprintf(reaction, other.Id))) {
+		t.Error("room guest may react in another call")
+	}
+}
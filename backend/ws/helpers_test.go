@@ -0,0 +1,91 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+// testDB opens a fresh SQLite database in a temporary working directory, which also receives
+// any recordings written by the test.
+func testDB(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	database.InitSqliteDB()
+}
+
+// testUsers creates users named after names and returns their ids in order.
+func testUsers(t *testing.T, names ...string) []uint {
+	t.Helper()
+	ids := make([]uint, len(names))
+	for i, n := range names {
+		u := models.User{Name: n, Email: n + "@example.com"}
+		if err := database.Db.Create(&u).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = u.Id
+	}
+	return ids
+}
+
+// testClient registers a connected, online client for userID with the hub.
+func testClient(h *Hub, userID uint) *Client {
+	c := &Client{Hub: h, Send: make(chan models.WebSocketMessage, 64), UserID: userID,
+		presenceSeen: make(map[uint]models.UserStatusMessage)}
+	h.UserClients[userID] = c
+	h.UserStatuses[userID] = &models.UserStatusMessage{UserID: userID, Status: models.Online, Presence: models.Available}
+	return c
+}
+
+func opusTrack(id string) *ForwardTrack {
+	return NewForwardTrack(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}, id, "stream")
+}
+
+func vp8Track(id string) *ForwardTrack {
+	return NewForwardTrack(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, id, "stream")
+}
+
+// feed writes n small RTP packets to track.
+func feed(t *testing.T, track *ForwardTrack, n int) {
+	t.Helper()
+	video := track.Kind() == webrtc.RTPCodecTypeVideo
+	for i := 0; i < n; i++ {
+		pkt := &rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: uint16(i), Timestamp: uint32(i * 960), PayloadType: 111},
+			Payload: []byte{0xf8, 0xff, 0xfe}}
+		if video {
+			pkt.Marker = true
+			pkt.PayloadType = 96
+			pkt.Payload = []byte{0x10, 0x00, 0x9d, 0x01, 0x2a}
+		}
+		if err := track.WriteRTP(pkt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// drain returns the messages queued for c, in order.
+func drain(c *Client) []models.WebSocketMessage {
+	var out []models.WebSocketMessage
+	for {
+		select {
+		case m := <-c.Send:
+			out = append(out, m)
+		default:
+			return out
+		}
+	}
+}
+
+// messagesOfType returns the messages of type typ among msgs.
+func messagesOfType(msgs []models.WebSocketMessage, typ models.WSMessageType) []models.WebSocketMessage {
+	var out []models.WebSocketMessage
+	for _, m := range msgs {
+		if m.Type == typ {
+			out = append(out, m)
+		}
+	}
+	return out
+}
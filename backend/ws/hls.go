@@ -0,0 +1,161 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/hls"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/webrtc/v4"
+)
+
+const hlsSink = "hls"
+
+var (
+	ErrLiveStreamActive   = errors.New("call is already being streamed")
+	ErrLiveStreamInactive = errors.New("call is not being streamed")
+	ErrNothingToStream    = errors.New("publisher has no H.264 video or Opus audio track")
+)
+
+// LiveStream is the HLS output of a call. It follows a single featured publisher (the host by
+// default) since HLS players render one video and one audio track.
+type LiveStream struct {
+	PublisherID uint
+	StartedBy   uint
+	StartedAt   time.Time
+	Muxer       *hls.Muxer
+
+	videoTrack string // trackID feeding the muxer, "" if the stream has no video
+	audioTrack string
+}
+
+// StartLiveStream packages publisherID's tracks (0 for the host) into an HLS playlist and notifies participants.
+func (s *CallSession) StartLiveStream(userID, publisherID uint) (*LiveStream, error) {
+	s.Mu.Lock()
+	if s.LiveStream != nil {
+		s.Mu.Unlock()
+		return nil, ErrLiveStreamActive
+	}
+	if publisherID == 0 {
+		publisherID = s.hostID()
+	}
+	var video, audio string
+	for id, t := range s.PublishedTracks {
+		if s.PublishedOwners[id] != publisherID {
+			continue
+		}
+		if video == "" && isCodec(t, webrtc.MimeTypeH264) {
+			video = id
+		}
+		if audio == "" && isCodec(t, webrtc.MimeTypeOpus) {
+			audio = id
+		}
+	}
+	if video == "" && audio == "" {
+		s.Mu.Unlock()
+		return nil, ErrNothingToStream
+	}
+
+	ls := &LiveStream{
+		PublisherID: publisherID,
+		StartedBy:   userID,
+		StartedAt:   time.Now(),
+		Muxer:       hls.NewMuxer(video != "", audio != ""),
+	}
+	if video != "" {
+		ls.videoTrack = video
+		s.PublishedTracks[video].AddSink(hlsSink, ls.Muxer.VideoInput())
+	}
+	if audio != "" {
+		ls.audioTrack = audio
+		s.PublishedTracks[audio].AddSink(hlsSink, ls.Muxer.AudioInput())
+	}
+	s.LiveStream = ls
+	s.Mu.Unlock()
+
+	s.Broadcast(models.WebSocketMessage{
+		Type:    models.MessageTypeLiveStreamStarted,
+		Payload: ls.status(s.ID),
+		Time:    time.Now(),
+	}, 0)
+	return ls, nil
+}
+
+// StopLiveStream detaches the HLS muxer and notifies participants.
+func (s *CallSession) StopLiveStream(userID uint) error {
+	s.Mu.Lock()
+	ls := s.LiveStream
+	s.LiveStream = nil
+	if ls != nil {
+		for _, id := range []string{ls.videoTrack, ls.audioTrack} {
+			if t, ok := s.PublishedTracks[id]; ok {
+				t.RemoveSink(hlsSink)
+			}
+		}
+	}
+	s.Mu.Unlock()
+	if ls == nil {
+		return ErrLiveStreamInactive
+	}
+
+	status := ls.status(s.ID)
+	status.UserId = userID
+	s.Broadcast(models.WebSocketMessage{
+		Type:    models.MessageTypeLiveStreamStopped,
+		Payload: status,
+		Time:    time.Now(),
+	}, 0)
+	return nil
+}
+
+// GetLiveStream returns the active HLS output, if any.
+func (s *CallSession) GetLiveStream() (*LiveStream, bool) {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.LiveStream, s.LiveStream != nil
+}
+
+// attachLiveStream feeds a newly published track into the live stream when it belongs to the featured
+// publisher and replaces a track of the same kind that went away; callers must hold s.Mu.
+func (s *CallSession) attachLiveStream(publisherID uint, trackID string, track *ForwardTrack) {
+	ls := s.LiveStream
+	if ls == nil || ls.PublisherID != publisherID {
+		return
+	}
+	// the muxer's init segment fixes which kinds the stream carries
+	switch {
+	case isCodec(track, webrtc.MimeTypeH264) && ls.videoTrack != "" && (ls.videoTrack == trackID || !s.isPublished(ls.videoTrack)):
+		ls.videoTrack = trackID
+		track.AddSink(hlsSink, ls.Muxer.VideoInput())
+	case isCodec(track, webrtc.MimeTypeOpus) && ls.audioTrack != "" && (ls.audioTrack == trackID || !s.isPublished(ls.audioTrack)):
+		ls.audioTrack = trackID
+		track.AddSink(hlsSink, ls.Muxer.AudioInput())
+	}
+}
+
+// isPublished reports whether trackID is still published; callers must hold s.Mu.
+func (s *CallSession) isPublished(trackID string) bool {
+	_, ok := s.PublishedTracks[trackID]
+	return ok
+}
+
+func (ls *LiveStream) status(callID uint) models.LiveStreamStatusMessage {
+	return models.LiveStreamStatusMessage{
+		CallId:      callID,
+		UserId:      ls.StartedBy,
+		PublisherId: ls.PublisherID,
+		StartedAt:   ls.StartedAt,
+		PlaylistUrl: LivePlaylistURL(callID),
+	}
+}
+
+// LivePlaylistURL is the authenticated route players load the stream from.
+func LivePlaylistURL(callID uint) string {
+	return fmt.Sprintf("/calls/%d/live/%s", callID, hls.PlaylistName)
+}
+
+func isCodec(t *ForwardTrack, mimeType string) bool {
+	return strings.EqualFold(t.Codec().MimeType, mimeType)
+}
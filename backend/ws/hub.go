@@ -23,7 +23,9 @@ type Hub struct {
 
 	// call sessions keyed by call ID
 	CallSessions        map[uint]*CallSession
-	DisconnectedClients map[uint]*Client // userID -> client (recently disconnected)
+	DisconnectedClients map[uint]*Client   // userID -> client (recently disconnected)
+	transfers           map[uint]*transfer // call ID -> transfer in progress
+	contacts            contactGraph       // mutual contacts, for presence
 }
 
 func NewHub() *Hub {
@@ -35,6 +37,7 @@ func NewHub() *Hub {
 		UserStatuses:        make(map[uint]*models.UserStatusMessage),
 		CallSessions:        make(map[uint]*CallSession),
 		DisconnectedClients: make(map[uint]*Client),
+		transfers:           make(map[uint]*transfer),
 	}
 	hub.InitializeUserStatuses()
 	return hub
@@ -49,12 +52,7 @@ func (h *Hub) InitializeUserStatuses() {
 	}
 
 	for _, user := range users {
-		h.UserStatuses[uint(user.Id)] = &models.UserStatusMessage{
-			UserID:   uint(user.Id),
-			Username: user.Name,
-			Status:   "offline",
-			LastSeen: user.LastSeen,
-		}
+		h.UserStatuses[uint(user.Id)] = models.NewUserStatusMessage(user, models.Offline)
 	}
 }
 
@@ -77,12 +75,13 @@ func (h *Hub) Run() {
 			h.handleMessage(message)
 
 		case <-ticker.C:
-			h.broadcastOnlineUsers()
+			h.sendPresenceDiffs()
 		}
 	}
 }
 
 func (h *Hub) handleRegister(client *Client) {
+	h.contacts.get(client.UserID) // presence fan-out below reads the cache under the lock
 	h.Mutex.Lock()
 	defer h.Mutex.Unlock()
 
@@ -92,17 +91,17 @@ func (h *Hub) handleRegister(client *Client) {
 	// 	h.UserClients[client.UserID] = []*Client{}
 	// }
 	h.UserClients[client.UserID] = client
+	client.lastActive.Store(time.Now().UnixNano())
 
 	if status, exists := h.UserStatuses[client.UserID]; exists {
 		status.Status = models.Online
 		status.LastSeen = time.Now()
+		client.autoAway.Store(status.AutoAway)
 	} else {
-		h.UserStatuses[client.UserID] = &models.UserStatusMessage{
-			UserID:   client.UserID,
-			Username: client.Username,
-			Status:   models.Online,
-			LastSeen: time.Now(),
-		}
+		user := models.User{Id: client.UserID, Name: client.Username}
+		database.Db.First(&user, client.UserID)
+		user.LastSeen = time.Now()
+		h.UserStatuses[client.UserID] = models.NewUserStatusMessage(user, models.Online)
 	}
 
 	h.updateUserOnlineStatus(client.UserID, models.Online)
@@ -110,6 +109,8 @@ func (h *Hub) handleRegister(client *Client) {
 	log.Printf("User %s connected.", client.Username)
 	h.broadcastUserStatus(client.UserID, models.Online)
 	h.sendOnlineUsersToClient(client)
+	go h.deliverPendingMessages(client)
+	go h.deliverPendingVoicemails(client)
 }
 
 func (h *Hub) handleUnregister(client *Client) {
@@ -172,6 +173,7 @@ func (h *Hub) handleUnregister(client *Client) {
 func (h *Hub) CreateCallSession(call *models.Call) *CallSession {
 
 	session := NewCallSession(*call)
+	session.hub = h
 	// register participants who are connected
 	for _, uid := range append([]uint{call.CallerId}, call.CalleeIds...) {
 		if cl, ok := h.UserClients[uid]; ok && h.UserStatuses[uid].Status == models.Online {
@@ -179,9 +181,18 @@ func (h *Hub) CreateCallSession(call *models.Call) *CallSession {
 		}
 	}
 	h.CallSessions[uint(call.Id)] = session
+	go session.runStats()
 	return session
 }
 
+// GetCallSession returns the live session for a call, if any.
+func (h *Hub) GetCallSession(callID uint) (*CallSession, bool) {
+	h.Mutex.RLock()
+	defer h.Mutex.RUnlock()
+	session, ok := h.CallSessions[callID]
+	return session, ok
+}
+
 func (h *Hub) updateUserOnlineStatus(userID uint, status models.UserStatus) {
 	db := database.Db
 	db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
@@ -190,6 +201,8 @@ func (h *Hub) updateUserOnlineStatus(userID uint, status models.UserStatus) {
 	})
 }
 
+// broadcastUserStatus tells userID and their mutual contacts about a connection status change.
+// Callers must hold h.Mutex.
 func (h *Hub) broadcastUserStatus(userID uint, status models.UserStatus) {
 	if stat, exists := h.UserStatuses[userID]; exists {
 		messageType := models.MessageTypeUserOffline
@@ -199,25 +212,8 @@ func (h *Hub) broadcastUserStatus(userID uint, status models.UserStatus) {
 		if status == models.Busy {
 			messageType = models.MessageTypeUserBusy
 		}
-		message := models.WebSocketMessage{
-			Type:    messageType,
-			Payload: stat,
-			Time:    time.Now(),
-		}
-
-		h.broadcastMessage(message)
-	}
-}
-
-func (h *Hub) broadcastOnlineUsers() {
-	onlineUsers := h.GetOnlineUsers()
-	message := models.WebSocketMessage{
-		Type:    models.MessageTypeUsersList,
-		Payload: onlineUsers,
-		Time:    time.Now(),
+		h.fanOutPresence(messageType, *stat)
 	}
-
-	h.broadcastMessage(message)
 }
 
 func (h *Hub) GetOnlineUsers() []models.UserStatusMessage {
@@ -254,18 +250,25 @@ func (h *Hub) CheckUserStatus(userID uint) *models.UserStatusMessage {
 	return status
 }
 
+// sendOnlineUsersToClient sends a newly connected client the presence of its mutual contacts;
+// later changes arrive as presence_diff. Callers must hold h.Mutex.
 func (h *Hub) sendOnlineUsersToClient(client *Client) {
-	onlineUsers := h.GetOnlineUsers()
+	visible := h.visibleStatuses(client.UserID)
+	users := make([]models.UserStatusMessage, 0, len(visible))
+	for _, st := range visible {
+		users = append(users, st)
+	}
 	message := models.WebSocketMessage{
 		Type:    models.MessageTypeUsersList,
-		Payload: onlineUsers,
+		Payload: users,
 		Time:    time.Now(),
 	}
 
 	select {
 	case client.Send <- message:
+		client.presenceSeen = visible
 	default:
-		h.Unregister <- client
+		log.Printf("sendOnlineUsersToClient: send channel full for user %d", client.UserID)
 	}
 }
 
@@ -282,7 +285,14 @@ func (h *Hub) broadcastMessage(message models.WebSocketMessage) {
 	}
 }
 
+// handleMessage dispatches a message read from a client. Payloads still carry a userId, but
+// handlers act for msg.From, the authenticated sender, so nobody can speak for someone else.
 func (h *Hub) handleMessage(msg models.WebSocketMessage) {
+	if !h.guestMaySend(msg) {
+		log.Printf("handleMessage: guest %d may not send %s", msg.From, msg.Type)
+		h.sendError(msg.From, string(msg.Type), ErrGuestNotAllowed)
+		return
+	}
 	switch msg.Type {
 	case "user_online":
 		h.broadcastMessage(msg)
@@ -296,6 +306,16 @@ func (h *Hub) handleMessage(msg models.WebSocketMessage) {
 		h.handleUserLeft(msg)
 	case "add_callee":
 		h.handleAddCallee(msg)
+	case models.MessageTypeModerate:
+		h.handleModerate(msg)
+	case models.MessageTypeRaiseHand:
+		h.handleRaiseHand(msg)
+	case models.MessageTypeReaction:
+		h.handleReaction(msg)
+	case models.MessageTypePollCreate, models.MessageTypePollVote, models.MessageTypePollClose:
+		h.handlePoll(msg)
+	case models.MessageTypeTransfer:
+		h.handleTransfer(msg)
 	case "ice-candidate":
 		h.handleICECandidate(msg)
 	case "call_offer":
@@ -310,50 +330,95 @@ func (h *Hub) handleMessage(msg models.WebSocketMessage) {
 
 }
 
+// guestMessages are the message types a guest may send, each about the call in their scope.
+// Guests can't start calls, invite or transfer anyone, or broadcast.
+var guestMessages = map[models.WSMessageType]bool{
+	"call_offer":                 true,
+	"call_accepted":              true,
+	"call_rejected":              true,
+	"user_leave":                 true,
+	"ice-candidate":              true,
+	"track_update":               true,
+	"reconnect":                  true,
+	models.MessageTypeModerate:   true,
+	models.MessageTypeRaiseHand:  true,
+	models.MessageTypeReaction:   true,
+	models.MessageTypePollCreate: true,
+	models.MessageTypePollVote:   true,
+	models.MessageTypePollClose:  true,
+}
+
+// guestMaySend reports whether msg is allowed from its sender: anything from a regular user, and
+// for guests only the messages above about the room or call their token grants.
+func (h *Hub) guestMaySend(msg models.WebSocketMessage) bool {
+	h.Mutex.RLock()
+	cl, ok := h.UserClients[msg.From]
+	if !ok {
+		cl = h.DisconnectedClients[msg.From]
+	}
+	h.Mutex.RUnlock()
+	if cl == nil || !cl.Guest {
+		return true
+	}
+	if !guestMessages[msg.Type] {
+		return false
+	}
+	var payload struct {
+		CallId uint `json:"callId"`
+	}
+	if err := decodePayload(msg.Payload, &payload); err != nil {
+		return false
+	}
+	if cl.GuestScope.CallId != 0 {
+		return payload.CallId == cl.GuestScope.CallId
+	}
+	session, ok := h.GetCallSession(payload.CallId)
+	if !ok || cl.GuestScope.RoomId == 0 {
+		return false
+	}
+	session.Mu.RLock()
+	defer session.Mu.RUnlock()
+	return session.Call.RoomId != nil && *session.Call.RoomId == cl.GuestScope.RoomId
+}
+
 func (h *Hub) handleIncomingCall(msg models.WebSocketMessage) {
-	h.Mutex.Lock()
-	defer h.Mutex.Unlock()
 	type IncomingCallPayload struct {
-		CallId uint `json:"userId"`
-		UserId uint `json:"callId"`
+		CallId uint `json:"callId"`
+		UserId uint `json:"userId"`
 	}
 
-	payload, ok := msg.Payload.(IncomingCallPayload)
-	if !ok {
-		log.Printf("Failed to assert msg.Payload as models.Call")
+	var payload IncomingCallPayload
+	if err := decodePayload(msg.Payload, &payload); err != nil {
+		log.Printf("Couldn't decode msg.Payload as IncomingCallPayload: %v", err)
 		return
 	}
-	session, exists := h.CallSessions[payload.CallId]
+	session, exists := h.GetCallSession(payload.CallId)
 	if !exists {
 		log.Printf("Call %d doesn't exist", payload.CallId)
 		return
 	}
+	session.Mu.RLock()
 	call := session.Call
-	caller := h.UserClients[call.CallerId]
+	session.Mu.RUnlock()
+	if call.CallerId != msg.From {
+		log.Printf("incoming_call: user %d is not the caller of call %d", msg.From, call.Id)
+		return
+	}
 	// db.Create(&call)
 	// h.CreateCallSession(&call)
-	//if h.UserStatuses[call.CalleeId].Status != models.Online {
-	//TODO: handle busy and offline
-	msg = models.WebSocketMessage{
-		Type:    "incoming_call",
-		Payload: call,
-		Time:    time.Now(),
-	}
-	for _, id := range call.CalleeIds {
-		if h.UserStatuses[id].Status != models.Online {
-			log.Printf("User %d is already in a call", id)
-			continue
-		}
-		select {
-		case h.UserClients[uint(id)].Send <- msg:
-		default:
-			log.Printf("Couldn't send user %v the incoming call due to full channel or stg I don't no", id)
-		}
-	}
+	h.Ring(session)
+
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
 	if call.Offer == nil {
 		log.Printf("Caller does not have any offer")
 		return
 	}
+	caller, exists := h.UserClients[call.CallerId]
+	if !exists {
+		log.Printf("Caller %d of call %d is not connected", call.CallerId, call.Id)
+		return
+	}
 	caller.ProcessOffer(call.Offer, call.Id)
 	h.updateUserOnlineStatus(call.CallerId, models.Busy)
 	h.broadcastUserStatus(call.CallerId, models.Busy)
@@ -370,19 +435,27 @@ func (h *Hub) handleOffer(msg models.WebSocketMessage) {
 		Offer  json.RawMessage `json:"offer"`
 	}
 
-	payload, ok := msg.Payload.(OfferPayload)
-	if !ok {
-		log.Printf("handleOffer: Could not assert msg.Payload as OfferPayload")
+	var payload OfferPayload
+	if err := decodePayload(msg.Payload, &payload); err != nil {
+		log.Printf("handleOffer: Could not decode msg.Payload as OfferPayload: %v", err)
 		return
 	}
-	if _, exists := h.CallSessions[payload.CallId]; !exists {
+	payload.UserId = msg.From
+	session, exists := h.CallSessions[payload.CallId]
+	if !exists {
 		log.Printf("handleOffer: Call %d session does not exist", payload.CallId)
+		return
 	}
 	cl, exists := h.UserClients[payload.UserId]
 	if !exists {
 		log.Printf("handleOffer: User %d is not found", payload.UserId)
 		return
 	}
+	if err := session.joinError(payload.UserId); err != nil {
+		log.Printf("handleOffer: user %d may not join call %d: %v", payload.UserId, payload.CallId, err)
+		sendNonBlocking(cl, errorMessage(string(msg.Type), err))
+		return
+	}
 	cl.ProcessOffer(payload.Offer, payload.CallId)
 	h.updateUserOnlineStatus(payload.UserId, models.Busy)
 	h.broadcastUserStatus(payload.UserId, models.Busy)
@@ -398,16 +471,28 @@ func (h *Hub) handleCallAccepted(msg models.WebSocketMessage) {
 		Offer  json.RawMessage `json:"offer"`
 	}
 
-	payload, ok := msg.Payload.(CallAcceptedPayload)
-	if !ok {
-		log.Printf("Failed to assert msg.Payload as CallAcceptedPayload")
+	var payload CallAcceptedPayload
+	if err := decodePayload(msg.Payload, &payload); err != nil {
+		log.Printf("Couldn't decode msg.Payload as CallAcceptedPayload: %v", err)
 		return
 	}
+	payload.UserId = msg.From
+	session, ok := h.CallSessions[payload.CallId]
 	if !ok {
 		log.Printf("No call in stack")
 		return
 	}
-	h.UserClients[payload.UserId].ProcessOffer(payload.Offer, payload.CallId)
+	client, ok := h.UserClients[payload.UserId]
+	if !ok {
+		log.Printf("User %d is not connected", payload.UserId)
+		return
+	}
+	if err := session.joinError(payload.UserId); err != nil {
+		log.Printf("call_accepted: user %d may not join call %d: %v", payload.UserId, payload.CallId, err)
+		sendNonBlocking(client, errorMessage(string(msg.Type), err))
+		return
+	}
+	client.ProcessOffer(payload.Offer, payload.CallId)
 
 	h.updateUserOnlineStatus(payload.UserId, models.Busy)
 	h.broadcastUserStatus(payload.UserId, models.Busy)
@@ -421,16 +506,17 @@ func (h *Hub) handleCallRejected(msg models.WebSocketMessage) {
 		CallId uint `json:"callId"`
 		UserId uint `json:"userId"`
 	}
-	payload, ok := msg.Payload.(CallRejectedPayload)
-	if !ok {
-		log.Printf("Failed to assert msg.Payload as CallRejectedPayload")
+	var payload CallRejectedPayload
+	if err := decodePayload(msg.Payload, &payload); err != nil {
+		log.Printf("Couldn't decode msg.Payload as CallRejectedPayload: %v", err)
 		return
 	}
+	payload.UserId = msg.From
+	session, ok := h.CallSessions[payload.CallId]
 	if !ok {
 		log.Printf("No call in stack")
 		return
 	}
-	session := h.CallSessions[payload.CallId]
 	db := database.Db
 	history := models.History{
 		Id:      0,
@@ -451,8 +537,12 @@ func (h *Hub) handleCallRejected(msg models.WebSocketMessage) {
 		session.Close()
 	}
 
-	caller := session.Participants[session.Call.CallerId]
-	caller.Send <- msg
+	if caller, ok := session.Participants[session.Call.CallerId]; ok {
+		select {
+		case caller.Send <- msg:
+		default:
+		}
+	}
 
 }
 
@@ -464,11 +554,17 @@ func (h *Hub) handleUserLeft(msg models.WebSocketMessage) {
 		CallId uint `json:"callId"`
 		UserId uint `json:"userId"`
 	}
-	payload, ok := msg.Payload.(UserLeftPayload)
+	var payload UserLeftPayload
+	if err := decodePayload(msg.Payload, &payload); err != nil {
+		log.Printf("Couldn't decode msg.Payload as UserLeftPayload: %v", err)
+		return
+	}
+	payload.UserId = msg.From
+	session, ok := h.CallSessions[payload.CallId]
 	if !ok {
-		log.Printf("Couldn't assert msg.Payload as CallEndedPayload")
+		log.Printf("Call session %d does not exist", payload.CallId)
+		return
 	}
-	session := h.CallSessions[payload.CallId]
 
 	role := "callee"
 	if session.Call.CallerId == payload.UserId {
@@ -497,23 +593,41 @@ func (h *Hub) handleUserLeft(msg models.WebSocketMessage) {
 }
 
 func (h *Hub) handleAddCallee(msg models.WebSocketMessage) {
-	h.Mutex.Lock()
-	defer h.Mutex.Unlock()
-
 	type AddCalleePayload struct {
 		CallId uint `json:"callId"`
 		UserId uint `json:"userId"`
 	}
-	payload, ok := msg.Payload.(AddCalleePayload)
-	if !ok {
-		log.Printf("Couldn't assert msg.Payload as AddCalleePayload")
+	var payload AddCalleePayload
+	if err := decodePayload(msg.Payload, &payload); err != nil {
+		log.Printf("Couldn't decode msg.Payload as AddCalleePayload: %v", err)
 		return
 	}
+	// blocks are checked before taking the hub lock, since they are a database query
+	if BlockedBetween(msg.From, payload.UserId) {
+		log.Printf("add_callee: call %d refused user %d", payload.CallId, payload.UserId)
+		return
+	}
+
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
 	session, exists := h.CallSessions[payload.CallId]
 	if !exists {
 		log.Printf("Call session %d does not exist", payload.CallId)
 		return
 	}
+	// viewers can't invite, and only moderators can bring people into a locked call
+	role := session.Role(msg.From)
+	if !session.HasParticipant(msg.From) || role == models.RoleViewer {
+		log.Printf("add_callee: user %d may not invite to call %d", msg.From, payload.CallId)
+		return
+	}
+	session.Mu.RLock()
+	refused := session.removed[payload.UserId] || (session.Locked && role.Rank() < models.RoleModerator.Rank())
+	session.Mu.RUnlock()
+	if refused {
+		log.Printf("add_callee: call %d refused user %d", payload.CallId, payload.UserId)
+		return
+	}
 	client, exists := h.UserClients[payload.UserId]
 	if !exists {
 		log.Printf("User %d not found in client struct", payload.UserId)
@@ -532,6 +646,132 @@ func (h *Hub) handleAddCallee(msg models.WebSocketMessage) {
 	}
 }
 
+// handleModerate applies a moderation request sent by a host or moderator and reports failures
+// back to the sender.
+func (h *Hub) handleModerate(msg models.WebSocketMessage) {
+	var req models.ModerationRequest
+	if err := decodePayload(msg.Payload, &req); err != nil {
+		log.Printf("handleModerate: invalid payload: %v", err)
+		return
+	}
+	session, exists := h.GetCallSession(req.CallId)
+	if !exists {
+		log.Printf("handleModerate: call session %d does not exist", req.CallId)
+		return
+	}
+	if err := session.Moderate(msg.From, req); err != nil {
+		h.sendError(msg.From, string(req.Action), err)
+	}
+}
+
+// handleRaiseHand raises or lowers a hand in a call.
+func (h *Hub) handleRaiseHand(msg models.WebSocketMessage) {
+	var req models.HandRequest
+	if err := decodePayload(msg.Payload, &req); err != nil {
+		log.Printf("handleRaiseHand: invalid payload: %v", err)
+		return
+	}
+	session, exists := h.GetCallSession(req.CallId)
+	if !exists {
+		log.Printf("handleRaiseHand: call session %d does not exist", req.CallId)
+		return
+	}
+	if req.UserId == 0 {
+		req.UserId = msg.From
+	}
+	if err := session.SetHand(msg.From, req.UserId, req.Raised); err != nil {
+		h.sendError(msg.From, string(msg.Type), err)
+	}
+}
+
+// handleReaction relays an emoji reaction to the sender's call.
+func (h *Hub) handleReaction(msg models.WebSocketMessage) {
+	var req models.ReactionMessage
+	if err := decodePayload(msg.Payload, &req); err != nil {
+		log.Printf("handleReaction: invalid payload: %v", err)
+		return
+	}
+	session, exists := h.GetCallSession(req.CallId)
+	if !exists {
+		log.Printf("handleReaction: call session %d does not exist", req.CallId)
+		return
+	}
+	if err := session.React(msg.From, req.Emoji); err != nil {
+		h.sendError(msg.From, string(msg.Type), err)
+	}
+}
+
+// handlePoll creates, votes in or closes a poll. poll_create carries a models.PollRequest; the
+// other two a models.PollVoteRequest.
+func (h *Hub) handlePoll(msg models.WebSocketMessage) {
+	var callID uint
+	var create models.PollRequest
+	var vote models.PollVoteRequest
+	var err error
+	if msg.Type == models.MessageTypePollCreate {
+		err = decodePayload(msg.Payload, &create)
+		callID = create.CallId
+	} else {
+		err = decodePayload(msg.Payload, &vote)
+		callID = vote.CallId
+	}
+	if err != nil {
+		log.Printf("handlePoll: invalid payload: %v", err)
+		return
+	}
+	session, exists := h.GetCallSession(callID)
+	if !exists {
+		log.Printf("handlePoll: call session %d does not exist", callID)
+		return
+	}
+
+	switch msg.Type {
+	case models.MessageTypePollCreate:
+		_, err = session.CreatePoll(msg.From, create)
+	case models.MessageTypePollVote:
+		_, err = session.Vote(msg.From, vote.PollId, vote.OptionIds)
+	case models.MessageTypePollClose:
+		_, err = session.ClosePoll(msg.From, vote.PollId)
+	}
+	if err != nil {
+		h.sendError(msg.From, string(msg.Type), err)
+	}
+}
+
+// handleTransfer applies a step of a call transfer sent over WS.
+func (h *Hub) handleTransfer(msg models.WebSocketMessage) {
+	var req models.TransferRequest
+	if err := decodePayload(msg.Payload, &req); err != nil {
+		log.Printf("handleTransfer: invalid payload: %v", err)
+		return
+	}
+	if _, err := h.HandleTransfer(msg.From, req); err != nil {
+		h.sendError(msg.From, string(req.Action), err)
+	}
+}
+
+// sendError reports a failed request back to the user who sent it.
+func (h *Hub) sendError(userID uint, action string, err error) {
+	h.SendToUser(userID, errorMessage(action, err))
+}
+
+// errorMessage reports that action failed with err.
+func errorMessage(action string, err error) models.WebSocketMessage {
+	return models.WebSocketMessage{
+		Type:    models.MessageTypeError,
+		Payload: map[string]string{"error": err.Error(), "action": action},
+		Time:    time.Now(),
+	}
+}
+
+// sendNonBlocking queues msg for c, dropping it if c's buffer is full; for callers holding h.Mutex.
+func sendNonBlocking(c *Client, msg models.WebSocketMessage) {
+	select {
+	case c.Send <- msg:
+	default:
+	}
+}
+
 func (h *Hub) handleICECandidate(msg models.WebSocketMessage) {
 	h.Mutex.RLock()
 	defer h.Mutex.RUnlock()
@@ -541,11 +781,12 @@ func (h *Hub) handleICECandidate(msg models.WebSocketMessage) {
 		Candidate webrtc.ICECandidateInit `json:"candidate"`
 		CallId    uint                    `json:"callId"`
 	}
-	payload, ok := msg.Payload.(ICECandidatePayload)
-	if !ok {
-		log.Printf("Couldn't assert msg.Payload as ICECandidatePayload")
+	var payload ICECandidatePayload
+	if err := decodePayload(msg.Payload, &payload); err != nil {
+		log.Printf("Couldn't decode msg.Payload as ICECandidatePayload: %v", err)
 		return
 	}
+	payload.UserId = msg.From
 	if payload.CallId != 0 {
 		if session, exists := h.CallSessions[payload.CallId]; exists {
 			if client, exists := session.Participants[payload.UserId]; exists {
@@ -570,12 +811,17 @@ func (h *Hub) handleTrackUpdate(msg models.WebSocketMessage) {
 		TrackType string `json:"trackType"`
 		Muted bool `json:"muted"` 
 	}
-	payload, ok := msg.Payload.(TrackUpdatePayload)
+	var payload TrackUpdatePayload
+	if err := decodePayload(msg.Payload, &payload); err != nil {
+		log.Printf("Could not decode msg payload as TrackUpdatePayload: %v", err)
+		return
+	}
+	payload.UserId = msg.From
+	session, ok := h.CallSessions[payload.CallId]
 	if !ok {
-		log.Printf("Could not assert msg payload as TrackUpdatePayload")
+		log.Printf("track_update: no call session: %d", payload.CallId)
 		return
 	}
-	session := h.CallSessions[payload.CallId]
 	for _, c := range session.Participants {
 		if c.UserID == payload.UserId {
 			continue
@@ -596,11 +842,12 @@ func (h *Hub) handleReconnect(msg models.WebSocketMessage) {
 		UserId  uint `json:"userId"`
 		PcAlive bool `json:"pcAlive"`
 	}
-	payload, ok := msg.Payload.(ReconnectPayload)
-	if !ok {
-		log.Printf("Could not assert message payload as ReconnectPayload")
+	var payload ReconnectPayload
+	if err := decodePayload(msg.Payload, &payload); err != nil {
+		log.Printf("Could not decode message payload as ReconnectPayload: %v", err)
 		return
 	}
+	payload.UserId = msg.From
 	client, exists := h.UserClients[payload.UserId]
 	if !exists {
 		if c, ok := h.DisconnectedClients[payload.UserId]; ok {
@@ -620,6 +867,10 @@ func (h *Hub) handleReconnect(msg models.WebSocketMessage) {
 		log.Printf("reconnect: no call session: %d", payload.CallId)
 		return
 	}
+	if !session.CanJoin(payload.UserId) {
+		log.Printf("reconnect: user %d may not rejoin call %d", payload.UserId, payload.CallId)
+		return
+	}
 	session.AddParticipant(client)
 	if payload.PcAlive {
 		go func() {
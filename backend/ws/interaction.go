@@ -0,0 +1,364 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// a participant may send reactionBurst reactions in any reactionWindow
+	reactionBurst  = 5
+	reactionWindow = 3 * time.Second
+	maxEmojiRunes  = 8
+
+	maxPollOptions     = 10
+	maxPollQuestionLen = 500
+	maxPollOptionLen   = 200
+)
+
+var (
+	ErrReactionRateLimited = errors.New("too many reactions, slow down")
+	ErrInvalidReaction     = errors.New("reaction must be a short emoji")
+	ErrInvalidPoll         = errors.New("a poll needs a question and 2 to 10 distinct options")
+	ErrUnknownPoll         = errors.New("poll not found")
+	ErrPollClosed          = errors.New("poll is closed")
+	ErrInvalidVote         = errors.New("vote for one option, or several on a multi-choice poll")
+)
+
+// livePoll is a poll of a running call with everyone's current choice.
+type livePoll struct {
+	poll  models.Poll
+	votes map[uint][]uint // userID -> chosen option ids
+	// saving serializes storing votes and closing the poll, so the database sees votes in the
+	// same order as votes and none after the poll closed. Take it before s.Mu.
+	saving sync.Mutex
+}
+
+// tallied returns the poll with option counts computed from the current votes.
+func (p *livePoll) tallied() models.Poll {
+	out := p.poll
+	out.Options = append([]models.PollOption(nil), p.poll.Options...)
+	counts := make(map[uint]int)
+	for _, ids := range p.votes {
+		for _, id := range ids {
+			counts[id]++
+		}
+	}
+	for i := range out.Options {
+		out.Options[i].Votes = counts[out.Options[i].Id]
+	}
+	out.Voters = len(p.votes)
+	return out
+}
+
+// SetHand raises or lowers userID's hand. Anyone in the call can raise or lower their own; hosts
+// and moderators can also lower other people's.
+func (s *CallSession) SetHand(actorID, userID uint, raised bool) error {
+	if !s.HasParticipant(actorID) {
+		return ErrNotInCall
+	}
+	if actorID != userID && (raised || !s.CanModerate(actorID)) {
+		return ErrNotPermitted
+	}
+
+	s.Mu.Lock()
+	if _, ok := s.Participants[userID]; !ok && userID != s.hostID() {
+		s.Mu.Unlock()
+		return ErrNotInCall
+	}
+	i := s.handIndex(userID)
+	if raised == (i >= 0) {
+		s.Mu.Unlock()
+		return nil
+	}
+	if raised {
+		s.hands = append(s.hands, models.HandRaise{UserId: userID, RaisedAt: time.Now()})
+	} else {
+		s.hands = append(s.hands[:i], s.hands[i+1:]...)
+	}
+	s.Mu.Unlock()
+
+	msg := models.HandMessage{CallId: s.ID, UserId: userID, Raised: raised}
+	if actorID != userID {
+		msg.LoweredBy = actorID
+	}
+	s.Broadcast(models.WebSocketMessage{Type: models.MessageTypeHandRaised, Payload: msg, Time: time.Now()}, 0)
+	s.sendToModerators(models.WebSocketMessage{
+		Type:    models.MessageTypeHandQueue,
+		Payload: models.HandQueueMessage{CallId: s.ID, Queue: s.HandQueue()},
+		Time:    time.Now(),
+	})
+	return nil
+}
+
+// HandQueue returns the raised hands, oldest first.
+func (s *CallSession) HandQueue() []models.HandRaise {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return append([]models.HandRaise{}, s.hands...)
+}
+
+// handIndex returns userID's position in the hand queue, or -1; callers must hold s.Mu.
+func (s *CallSession) handIndex(userID uint) int {
+	for i, h := range s.hands {
+		if h.UserId == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+// React relays an emoji reaction from userID to the whole call.
+func (s *CallSession) React(userID uint, emoji string) error {
+	emoji = strings.TrimSpace(emoji)
+	if emoji == "" || utf8.RuneCountInString(emoji) > maxEmojiRunes || !utf8.ValidString(emoji) {
+		return ErrInvalidReaction
+	}
+	if !s.HasParticipant(userID) {
+		return ErrNotInCall
+	}
+
+	now := time.Now()
+	s.Mu.Lock()
+	recent := s.reactions[userID][:0]
+	for _, t := range s.reactions[userID] {
+		if now.Sub(t) < reactionWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= reactionBurst {
+		s.reactions[userID] = recent
+		s.Mu.Unlock()
+		return ErrReactionRateLimited
+	}
+	s.reactions[userID] = append(recent, now)
+	s.Mu.Unlock()
+
+	s.Broadcast(models.WebSocketMessage{
+		Type:    models.MessageTypeReaction,
+		Payload: models.ReactionMessage{CallId: s.ID, UserId: userID, Emoji: emoji, Time: now},
+		Time:    now,
+	}, 0)
+	return nil
+}
+
+// CreatePoll asks the call a question; host and moderators only. The poll is stored right away so
+// its results stay with the call record.
+func (s *CallSession) CreatePoll(actorID uint, req models.PollRequest) (models.Poll, error) {
+	if !s.CanModerate(actorID) {
+		return models.Poll{}, ErrNotPermitted
+	}
+	question := strings.TrimSpace(req.Question)
+	if question == "" || len(question) > maxPollQuestionLen || len(req.Options) < 2 || len(req.Options) > maxPollOptions {
+		return models.Poll{}, ErrInvalidPoll
+	}
+	poll := models.Poll{
+		CallId:      s.ID,
+		CreatorId:   actorID,
+		Question:    question,
+		MultiChoice: req.MultiChoice,
+		CreatedAt:   time.Now(),
+	}
+	seen := make(map[string]bool)
+	for i, text := range req.Options {
+		text = strings.TrimSpace(text)
+		if text == "" || len(text) > maxPollOptionLen || seen[text] {
+			return models.Poll{}, ErrInvalidPoll
+		}
+		seen[text] = true
+		poll.Options = append(poll.Options, models.PollOption{Position: i, Text: text})
+	}
+	if err := database.Db.Create(&poll).Error; err != nil {
+		return models.Poll{}, err
+	}
+
+	lp := &livePoll{poll: poll, votes: make(map[uint][]uint)}
+	s.Mu.Lock()
+	s.polls[poll.Id] = lp
+	s.Mu.Unlock()
+
+	s.Broadcast(models.WebSocketMessage{Type: models.MessageTypePollStarted, Payload: poll, Time: time.Now()}, 0)
+	return poll, nil
+}
+
+// Vote records userID's choice in an open poll, replacing any earlier vote, and broadcasts the
+// new tallies. Voters stay anonymous to the rest of the call.
+func (s *CallSession) Vote(userID, pollID uint, optionIDs []uint) (models.Poll, error) {
+	if !s.HasParticipant(userID) {
+		return models.Poll{}, ErrNotInCall
+	}
+	lp := s.livePoll(pollID)
+	if lp == nil {
+		return models.Poll{}, ErrUnknownPoll
+	}
+	lp.saving.Lock()
+	defer lp.saving.Unlock()
+
+	s.Mu.RLock()
+	closed := lp.poll.Closed
+	multiChoice := lp.poll.MultiChoice
+	valid := make(map[uint]bool, len(lp.poll.Options))
+	for _, o := range lp.poll.Options {
+		valid[o.Id] = true
+	}
+	s.Mu.RUnlock()
+	if closed {
+		return models.Poll{}, ErrPollClosed
+	}
+	if len(optionIDs) == 0 || (!multiChoice && len(optionIDs) > 1) {
+		return models.Poll{}, ErrInvalidVote
+	}
+	chosen := make([]uint, 0, len(optionIDs))
+	for _, id := range optionIDs {
+		if !valid[id] {
+			return models.Poll{}, ErrInvalidVote
+		}
+		valid[id] = false // each option counts once
+		chosen = append(chosen, id)
+	}
+
+	// the session lock isn't held while the vote is stored; lp.saving keeps the poll open
+	err := database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("poll_id = ? AND user_id = ?", pollID, userID).Delete(&models.PollVote{}).Error; err != nil {
+			return err
+		}
+		for _, id := range chosen {
+			if err := tx.Create(&models.PollVote{PollId: pollID, OptionId: id, UserId: userID, CreatedAt: time.Now()}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Poll{}, err
+	}
+	s.Mu.Lock()
+	lp.votes[userID] = chosen
+	poll := lp.tallied()
+	s.Mu.Unlock()
+
+	s.Broadcast(models.WebSocketMessage{Type: models.MessageTypePollUpdated, Payload: poll, Time: time.Now()}, 0)
+	return poll, nil
+}
+
+// ClosePoll stops voting and stores the final tallies; host and moderators only.
+func (s *CallSession) ClosePoll(actorID, pollID uint) (models.Poll, error) {
+	if !s.CanModerate(actorID) {
+		return models.Poll{}, ErrNotPermitted
+	}
+	lp := s.livePoll(pollID)
+	if lp == nil {
+		return models.Poll{}, ErrUnknownPoll
+	}
+	lp.saving.Lock()
+	defer lp.saving.Unlock()
+	s.Mu.Lock()
+	if lp.poll.Closed {
+		s.Mu.Unlock()
+		return models.Poll{}, ErrPollClosed
+	}
+	poll := s.finishPoll(lp)
+	s.Mu.Unlock()
+
+	if err := savePollResults(poll); err != nil {
+		return poll, err
+	}
+	s.Broadcast(models.WebSocketMessage{Type: models.MessageTypePollClosed, Payload: poll, Time: time.Now()}, 0)
+	return poll, nil
+}
+
+// Polls returns the call's polls with their current tallies, oldest first.
+func (s *CallSession) Polls() []models.Poll {
+	s.Mu.RLock()
+	out := make([]models.Poll, 0, len(s.polls))
+	for _, lp := range s.polls {
+		out = append(out, lp.tallied())
+	}
+	s.Mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Id < out[j].Id })
+	return out
+}
+
+// finishPoll marks lp closed and returns its final state; callers must hold s.Mu.
+func (s *CallSession) finishPoll(lp *livePoll) models.Poll {
+	t := time.Now()
+	lp.poll.Closed = true
+	lp.poll.ClosedAt = &t
+	poll := lp.tallied()
+	lp.poll.Options = poll.Options
+	lp.poll.Voters = poll.Voters
+	return poll
+}
+
+// closePolls closes every open poll when the call ends, so their results are kept.
+func (s *CallSession) closePolls() {
+	s.Mu.RLock()
+	polls := make([]*livePoll, 0, len(s.polls))
+	for _, lp := range s.polls {
+		polls = append(polls, lp)
+	}
+	s.Mu.RUnlock()
+	for _, lp := range polls {
+		lp.saving.Lock()
+		s.Mu.Lock()
+		closed := lp.poll.Closed
+		var poll models.Poll
+		if !closed {
+			poll = s.finishPoll(lp)
+		}
+		s.Mu.Unlock()
+		if !closed {
+			if err := savePollResults(poll); err != nil {
+				log.Printf("closePolls: failed to save results of poll %d: %v", poll.Id, err)
+			}
+		}
+		lp.saving.Unlock()
+	}
+}
+
+// livePoll returns the poll pollID of the call, or nil.
+func (s *CallSession) livePoll(pollID uint) *livePoll {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.polls[pollID]
+}
+
+// savePollResults writes the final tallies of a closed poll.
+func savePollResults(poll models.Poll) error {
+	return database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Poll{}).Where("id = ?", poll.Id).
+			Updates(map[string]interface{}{"closed": true, "closed_at": poll.ClosedAt, "voters": poll.Voters}).Error; err != nil {
+			return err
+		}
+		for _, o := range poll.Options {
+			if err := tx.Model(&models.PollOption{}).Where("id = ?", o.Id).Update("votes", o.Votes).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// sendToModerators sends msg to the host and moderators connected to the call.
+func (s *CallSession) sendToModerators(msg models.WebSocketMessage) {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	for uid, cl := range s.Participants {
+		if cl == nil || s.role(uid).Rank() < models.RoleModerator.Rank() {
+			continue
+		}
+		select {
+		case cl.Send <- msg:
+		default:
+		}
+	}
+}
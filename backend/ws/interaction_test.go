@@ -0,0 +1,94 @@
+package ws
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestPollVoting(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "host", "alice", "bob")
+	host, alice, bob := users[0], users[1], users[2]
+	_, s := moderatedCall(t, users)
+
+	poll, err := s.CreatePoll(host, models.PollRequest{Question: "Lunch?", Options: []string{"pizza", "sushi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pizza, sushi := poll.Options[0].Id, poll.Options[1].Id
+
+	if _, err := s.Vote(alice, poll.Id, []uint{pizza, sushi}); err != ErrInvalidVote {
+		t.Errorf("two options on a single-choice poll = %v, want ErrInvalidVote", err)
+	}
+	if _, err := s.Vote(alice, poll.Id, []uint{pizza}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Vote(bob, poll.Id, []uint{pizza}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Vote(alice, poll.Id, []uint{sushi})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Options[0].Votes != 1 || got.Options[1].Votes != 1 {
+		t.Errorf("tallies after revote = %d/%d, want 1/1", got.Options[0].Votes, got.Options[1].Votes)
+	}
+	var stored int64
+	database.Db.Model(&models.PollVote{}).Where("poll_id = ? AND user_id = ?", poll.Id, alice).Count(&stored)
+	if stored != 1 {
+		t.Errorf("alice has %d stored votes, want 1", stored)
+	}
+
+	if _, err := s.ClosePoll(host, poll.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Vote(bob, poll.Id, []uint{sushi}); err != ErrPollClosed {
+		t.Errorf("vote after close = %v, want ErrPollClosed", err)
+	}
+}
+
+func TestConcurrentVotesMatchDatabase(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "host", "alice", "bob", "carol")
+	_, s := moderatedCall(t, users)
+
+	poll, err := s.CreatePoll(users[0], models.PollRequest{Question: "Ship it?", Options: []string{"yes", "no"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for _, uid := range users {
+		for _, o := range poll.Options {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := s.Vote(uid, poll.Id, []uint{o.Id}); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	closed, err := s.ClosePoll(users[0], poll.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored []models.PollVote
+	database.Db.Where("poll_id = ?", poll.Id).Find(&stored)
+	if len(stored) != len(users) {
+		t.Fatalf("%d stored votes, want one per user (%d)", len(stored), len(users))
+	}
+	counts := make(map[uint]int)
+	for _, v := range stored {
+		counts[v.OptionId]++
+	}
+	for _, o := range closed.Options {
+		if o.Votes != counts[o.Id] {
+			t.Errorf("option %q: tally %d, stored %d", o.Text, o.Votes, counts[o.Id])
+		}
+	}
+}
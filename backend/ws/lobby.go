@@ -0,0 +1,196 @@
+package ws
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+var (
+	ErrNotInLobby        = errors.New("user is not waiting in the lobby")
+	ErrAwaitingAdmission = errors.New("waiting in the lobby for a host to let you in")
+)
+
+// AutoAdmitRules lets some joiners skip the lobby. Guests are never auto-admitted.
+type AutoAdmitRules struct {
+	Contacts     bool // the host's contacts
+	Organization bool // users whose email domain matches the host's
+}
+
+// RequestEntry lets user into the call, or puts them in the lobby and asks the host and moderators
+// to decide. It reports whether the user was admitted straight away. People invited to the call
+// by name never wait.
+func (s *CallSession) RequestEntry(user models.User) (bool, error) {
+	s.Mu.RLock()
+	wait := s.LobbyEnabled && !s.invited(user.Id)
+	rules := s.AutoAdmit
+	host := s.hostID()
+	s.Mu.RUnlock()
+	if !wait || autoAdmits(rules, host, user) {
+		return true, s.Admit(user.Id)
+	}
+
+	s.Mu.Lock()
+	if s.removed[user.Id] {
+		s.Mu.Unlock()
+		return false, ErrNotPermitted
+	}
+	if s.Locked {
+		s.Mu.Unlock()
+		return false, ErrCallLocked
+	}
+	if _, waiting := s.Lobby[user.Id]; waiting {
+		s.Mu.Unlock()
+		return false, nil
+	}
+	entry := models.LobbyEntry{
+		CallId:      s.ID,
+		UserId:      user.Id,
+		Name:        user.Name,
+		Guest:       user.Guest,
+		RequestedAt: time.Now(),
+	}
+	s.Lobby[user.Id] = entry
+	var moderators []*Client
+	for uid, cl := range s.Participants {
+		if cl != nil && s.role(uid).Rank() >= models.RoleModerator.Rank() {
+			moderators = append(moderators, cl)
+		}
+	}
+	s.Mu.Unlock()
+
+	msg := models.WebSocketMessage{Type: models.MessageTypeLobbyJoinRequest, Payload: entry, Time: time.Now()}
+	for _, cl := range moderators {
+		select {
+		case cl.Send <- msg:
+		default:
+		}
+	}
+	return false, nil
+}
+
+// joinError returns why userID may not negotiate media in the call, or nil if they may. People
+// in the lobby have to be admitted first.
+func (s *CallSession) joinError(userID uint) error {
+	if s.CanJoin(userID) {
+		return nil
+	}
+	s.Mu.RLock()
+	_, waiting := s.Lobby[userID]
+	s.Mu.RUnlock()
+	if waiting {
+		return ErrAwaitingAdmission
+	}
+	return ErrCannotJoin
+}
+
+// LobbyEntries returns everyone waiting in the lobby.
+func (s *CallSession) LobbyEntries() []models.LobbyEntry {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	out := make([]models.LobbyEntry, 0, len(s.Lobby))
+	for _, e := range s.Lobby {
+		out = append(out, e)
+	}
+	return out
+}
+
+// invited reports whether userID is the host, already in the call or on its invite list;
+// callers must hold s.Mu.
+func (s *CallSession) invited(userID uint) bool {
+	if _, ok := s.Participants[userID]; ok || userID == s.hostID() {
+		return true
+	}
+	for _, id := range s.Call.CalleeIds {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// decideLobby admits or denies the given waiting users (all of them when userIDs is nil) on
+// behalf of actorID, and tells each of them the outcome.
+func (s *CallSession) decideLobby(actorID uint, userIDs []uint, admit bool) error {
+	s.Mu.Lock()
+	if userIDs == nil {
+		for uid := range s.Lobby {
+			userIDs = append(userIDs, uid)
+		}
+	}
+	decided := make([]uint, 0, len(userIDs))
+	for _, uid := range userIDs {
+		if _, ok := s.Lobby[uid]; !ok {
+			continue
+		}
+		delete(s.Lobby, uid)
+		if admit {
+			// admitting by hand overrides a lock or a full room
+			s.admitted[uid] = true
+			if !s.invited(uid) {
+				s.Call.CalleeIds = append(s.Call.CalleeIds, uid)
+			}
+		}
+		decided = append(decided, uid)
+	}
+	hub := s.hub
+	s.Mu.Unlock()
+
+	if len(userIDs) == 1 && len(decided) == 0 {
+		return ErrNotInLobby
+	}
+	if hub == nil {
+		return nil
+	}
+	typ := models.MessageTypeLobbyDenied
+	if admit {
+		typ = models.MessageTypeLobbyAdmitted
+	}
+	msg := models.WebSocketMessage{
+		Type:    typ,
+		Payload: models.LobbyDecisionMessage{CallId: s.ID, ActorId: actorID},
+		Time:    time.Now(),
+	}
+	for _, uid := range decided {
+		hub.SendToUser(uid, msg)
+		if admit {
+			hub.AttachClient(s, uid)
+		}
+	}
+	return nil
+}
+
+// autoAdmits applies rules to user joining a call hosted by hostID.
+func autoAdmits(rules AutoAdmitRules, hostID uint, user models.User) bool {
+	if user.Guest {
+		return false
+	}
+	if rules.Contacts {
+		var n int64
+		database.Db.Model(&models.UserContact{}).Where("user_id = ? AND contact_id = ?", hostID, user.Id).Count(&n)
+		if n > 0 {
+			return true
+		}
+	}
+	if rules.Organization {
+		var host models.User
+		if err := database.Db.First(&host, hostID).Error; err == nil {
+			domain := emailDomain(host.Email)
+			if domain != "" && domain == emailDomain(user.Email) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
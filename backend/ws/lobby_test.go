@@ -0,0 +1,68 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestLobbyAdmitOverridesLock(t *testing.T) {
+	s := NewCallSession(models.Call{Id: 1, CallerId: 1})
+	s.LobbyEnabled = true
+	alice := models.User{Id: 2, Name: "alice"}
+	bob := models.User{Id: 3, Name: "bob"}
+	for _, u := range []models.User{alice, bob} {
+		if in, err := s.RequestEntry(u); in || err != nil {
+			t.Fatalf("%s: entry = %v, %v; want to wait in the lobby", u.Name, in, err)
+		}
+	}
+	if s.CanJoin(alice.Id) {
+		t.Fatal("waiting user can join")
+	}
+
+	if err := s.Moderate(1, models.ModerationRequest{Action: models.ActionLock}); err != nil {
+		t.Fatal(err)
+	}
+	if in, err := s.RequestEntry(models.User{Id: 4}); in || err != ErrCallLocked {
+		t.Errorf("entry to a locked call = %v, %v; want ErrCallLocked", in, err)
+	}
+	if err := s.Moderate(1, models.ModerationRequest{Action: models.ActionAdmit, TargetId: alice.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Moderate(1, models.ModerationRequest{Action: models.ActionDeny, TargetId: bob.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.CanJoin(alice.Id) {
+		t.Error("admitted user can't join the locked call")
+	}
+	if err := s.Admit(alice.Id); err != nil {
+		t.Errorf("Admit of admitted user = %v", err)
+	}
+	if s.CanJoin(bob.Id) {
+		t.Error("denied user can join")
+	}
+	if err := s.Moderate(1, models.ModerationRequest{Action: models.ActionAdmit, TargetId: bob.Id}); err != ErrNotInLobby {
+		t.Errorf("admitting someone who isn't waiting = %v, want ErrNotInLobby", err)
+	}
+	if len(s.Call.CalleeIds) != 1 {
+		t.Errorf("callees %v, want only alice", s.Call.CalleeIds)
+	}
+}
+
+func TestLobbyAdmitOverridesFullRoom(t *testing.T) {
+	s := NewCallSession(models.Call{Id: 1, CallerId: 1})
+	s.LobbyEnabled = true
+	s.MaxParticipants = 1
+	s.Participants[1] = &Client{UserID: 1, Send: make(chan models.WebSocketMessage, 8)}
+
+	if err := s.Admit(5); err != ErrRoomFull {
+		t.Fatalf("Admit to a full room = %v, want ErrRoomFull", err)
+	}
+	s.RequestEntry(models.User{Id: 2})
+	if err := s.Moderate(1, models.ModerationRequest{Action: models.ActionAdmitAll}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Admit(2); err != nil {
+		t.Errorf("Admit after lobby admission = %v", err)
+	}
+}
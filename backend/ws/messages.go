@@ -0,0 +1,78 @@
+package ws
+
+import (
+	"log"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+// pendingMessageBatch bounds how many offline messages are pushed when a user reconnects;
+// older ones are still available through the REST history.
+const pendingMessageBatch = 200
+
+// SendToUser queues msg on userID's WebSocket and reports whether they are connected.
+func (h *Hub) SendToUser(userID uint, msg models.WebSocketMessage) bool {
+	h.Mutex.RLock()
+	defer h.Mutex.RUnlock()
+	client, ok := h.UserClients[userID]
+	if !ok {
+		return false
+	}
+	select {
+	case client.Send <- msg:
+		return true
+	default:
+		log.Printf("SendToUser: send channel full for user %d", userID)
+		return false
+	}
+}
+
+// NotifyReceipts tells the senders of messages that recipientID received (delivered) or read them.
+func (h *Hub) NotifyReceipts(msgType models.WSMessageType, recipientID uint, messages []models.Message, at time.Time) {
+	bySender := make(map[uint][]uint)
+	for _, m := range messages {
+		bySender[m.SenderId] = append(bySender[m.SenderId], m.Id)
+	}
+	for sender, ids := range bySender {
+		h.SendToUser(sender, models.WebSocketMessage{
+			Type:    msgType,
+			Payload: models.MessageReceiptMessage{UserId: recipientID, MessageIds: ids, At: at},
+			Time:    at,
+		})
+	}
+}
+
+// deliverPendingMessages pushes the direct messages userID received while offline and marks them delivered.
+func (h *Hub) deliverPendingMessages(client *Client) {
+	var pending []models.Message
+	if err := database.Db.Where("recipient_id = ? AND call_id IS NULL AND delivered_at IS NULL", client.UserID).
+		Order("id").Limit(pendingMessageBatch).Find(&pending).Error; err != nil {
+		log.Printf("deliverPendingMessages: query for user %d: %v", client.UserID, err)
+		return
+	}
+
+	delivered := make([]models.Message, 0, len(pending))
+	for _, m := range pending {
+		msg := models.WebSocketMessage{Type: models.MessageTypeMessageNew, Payload: m, Time: m.CreatedAt}
+		if !h.SendToUser(client.UserID, msg) {
+			break
+		}
+		delivered = append(delivered, m)
+	}
+	if len(delivered) == 0 {
+		return
+	}
+
+	now := time.Now()
+	ids := make([]uint, len(delivered))
+	for i, m := range delivered {
+		ids[i] = m.Id
+	}
+	if err := database.Db.Model(&models.Message{}).Where("id IN ?", ids).Update("delivered_at", now).Error; err != nil {
+		log.Printf("deliverPendingMessages: update for user %d: %v", client.UserID, err)
+		return
+	}
+	h.NotifyReceipts(models.MessageTypeMessageDelivered, client.UserID, delivered, now)
+}
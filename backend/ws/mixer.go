@@ -0,0 +1,456 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+const (
+	mixerSink = "mixer"
+
+	mixSampleRate    = 48000
+	mixFrameSize     = mixSampleRate / 50 // 20ms of mono PCM
+	mixFrameDuration = 20 * time.Millisecond
+	mixQueueFrames   = 5 // per-source jitter allowance before old audio is dropped
+	mixBitrate       = 24000
+	mixMaxPacketSize = 1500
+)
+
+// ErrMixingUnavailable is returned when the server was built without Opus support (see opus.go).
+var ErrMixingUnavailable = errors.New("server-side audio mixing is not available in this build")
+
+// audioCodec creates the decoders and encoders of the mixer. The libopus bindings in opus.go are
+// used when the server is built with the opus tag; tests substitute their own.
+type audioCodec interface {
+	NewDecoder(sampleRate, channels int) (opusDecoder, error)
+	NewEncoder(sampleRate, channels, bitrate int) (opusEncoder, error)
+}
+
+type opusDecoder interface {
+	Decode(data []byte, pcm []int16) (int, error)
+}
+
+type opusEncoder interface {
+	Encode(pcm []int16, data []byte) (int, error)
+}
+
+// AudioMixer decodes every published Opus track of a call and sends each mixing subscriber a
+// single Opus track containing everyone but themselves.
+type AudioMixer struct {
+	codec   audioCodec
+	mu      sync.Mutex
+	sources map[string]*mixSource // trackID -> decoded audio
+	outputs map[uint]*mixOutput   // subscriber userID -> mixed track
+	done    chan struct{}
+}
+
+// mixSource is attached as a ForwardTrack sink and buffers decoded 20ms frames.
+type mixSource struct {
+	publisherID uint
+	track       *ForwardTrack
+	decoder     opusDecoder
+
+	mu     sync.Mutex
+	pcm    []int16 // decoded samples not yet split into frames
+	frames [][]int16
+}
+
+type mixOutput struct {
+	track   *webrtc.TrackLocalStaticSample
+	sender  *webrtc.RTPSender
+	encoder opusEncoder
+}
+
+func newAudioMixer(codec audioCodec) *AudioMixer {
+	m := &AudioMixer{
+		codec:   codec,
+		sources: make(map[string]*mixSource),
+		outputs: make(map[uint]*mixOutput),
+		done:    make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+func (src *mixSource) WriteRTP(pkt *rtp.Packet) error {
+	if len(pkt.Payload) == 0 {
+		return nil
+	}
+	buf := make([]int16, mixSampleRate*120/1000) // the longest Opus packet is 120ms
+	n, err := src.decoder.Decode(pkt.Payload, buf)
+	if err != nil {
+		return err
+	}
+
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	src.pcm = append(src.pcm, buf[:n]...)
+	for len(src.pcm) >= mixFrameSize {
+		src.frames = append(src.frames, src.pcm[:mixFrameSize:mixFrameSize])
+		src.pcm = src.pcm[mixFrameSize:]
+	}
+	if over := len(src.frames) - mixQueueFrames; over > 0 {
+		src.frames = src.frames[over:]
+	}
+	return nil
+}
+
+func (src *mixSource) Close() error { return nil }
+
+func (src *mixSource) pop() []int16 {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	if len(src.frames) == 0 {
+		return nil
+	}
+	f := src.frames[0]
+	src.frames = src.frames[1:]
+	return f
+}
+
+// addSource starts decoding a published Opus track.
+func (m *AudioMixer) addSource(publisherID uint, trackID string, track *ForwardTrack) error {
+	dec, err := m.codec.NewDecoder(mixSampleRate, 1)
+	if err != nil {
+		return err
+	}
+	src := &mixSource{publisherID: publisherID, track: track, decoder: dec}
+
+	m.mu.Lock()
+	if old, ok := m.sources[trackID]; ok {
+		old.track.RemoveSink(mixerSink)
+	}
+	m.sources[trackID] = src
+	m.mu.Unlock()
+	track.AddSink(mixerSink, src)
+	return nil
+}
+
+func (m *AudioMixer) removeSource(trackID string) {
+	m.mu.Lock()
+	src, ok := m.sources[trackID]
+	delete(m.sources, trackID)
+	m.mu.Unlock()
+	if ok {
+		src.track.RemoveSink(mixerSink)
+	}
+}
+
+// addOutput creates the mixed track for userID; the caller adds it to the subscriber's PeerConnection.
+func (m *AudioMixer) addOutput(userID uint) (*mixOutput, error) {
+	enc, err := m.codec.NewEncoder(mixSampleRate, 1, mixBitrate)
+	if err != nil {
+		return nil, err
+	}
+	track, err := webrtc.NewTrackLocalStaticSample(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: mixSampleRate, Channels: 2},
+		"mixed-audio", "mixer",
+	)
+	if err != nil {
+		return nil, err
+	}
+	out := &mixOutput{track: track, encoder: enc}
+	m.mu.Lock()
+	m.outputs[userID] = out
+	m.mu.Unlock()
+	return out, nil
+}
+
+func (m *AudioMixer) removeOutput(userID uint) *mixOutput {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := m.outputs[userID]
+	delete(m.outputs, userID)
+	return out
+}
+
+func (m *AudioMixer) output(userID uint) *mixOutput {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.outputs[userID]
+}
+
+func (m *AudioMixer) outputCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.outputs)
+}
+
+// stop ends the mixing loop and detaches from every source.
+func (m *AudioMixer) stop() {
+	m.mu.Lock()
+	sources := m.sources
+	m.sources = make(map[string]*mixSource)
+	m.outputs = make(map[uint]*mixOutput)
+	m.mu.Unlock()
+	for _, src := range sources {
+		src.track.RemoveSink(mixerSink)
+	}
+	close(m.done)
+}
+
+func (m *AudioMixer) run() {
+	ticker := time.NewTicker(mixFrameDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.mixFrame()
+		}
+	}
+}
+
+// mixFrame sums one frame from every source and sends each subscriber the sum minus their own voice.
+func (m *AudioMixer) mixFrame() {
+	m.mu.Lock()
+	sources := make([]*mixSource, 0, len(m.sources))
+	for _, src := range m.sources {
+		sources = append(sources, src)
+	}
+	outputs := make(map[uint]*mixOutput, len(m.outputs))
+	for uid, out := range m.outputs {
+		outputs[uid] = out
+	}
+	m.mu.Unlock()
+	if len(outputs) == 0 {
+		return
+	}
+
+	total := make([]int32, mixFrameSize)
+	own := make(map[uint][]int32)
+	for _, src := range sources {
+		frame := src.pop()
+		if frame == nil {
+			continue
+		}
+		mine := own[src.publisherID]
+		if mine == nil {
+			mine = make([]int32, mixFrameSize)
+			own[src.publisherID] = mine
+		}
+		for i, v := range frame {
+			total[i] += int32(v)
+			mine[i] += int32(v)
+		}
+	}
+
+	pcm := make([]int16, mixFrameSize)
+	packet := make([]byte, mixMaxPacketSize)
+	for uid, out := range outputs {
+		mine := own[uid]
+		for i, v := range total {
+			if mine != nil {
+				v -= mine[i]
+			}
+			pcm[i] = clampSample(v)
+		}
+		n, err := out.encoder.Encode(pcm, packet)
+		if err != nil {
+			log.Printf("AudioMixer: encode for user %d: %v", uid, err)
+			continue
+		}
+		data := make([]byte, n)
+		copy(data, packet[:n])
+		if err := out.track.WriteSample(media.Sample{Data: data, Duration: mixFrameDuration}); err != nil {
+			log.Printf("AudioMixer: write for user %d: %v", uid, err)
+		}
+	}
+}
+
+func clampSample(v int32) int16 {
+	switch {
+	case v > 32767:
+		return 32767
+	case v < -32768:
+		return -32768
+	default:
+		return int16(v)
+	}
+}
+
+// SetAudioMixing switches every participant of the call to mixed audio, or back to one track per publisher.
+func (s *CallSession) SetAudioMixing(enabled bool) error {
+	if enabled && s.audioCodec == nil {
+		return ErrMixingUnavailable
+	}
+	s.Mu.Lock()
+	s.AudioMixing = enabled
+	parts := make([]*Client, 0, len(s.Participants))
+	for _, p := range s.Participants {
+		parts = append(parts, p)
+	}
+	s.Mu.Unlock()
+
+	var errs []error
+	for _, p := range parts {
+		if err := s.applyAudioMixing(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.Broadcast(audioMixingMessage(s.ID, enabled, true), 0)
+	return errors.Join(errs...)
+}
+
+// SetParticipantAudioMixing opts a single participant in or out of mixed audio. The choice is kept
+// for the lifetime of the session, so it also applies when the participant (re)negotiates later.
+func (s *CallSession) SetParticipantAudioMixing(userID uint, enabled bool) error {
+	if enabled && s.audioCodec == nil {
+		return ErrMixingUnavailable
+	}
+	s.Mu.Lock()
+	if enabled {
+		s.mixedAudio[userID] = true
+	} else {
+		delete(s.mixedAudio, userID)
+	}
+	p := s.Participants[userID]
+	s.Mu.Unlock()
+	if p == nil {
+		return nil
+	}
+	if err := s.applyAudioMixing(p); err != nil {
+		return err
+	}
+	select {
+	case p.Send <- audioMixingMessage(s.ID, s.ReceivesMixedAudio(userID), false):
+	default:
+	}
+	return nil
+}
+
+// ReceivesMixedAudio reports whether userID gets the mixed track instead of each publisher's audio.
+func (s *CallSession) ReceivesMixedAudio(userID uint) bool {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.mixesAudioFor(userID)
+}
+
+// mixesAudioFor is ReceivesMixedAudio for callers holding s.Mu.
+func (s *CallSession) mixesAudioFor(userID uint) bool {
+	return s.AudioMixing || s.mixedAudio[userID]
+}
+
+// applyAudioMixing updates a connected participant's senders to the current mode and renegotiates.
+func (s *CallSession) applyAudioMixing(p *Client) error {
+	if p == nil || p.PeerConn == nil {
+		return nil
+	}
+	changed, err := s.syncMixedAudio(p.UserID, p.PeerConn)
+	if err != nil || !changed {
+		return err
+	}
+	go func() {
+		if err := s.RenegotiateParticipant(p); err != nil {
+			log.Printf("renegotiate error for %d: %v", p.UserID, err)
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+		s.MapMIDsForParticipant(p)
+	}()
+	return nil
+}
+
+// syncMixedAudio swaps the per-publisher Opus senders of pc for the mixed track or back, depending
+// on userID's mode. It reports whether the senders changed.
+func (s *CallSession) syncMixedAudio(userID uint, pc *webrtc.PeerConnection) (bool, error) {
+	s.Mu.Lock()
+	want := s.mixesAudioFor(userID)
+	if want && s.mixer == nil {
+		s.mixer = newAudioMixer(s.audioCodec)
+		for id, t := range s.PublishedTracks {
+			if isCodec(t, webrtc.MimeTypeOpus) {
+				if err := s.mixer.addSource(s.PublishedOwners[id], id, t); err != nil {
+					log.Printf("AudioMixer: cannot decode track %s: %v", id, err)
+				}
+			}
+		}
+	}
+	mixer := s.mixer
+	audio := make([]*ForwardTrack, 0, len(s.PublishedTracks))
+	for id, t := range s.PublishedTracks {
+		if isCodec(t, webrtc.MimeTypeOpus) && s.PublishedOwners[id] != userID {
+			audio = append(audio, t)
+		}
+	}
+	s.Mu.Unlock()
+
+	if want {
+		if mixer.output(userID) != nil {
+			return false, nil
+		}
+		out, err := mixer.addOutput(userID)
+		if err != nil {
+			return false, err
+		}
+		if out.sender, err = pc.AddTrack(out.track); err != nil {
+			mixer.removeOutput(userID)
+			return false, err
+		}
+		go drainRTCP(out.sender)
+		for _, sender := range pc.GetSenders() {
+			if t, ok := sender.Track().(*ForwardTrack); ok && isCodec(t, webrtc.MimeTypeOpus) {
+				if err := pc.RemoveTrack(sender); err != nil {
+					log.Printf("RemoveTrack error for participant %d: %v", userID, err)
+				}
+			}
+		}
+		return true, nil
+	}
+
+	if mixer == nil {
+		return false, nil
+	}
+	out := mixer.removeOutput(userID)
+	if out == nil {
+		return false, nil
+	}
+	if err := pc.RemoveTrack(out.sender); err != nil {
+		log.Printf("RemoveTrack error for participant %d: %v", userID, err)
+	}
+	for _, t := range audio {
+		sender, err := pc.AddTrack(t)
+		if err != nil {
+			log.Printf("AddTrack error for participant %d: %v", userID, err)
+			continue
+		}
+		go t.ServeRTCP(sender)
+	}
+	s.Mu.Lock()
+	s.releaseMixer()
+	s.Mu.Unlock()
+	return true, nil
+}
+
+// releaseMixer stops the mixer once nobody receives mixed audio; callers must hold s.Mu.
+func (s *CallSession) releaseMixer() {
+	if s.mixer != nil && s.mixer.outputCount() == 0 {
+		s.mixer.stop()
+		s.mixer = nil
+	}
+}
+
+// drainRTCP reads RTCP for a sender nobody answers feedback for, so interceptors keep running.
+func drainRTCP(sender *webrtc.RTPSender) {
+	for {
+		if _, _, err := sender.ReadRTCP(); err != nil {
+			return
+		}
+	}
+}
+
+func audioMixingMessage(callID uint, enabled, wholeCall bool) models.WebSocketMessage {
+	return models.WebSocketMessage{
+		Type:    models.MessageTypeAudioMixing,
+		Payload: models.AudioMixingMessage{CallId: callID, Enabled: enabled, WholeCall: wholeCall},
+		Time:    time.Now(),
+	}
+}
@@ -0,0 +1,168 @@
+package ws
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pion/rtp"
+)
+
+// fakeCodec stands in for libopus. A packet decodes to payload[1] milliseconds of samples that
+// all equal 100*payload[0], and encoders keep the last frame they were given.
+type fakeCodec struct{}
+
+type fakeDecoder struct{}
+
+type fakeEncoder struct{ last []int16 }
+
+func (fakeCodec) NewDecoder(int, int) (opusDecoder, error)      { return fakeDecoder{}, nil }
+func (fakeCodec) NewEncoder(int, int, int) (opusEncoder, error) { return &fakeEncoder{}, nil }
+
+func (fakeDecoder) Decode(data []byte, pcm []int16) (int, error) {
+	n := int(data[1]) * mixSampleRate / 1000
+	for i := 0; i < n; i++ {
+		pcm[i] = int16(int8(data[0])) * 100
+	}
+	return n, nil
+}
+
+func (e *fakeEncoder) Encode(pcm []int16, data []byte) (int, error) {
+	e.last = append([]int16(nil), pcm...)
+	data[0] = 0xf8
+	return 1, nil
+}
+
+// speak queues ms milliseconds of audio at level on the mixer's source for trackID.
+func speak(t *testing.T, m *AudioMixer, trackID string, level int8, ms int) {
+	t.Helper()
+	m.mu.Lock()
+	src := m.sources[trackID]
+	m.mu.Unlock()
+	for ; ms > 0; ms -= 10 {
+		if err := src.WriteRTP(&rtp.Packet{Payload: []byte{byte(level), 10}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// heard returns the first sample of the last frame encoded for userID.
+func heard(t *testing.T, m *AudioMixer, userID uint) int16 {
+	t.Helper()
+	last := m.output(userID).encoder.(*fakeEncoder).last
+	if len(last) != mixFrameSize {
+		t.Fatalf("user %d was sent %d samples, want %d", userID, len(last), mixFrameSize)
+	}
+	return last[0]
+}
+
+// testMixer returns a mixer that only mixes when the test calls mixFrame.
+func testMixer() *AudioMixer {
+	return &AudioMixer{codec: fakeCodec{}, sources: make(map[string]*mixSource), outputs: make(map[uint]*mixOutput)}
+}
+
+func TestMixSourceSplitsFrames(t *testing.T) {
+	m := testMixer()
+	if err := m.addSource(1, "mic", opusTrack("mic")); err != nil {
+		t.Fatal(err)
+	}
+	speak(t, m, "mic", 1, 30)
+	src := m.sources["mic"]
+	if f := src.pop(); len(f) != mixFrameSize {
+		t.Fatalf("first frame has %d samples, want %d", len(f), mixFrameSize)
+	}
+	if f := src.pop(); f != nil {
+		t.Fatalf("10ms of audio made a frame of %d samples", len(f))
+	}
+
+	speak(t, m, "mic", 1, 200)
+	n := 0
+	for src.pop() != nil {
+		n++
+	}
+	if n != mixQueueFrames {
+		t.Errorf("%d frames queued, want at most %d", n, mixQueueFrames)
+	}
+}
+
+func TestMixFrameLeavesOutOwnVoice(t *testing.T) {
+	m := testMixer()
+	for uid, id := range map[uint]string{1: "alice", 2: "bob", 3: "carol"} {
+		if err := m.addSource(uid, id, opusTrack(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, uid := range []uint{1, 2, 4} {
+		if _, err := m.addOutput(uid); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	speak(t, m, "alice", 10, 20)
+	speak(t, m, "bob", 20, 20)
+	speak(t, m, "carol", -5, 20)
+	m.mixFrame()
+	for uid, want := range map[uint]int16{1: 1500, 2: 500, 4: 2500} {
+		if got := heard(t, m, uid); got != want {
+			t.Errorf("user %d heard %d, want %d", uid, got, want)
+		}
+	}
+
+	// bob is silent this frame, so he hears everyone and nobody hears him
+	speak(t, m, "alice", 120, 20)
+	speak(t, m, "carol", 120, 20)
+	m.mixFrame()
+	for uid, want := range map[uint]int16{1: 12000, 2: 24000, 4: 24000} {
+		if got := heard(t, m, uid); got != want {
+			t.Errorf("user %d heard %d, want %d", uid, got, want)
+		}
+	}
+}
+
+func TestMixFrameClampsLoudMixes(t *testing.T) {
+	m := testMixer()
+	for uid, id := range map[uint]string{1: "alice", 2: "bob", 3: "carol"} {
+		if err := m.addSource(uid, id, opusTrack(id)); err != nil {
+			t.Fatal(err)
+		}
+		speak(t, m, id, -120, 20)
+	}
+	if _, err := m.addOutput(4); err != nil {
+		t.Fatal(err)
+	}
+	m.mixFrame()
+	if got := heard(t, m, 4); got != -32768 {
+		t.Errorf("mix of three loud speakers = %d, want -32768", got)
+	}
+}
+
+func TestClampSample(t *testing.T) {
+	for in, want := range map[int32]int16{0: 0, -5: -5, 40000: 32767, -40000: -32768, 32767: 32767} {
+		if got := clampSample(in); got != want {
+			t.Errorf("clampSample(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestAudioMixingNeedsCodec(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "alice", "bob")
+	_, s := moderatedCall(t, users)
+	s.audioCodec = nil
+	if err := s.SetAudioMixing(true); !errors.Is(err, ErrMixingUnavailable) {
+		t.Errorf("SetAudioMixing = %v, want ErrMixingUnavailable", err)
+	}
+	if err := s.SetParticipantAudioMixing(users[1], true); !errors.Is(err, ErrMixingUnavailable) {
+		t.Errorf("SetParticipantAudioMixing = %v, want ErrMixingUnavailable", err)
+	}
+	if s.ReceivesMixedAudio(users[1]) {
+		t.Error("bob receives mixed audio after a refused opt-in")
+	}
+
+	s.audioCodec = fakeCodec{}
+	if err := s.SetParticipantAudioMixing(users[1], true); err != nil {
+		t.Fatal(err)
+	}
+	if !s.ReceivesMixedAudio(users[1]) || s.ReceivesMixedAudio(users[0]) {
+		t.Error("only bob should receive mixed audio")
+	}
+}
@@ -0,0 +1,307 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/webrtc/v4"
+)
+
+var (
+	ErrNotPermitted      = errors.New("your call role does not allow this")
+	ErrNotInCall         = errors.New("user is not in this call")
+	ErrCallLocked        = errors.New("call is locked")
+	ErrCannotJoin        = errors.New("you are not allowed to join this call")
+	ErrGuestNotAllowed   = errors.New("guests can only act in the call they were invited to")
+	ErrInvalidModeration = errors.New("invalid moderation request")
+	ErrViewerPublish     = errors.New("viewers cannot publish media")
+)
+
+// role returns userID's role; callers must hold s.Mu.
+func (s *CallSession) role(userID uint) models.CallRole {
+	if userID == s.hostID() {
+		return models.RoleHost
+	}
+	if r, ok := s.Roles[userID]; ok {
+		return r
+	}
+	return models.RoleParticipant
+}
+
+// Role returns userID's role in the call.
+func (s *CallSession) Role(userID uint) models.CallRole {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.role(userID)
+}
+
+// CanModerate reports whether userID is the host or a moderator.
+func (s *CallSession) CanModerate(userID uint) bool {
+	return s.Role(userID).Rank() >= models.RoleModerator.Rank()
+}
+
+// CallRoles returns the role of every participant along with the host and lock state.
+func (s *CallSession) CallRoles() models.CallRolesMessage {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	roles := make(map[uint]models.CallRole, len(s.Participants))
+	for uid := range s.Participants {
+		roles[uid] = s.role(uid)
+	}
+	return models.CallRolesMessage{CallId: s.ID, HostId: s.hostID(), Locked: s.Locked, Roles: roles}
+}
+
+// ParticipantList returns everyone connected to the call with their role, marking guests.
+func (s *CallSession) ParticipantList() []models.CallParticipant {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	out := make([]models.CallParticipant, 0, len(s.Participants))
+	for uid, cl := range s.Participants {
+		p := models.CallParticipant{UserId: uid, Role: s.role(uid)}
+		if cl != nil {
+			p.Name = cl.Username
+			p.Guest = cl.Guest
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// isGuest reports whether userID joined with a guest token; callers must hold s.Mu.
+func (s *CallSession) isGuest(userID uint) bool {
+	cl := s.Participants[userID]
+	return cl != nil && cl.Guest
+}
+
+// outranks checks that actorID may act on targetID; callers must hold s.Mu.
+func (s *CallSession) outranks(actorID, targetID uint) error {
+	if _, ok := s.Participants[targetID]; !ok {
+		return ErrNotInCall
+	}
+	if s.role(actorID).Rank() <= s.role(targetID).Rank() {
+		return ErrNotPermitted
+	}
+	return nil
+}
+
+// Moderate applies a moderation request from actorID. Hosts and moderators can act on anyone
+// ranked below them; only the host can change roles or hand over the host role.
+func (s *CallSession) Moderate(actorID uint, req models.ModerationRequest) error {
+	if !s.CanModerate(actorID) {
+		return ErrNotPermitted
+	}
+	req.CallId = s.ID
+
+	switch req.Action {
+	case models.ActionRequestMute:
+		return s.requestMute(actorID, req)
+	case models.ActionStopMedia, models.ActionResumeMedia:
+		return s.setMediaStopped(actorID, req)
+	case models.ActionRemove:
+		return s.removeByModerator(actorID, req)
+	case models.ActionLock, models.ActionUnlock:
+		s.Mu.Lock()
+		s.Locked = req.Action == models.ActionLock
+		s.Mu.Unlock()
+	case models.ActionSetRole:
+		return s.setRole(actorID, req)
+	case models.ActionTransferHost:
+		return s.transferHost(actorID, req)
+	case models.ActionEndCall:
+		s.endForAll(actorID, req)
+		return nil
+	case models.ActionEnableLobby:
+		s.Mu.Lock()
+		s.LobbyEnabled = true
+		s.Mu.Unlock()
+	case models.ActionDisableLobby:
+		s.Mu.Lock()
+		s.LobbyEnabled = false
+		s.Mu.Unlock()
+		if err := s.decideLobby(actorID, nil, true); err != nil {
+			return err
+		}
+	case models.ActionAdmit, models.ActionDeny:
+		if err := s.decideLobby(actorID, []uint{req.TargetId}, req.Action == models.ActionAdmit); err != nil {
+			return err
+		}
+	case models.ActionAdmitAll:
+		if err := s.decideLobby(actorID, nil, true); err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidModeration
+	}
+	s.Broadcast(moderationMessage(actorID, req), 0)
+	return nil
+}
+
+func (s *CallSession) requestMute(actorID uint, req models.ModerationRequest) error {
+	if !validMediaKind(req.Kind) {
+		return ErrInvalidModeration
+	}
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	if err := s.outranks(actorID, req.TargetId); err != nil {
+		return err
+	}
+	// only the target is asked; muting is still up to their client
+	if target := s.Participants[req.TargetId]; target != nil {
+		select {
+		case target.Send <- moderationMessage(actorID, req):
+		default:
+		}
+	}
+	return nil
+}
+
+// setMediaStopped stops or resumes forwarding every audio or video track of the target,
+// including screen shares for video.
+func (s *CallSession) setMediaStopped(actorID uint, req models.ModerationRequest) error {
+	if !validMediaKind(req.Kind) {
+		return ErrInvalidModeration
+	}
+	kind := webrtc.NewRTPCodecType(req.Kind)
+	stop := req.Action == models.ActionStopMedia
+	s.Mu.RLock()
+	if err := s.outranks(actorID, req.TargetId); err != nil {
+		s.Mu.RUnlock()
+		return err
+	}
+	var tracks []*ForwardTrack
+	for id, t := range s.PublishedTracks {
+		if s.PublishedOwners[id] == req.TargetId && t.Kind() == kind {
+			t.SetStopped(stop)
+			tracks = append(tracks, t)
+		}
+	}
+	s.Mu.RUnlock()
+	if !stop {
+		// subscribers missed every frame since the stop, so resume from a keyframe
+		for _, t := range tracks {
+			t.RequestKeyframe()
+		}
+	}
+	s.Broadcast(moderationMessage(actorID, req), 0)
+	return nil
+}
+
+// removeByModerator removes the target from the call and keeps them from rejoining.
+func (s *CallSession) removeByModerator(actorID uint, req models.ModerationRequest) error {
+	s.Mu.Lock()
+	if err := s.outranks(actorID, req.TargetId); err != nil {
+		s.Mu.Unlock()
+		return err
+	}
+	s.removed[req.TargetId] = true
+	delete(s.admitted, req.TargetId)
+	delete(s.Roles, req.TargetId)
+	msg := moderationMessage(actorID, req)
+	if target := s.Participants[req.TargetId]; target != nil {
+		select {
+		case target.Send <- msg:
+		default:
+		}
+	}
+	s.Mu.Unlock()
+
+	s.RemoveParticipant(req.TargetId, &msg)
+	return nil
+}
+
+func (s *CallSession) setRole(actorID uint, req models.ModerationRequest) error {
+	if req.Role != models.RoleModerator && req.Role != models.RoleParticipant && req.Role != models.RoleViewer {
+		return ErrInvalidModeration
+	}
+	s.Mu.Lock()
+	if actorID != s.hostID() {
+		s.Mu.Unlock()
+		return ErrNotPermitted
+	}
+	if _, ok := s.Participants[req.TargetId]; !ok || req.TargetId == actorID {
+		s.Mu.Unlock()
+		return ErrNotInCall
+	}
+	if req.Role == models.RoleModerator && s.isGuest(req.TargetId) {
+		s.Mu.Unlock()
+		return ErrNotPermitted
+	}
+	s.Roles[req.TargetId] = req.Role
+	var published []string
+	if req.Role == models.RoleViewer {
+		for id, owner := range s.PublishedOwners {
+			if owner == req.TargetId {
+				published = append(published, id)
+			}
+		}
+	}
+	s.Mu.Unlock()
+
+	// viewers don't publish, so take down whatever they were sending
+	for _, id := range published {
+		s.unpublishTrack(id, nil, actorID)
+	}
+	s.Broadcast(moderationMessage(actorID, req), 0)
+	return nil
+}
+
+// transferHost makes the target the host; the previous host stays on as a moderator.
+func (s *CallSession) transferHost(actorID uint, req models.ModerationRequest) error {
+	s.Mu.Lock()
+	if actorID != s.hostID() {
+		s.Mu.Unlock()
+		return ErrNotPermitted
+	}
+	if _, ok := s.Participants[req.TargetId]; !ok || req.TargetId == actorID {
+		s.Mu.Unlock()
+		return ErrNotInCall
+	}
+	if s.isGuest(req.TargetId) {
+		s.Mu.Unlock()
+		return ErrNotPermitted
+	}
+	s.HostId = req.TargetId
+	delete(s.Roles, req.TargetId)
+	s.Roles[actorID] = models.RoleModerator
+	s.Mu.Unlock()
+
+	req.Role = models.RoleHost
+	s.Broadcast(moderationMessage(actorID, req), 0)
+	return nil
+}
+
+// endForAll ends the call for every participant, records it as ended and removes it from the hub.
+func (s *CallSession) endForAll(actorID uint, req models.ModerationRequest) {
+	s.NotifyBreakouts(moderationMessage(actorID, req))
+	s.hub.finishCall(s, models.Ended)
+}
+
+func validMediaKind(kind string) bool {
+	return kind == webrtc.RTPCodecTypeAudio.String() || kind == webrtc.RTPCodecTypeVideo.String()
+}
+
+func moderationMessage(actorID uint, req models.ModerationRequest) models.WebSocketMessage {
+	return models.WebSocketMessage{
+		Type: models.MessageTypeModeration,
+		Payload: models.ModerationMessage{
+			CallId:   req.CallId,
+			Action:   req.Action,
+			ActorId:  actorID,
+			TargetId: req.TargetId,
+			Kind:     req.Kind,
+			Role:     req.Role,
+		},
+		Time: time.Now(),
+	}
+}
+
+// decodePayload converts a payload read from a client (a generic JSON value) into out.
+func decodePayload(payload interface{}, out interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,101 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+// moderatedCall creates a hub session hosted by the first of users, with everyone connected.
+func moderatedCall(t *testing.T, users []uint) (*Hub, *CallSession) {
+	t.Helper()
+	h := NewHub()
+	call := models.Call{CallerId: users[0], CalleeIds: users[1:], Status: models.Ongoing}
+	if err := database.Db.Create(&call).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, uid := range users {
+		testClient(h, uid)
+	}
+	return h, h.CreateCallSession(&call)
+}
+
+func TestModerationRanks(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "host", "mod", "alice", "bob")
+	host, mod, alice, bob := users[0], users[1], users[2], users[3]
+	_, s := moderatedCall(t, users)
+
+	if err := s.Moderate(host, models.ModerationRequest{Action: models.ActionSetRole, TargetId: mod, Role: models.RoleModerator}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Moderate(alice, models.ModerationRequest{Action: models.ActionRequestMute, TargetId: bob, Kind: "audio"}); err != ErrNotPermitted {
+		t.Errorf("participant moderating = %v, want ErrNotPermitted", err)
+	}
+	if err := s.Moderate(mod, models.ModerationRequest{Action: models.ActionRequestMute, TargetId: host, Kind: "audio"}); err != ErrNotPermitted {
+		t.Errorf("moderator acting on host = %v, want ErrNotPermitted", err)
+	}
+	if err := s.Moderate(mod, models.ModerationRequest{Action: models.ActionSetRole, TargetId: alice, Role: models.RoleModerator}); err != ErrNotPermitted {
+		t.Errorf("moderator setting roles = %v, want ErrNotPermitted", err)
+	}
+	if err := s.Moderate(mod, models.ModerationRequest{Action: models.ActionRequestMute, TargetId: alice, Kind: "audio"}); err != nil {
+		t.Errorf("moderator muting participant = %v", err)
+	}
+
+	if err := s.Moderate(host, models.ModerationRequest{Action: models.ActionTransferHost, TargetId: alice}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Role(alice) != models.RoleHost || s.Role(host) != models.RoleModerator {
+		t.Errorf("after transfer: alice %s, old host %s", s.Role(alice), s.Role(host))
+	}
+}
+
+func TestResumeMediaRequestsKeyframe(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "host", "alice")
+	_, s := moderatedCall(t, users)
+
+	video := vp8Track("cam")
+	keyframes := 0
+	video.SetKeyframeRequester(func() error { keyframes++; return nil })
+	audio := opusTrack("mic")
+	audio.SetKeyframeRequester(func() error { t.Error("keyframe requested for audio"); return nil })
+	s.PublishTrack(users[1], "cam", video, models.SourceCamera, false)
+	s.PublishTrack(users[1], "mic", audio, models.SourceAudio, false)
+
+	for _, kind := range []string{"video", "audio"} {
+		if err := s.Moderate(users[0], models.ModerationRequest{Action: models.ActionStopMedia, TargetId: users[1], Kind: kind}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !video.Stopped() || !audio.Stopped() || keyframes != 0 {
+		t.Fatalf("after stop: stopped %v/%v, %d keyframe requests", video.Stopped(), audio.Stopped(), keyframes)
+	}
+	for _, kind := range []string{"video", "audio"} {
+		if err := s.Moderate(users[0], models.ModerationRequest{Action: models.ActionResumeMedia, TargetId: users[1], Kind: kind}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if video.Stopped() || audio.Stopped() || keyframes != 1 {
+		t.Errorf("after resume: stopped %v/%v, %d keyframe requests, want 1", video.Stopped(), audio.Stopped(), keyframes)
+	}
+}
+
+func TestEndForAllForgetsSession(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "host", "alice")
+	h, s := moderatedCall(t, users)
+
+	if err := s.Moderate(users[0], models.ModerationRequest{Action: models.ActionEndCall}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.GetCallSession(s.ID); ok {
+		t.Error("ended call is still in the hub")
+	}
+	var call models.Call
+	database.Db.First(&call, s.ID)
+	if call.Status != models.Ended || call.EndTime == nil {
+		t.Errorf("call saved as %s, end %v", call.Status, call.EndTime)
+	}
+}
@@ -0,0 +1,148 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/webrtc/v4"
+)
+
+func TestBrokenOffersAreIgnored(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "alice", "bob")
+	h, s := moderatedCall(t, users)
+	bob := h.UserClients[users[1]]
+
+	for _, payload := range []string{
+		`{"callId":999,"offer":{"type":"offer","sdp":"v=0"}}`,
+		fmt.Sprintf(`{"callId":%d,"offer":"not base64"}`, s.ID),
+		fmt.Sprintf(`{"callId":%d,"offer":{"type":"offer","sdp":"garbage"}}`, s.ID),
+	} {
+		h.handleOffer(clientMessage(t, users[1], "call_offer", payload))
+		h.handleCallAccepted(clientMessage(t, users[1], "call_accepted", payload))
+	}
+	if bob.PeerConn != nil {
+		t.Error("bob got a peer connection from a broken offer")
+	}
+	if s.Answered(users[1]) {
+		t.Error("a broken offer counted as answering the call")
+	}
+	if n := len(messagesOfType(drain(bob), "answer")); n != 0 {
+		t.Errorf("bob got %d answers, want 0", n)
+	}
+}
+
+// audioOffer returns an SDP offer that receives one audio track.
+func audioOffer(t *testing.T) string {
+	t.Helper()
+	sdp := offerFrom(t, func(pc *webrtc.PeerConnection) error {
+		_, err := pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly})
+		return err
+	})
+	b, err := json.Marshal(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: sdp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// refused reports whether c was sent want as an error, and fails if it was sent an answer.
+func refused(t *testing.T, c *Client, want error) bool {
+	t.Helper()
+	msgs := drain(c)
+	if n := len(messagesOfType(msgs, "answer")); n != 0 {
+		t.Errorf("user %d got %d answers", c.UserID, n)
+	}
+	for _, m := range messagesOfType(msgs, models.MessageTypeError) {
+		if m.Payload.(map[string]string)["error"] == want.Error() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOffersNeedPermissionToJoin(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "host", "bob", "carol")
+	host, bob, carol := users[0], users[1], users[2]
+	h, s := moderatedCall(t, users[:2])
+	cc := testClient(h, carol)
+	offer := func(from uint) {
+		h.handleOffer(clientMessage(t, from, "call_offer", fmt.Sprintf(`{"callId":%d,"offer":%s}`, s.ID, audioOffer(t))))
+	}
+
+	offer(carol)
+	if !refused(t, cc, ErrCannotJoin) {
+		t.Error("uninvited user was not refused")
+	}
+
+	if err := s.Moderate(host, models.ModerationRequest{Action: models.ActionRemove, TargetId: bob}); err != nil {
+		t.Fatal(err)
+	}
+	cb := h.UserClients[bob]
+	drain(cb)
+	offer(bob)
+	h.handleCallAccepted(clientMessage(t, bob, "call_accepted", fmt.Sprintf(`{"callId":%d,"offer":%s}`, s.ID, audioOffer(t))))
+	if !refused(t, cb, ErrCannotJoin) {
+		t.Error("removed user was not refused")
+	}
+
+	s.Mu.Lock()
+	s.Call.CalleeIds = append(s.Call.CalleeIds, carol)
+	s.Locked = true
+	s.Mu.Unlock()
+	offer(carol)
+	if !refused(t, cc, ErrCannotJoin) {
+		t.Error("invited user got into a locked call")
+	}
+
+	s.Mu.Lock()
+	s.Locked = false
+	s.Mu.Unlock()
+	offer(carol)
+	if n := len(messagesOfType(drain(cc), "answer")); n != 1 {
+		t.Errorf("invited user got %d answers once unlocked, want 1", n)
+	}
+	if cc.PeerConn != nil {
+		cc.PeerConn.Close()
+	}
+}
+
+func TestLobbyWaitersMustBeAdmittedBeforeOffering(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "owner", "alice")
+	owner, alice := users[0], users[1]
+	room := models.Room{Slug: "standup", Name: "standup", OwnerId: owner, Lobby: true}
+	database.Db.Create(&room)
+	h := NewHub()
+	testClient(h, owner)
+	ac := testClient(h, alice)
+
+	s, _, err := h.JoinRoom(room, models.User{Id: owner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, admitted, err := h.JoinRoom(room, models.User{Id: alice, Name: "alice"}); admitted || err != nil {
+		t.Fatalf("alice admitted=%v err=%v, want to wait in the lobby", admitted, err)
+	}
+	offer := clientMessage(t, alice, "call_offer", fmt.Sprintf(`{"callId":%d,"offer":%s}`, s.ID, audioOffer(t)))
+	h.handleOffer(offer)
+	if !refused(t, ac, ErrAwaitingAdmission) {
+		t.Error("user in the lobby was not told to wait")
+	}
+
+	if err := s.Moderate(owner, models.ModerationRequest{Action: models.ActionAdmit, TargetId: alice}); err != nil {
+		t.Fatal(err)
+	}
+	drain(ac)
+	h.handleOffer(offer)
+	if n := len(messagesOfType(drain(ac), "answer")); n != 1 {
+		t.Errorf("admitted user got %d answers, want 1", n)
+	}
+	if ac.PeerConn != nil {
+		ac.PeerConn.Close()
+	}
+}
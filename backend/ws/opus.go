@@ -0,0 +1,26 @@
+//go:build opus
+
+package ws
+
+import "gopkg.in/hraban/opus.v2"
+
+// Audio mixing needs libopus; build with -tags opus to enable it (see README.md).
+var defaultAudioCodec audioCodec = opusCodec{}
+
+// opusCodec creates libopus decoders and encoders.
+type opusCodec struct{}
+
+func (opusCodec) NewDecoder(sampleRate, channels int) (opusDecoder, error) {
+	return opus.NewDecoder(sampleRate, channels)
+}
+
+func (opusCodec) NewEncoder(sampleRate, channels, bitrate int) (opusEncoder, error) {
+	enc, err := opus.NewEncoder(sampleRate, channels, opus.AppVoIP)
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.SetBitrate(bitrate); err != nil {
+		return nil, err
+	}
+	return enc, nil
+}
@@ -0,0 +1,6 @@
+//go:build !opus
+
+package ws
+
+// Without the opus build tag there is no codec and mixing requests get ErrMixingUnavailable.
+var defaultAudioCodec audioCodec
@@ -0,0 +1,42 @@
+package ws
+
+import (
+	"sync"
+
+	"github.com/pion/rtp"
+)
+
+// packetCacheSize is the number of RTP packets kept per published track for retransmission.
+// It must be a power of two so sequence numbers wrap cleanly onto the ring.
+const packetCacheSize = 1024
+
+// packetCache is a ring buffer of recently forwarded RTP packets keyed by sequence number.
+type packetCache struct {
+	mu      sync.RWMutex
+	packets [packetCacheSize]*rtp.Packet
+}
+
+func newPacketCache() *packetCache {
+	return &packetCache{}
+}
+
+// Push stores a copy of pkt so the caller may reuse its buffers.
+func (pc *packetCache) Push(pkt *rtp.Packet) {
+	cp := &rtp.Packet{Header: pkt.Header.Clone(), Payload: append([]byte(nil), pkt.Payload...)}
+
+	pc.mu.Lock()
+	pc.packets[pkt.SequenceNumber%packetCacheSize] = cp
+	pc.mu.Unlock()
+}
+
+// Get returns the cached packet for seq, or nil if it has already been overwritten.
+func (pc *packetCache) Get(seq uint16) *rtp.Packet {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+
+	pkt := pc.packets[seq%packetCacheSize]
+	if pkt == nil || pkt.SequenceNumber != seq {
+		return nil
+	}
+	return pkt
+}
@@ -0,0 +1,98 @@
+package ws
+
+import (
+	"errors"
+	"io"
+	"log"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/intervalpli"
+	"github.com/pion/interceptor/pkg/nack"
+	"github.com/pion/interceptor/pkg/stats"
+	"github.com/pion/webrtc/v4"
+)
+
+var peerConnectionConfig = webrtc.Configuration{
+	ICEServers: []webrtc.ICEServer{
+		{
+			URLs: []string{"stun:stun.l.google.com:19302"},
+		},
+	},
+}
+
+// newPeerConnection builds a PeerConnection with the SFU's codecs and interceptors.
+// onStats, if set, receives the stats Getter of the new connection.
+func newPeerConnection(config webrtc.Configuration, onStats func(stats.Getter)) (*webrtc.PeerConnection, error) {
+	mediaEngine := &webrtc.MediaEngine{}
+	if err := mediaEngine.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+
+	interceptorRegistry := &interceptor.Registry{}
+	if err := configureInterceptors(mediaEngine, interceptorRegistry); err != nil {
+		return nil, err
+	}
+	intervalPliFactory, err := intervalpli.NewReceiverInterceptor()
+	if err != nil {
+		return nil, err
+	}
+	interceptorRegistry.Add(intervalPliFactory)
+
+	statsFactory, err := stats.NewInterceptor()
+	if err != nil {
+		return nil, err
+	}
+	statsFactory.OnNewPeerConnection(func(_ string, g stats.Getter) {
+		if onStats != nil {
+			onStats(g)
+		}
+	})
+	interceptorRegistry.Add(statsFactory)
+
+	return webrtc.NewAPI(
+		webrtc.WithMediaEngine(mediaEngine),
+		webrtc.WithInterceptorRegistry(interceptorRegistry),
+	).NewPeerConnection(config)
+}
+
+// configureInterceptors registers the default pion interceptors except the NACK responder:
+// NACKs from subscribers are answered by ForwardTrack from its own packet cache, while the
+// generator still requests retransmission of gaps from publishers.
+func configureInterceptors(mediaEngine *webrtc.MediaEngine, interceptorRegistry *interceptor.Registry) error {
+	generator, err := nack.NewGeneratorInterceptor()
+	if err != nil {
+		return err
+	}
+	mediaEngine.RegisterFeedback(webrtc.RTCPFeedback{Type: "nack"}, webrtc.RTPCodecTypeVideo)
+	mediaEngine.RegisterFeedback(webrtc.RTCPFeedback{Type: "nack", Parameter: "pli"}, webrtc.RTPCodecTypeVideo)
+	interceptorRegistry.Add(generator)
+
+	if err := webrtc.ConfigureRTCPReports(interceptorRegistry); err != nil {
+		return err
+	}
+	if err := webrtc.ConfigureSimulcastExtensionHeaders(mediaEngine); err != nil {
+		return err
+	}
+	return webrtc.ConfigureTWCCSender(mediaEngine, interceptorRegistry)
+}
+
+// forwardRTP copies packets from a publisher's remote track into its ForwardTrack until the track ends.
+func forwardRTP(remoteTrack *webrtc.TrackRemote, localTrack *ForwardTrack) {
+	for {
+		// lost packets are NACKed upstream by the nack generator; recovered ones arrive here
+		pkt, _, readErr := remoteTrack.ReadRTP()
+		if readErr != nil {
+			// stop forwarding on read error
+			if !errors.Is(readErr, io.EOF) {
+				log.Printf("remoteTrack read error: %v", readErr)
+			}
+			return
+		}
+
+		// WriteRTP also caches the packet so subscriber NACKs can be answered
+		if err := localTrack.WriteRTP(pkt); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			log.Printf("localTrack write error: %v", err)
+			return
+		}
+	}
+}
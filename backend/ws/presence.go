@@ -0,0 +1,351 @@
+package ws
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+// AwayAfter is how long a connected user can be idle before they show as away.
+const AwayAfter = 5 * time.Minute
+
+// LastActive is when the client last sent anything over its WS connection.
+func (c *Client) LastActive() time.Time {
+	return time.Unix(0, c.lastActive.Load())
+}
+
+// touch records activity from c and brings its user back from automatic away. It runs for every
+// message a client sends, so it only takes the hub lock when the user was away.
+func (h *Hub) touch(c *Client) {
+	c.lastActive.Store(time.Now().UnixNano())
+	if !c.autoAway.CompareAndSwap(true, false) {
+		return
+	}
+
+	h.Mutex.Lock()
+	st, ok := h.UserStatuses[c.UserID]
+	back := ok && st.AutoAway
+	var msg models.UserStatusMessage
+	if back {
+		st.AutoAway = false
+		st.Presence = models.Available
+		msg = *st
+	}
+	h.Mutex.Unlock()
+	if back {
+		h.sendPresence(msg)
+	}
+}
+
+// UpdatePresence applies the presence user chose for themselves and tells everyone.
+func (h *Hub) UpdatePresence(user models.User) {
+	h.Mutex.Lock()
+	st, ok := h.UserStatuses[user.Id]
+	if !ok {
+		st = models.NewUserStatusMessage(user, models.Offline)
+		h.UserStatuses[user.Id] = st
+	}
+	st.SetPresence(user)
+	st.AutoAway = false
+	if cl, ok := h.UserClients[user.Id]; ok {
+		cl.autoAway.Store(false)
+	}
+	msg := *st
+	h.Mutex.Unlock()
+	h.sendPresence(msg)
+}
+
+// StartPresenceSweep marks idle users away and clears expired statuses every interval.
+func (h *Hub) StartPresenceSweep(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			h.sweepPresence(now)
+		}
+	}()
+}
+
+func (h *Hub) sweepPresence(now time.Time) {
+	var changed []models.UserStatusMessage
+	var expired []uint
+
+	h.Mutex.Lock()
+	for uid, st := range h.UserStatuses {
+		if st.StatusExpiresAt != nil && !now.Before(*st.StatusExpiresAt) {
+			st.Presence = models.Available
+			st.StatusText = ""
+			st.StatusEmoji = ""
+			st.StatusExpiresAt = nil
+			st.AutoAway = false
+			if cl, ok := h.UserClients[uid]; ok {
+				cl.autoAway.Store(false)
+			}
+			expired = append(expired, uid)
+			changed = append(changed, *st)
+			continue
+		}
+		// people in a call often don't touch the WS connection, so they never go away
+		cl, connected := h.UserClients[uid]
+		if !connected || st.Status != models.Online || st.Presence != models.Available {
+			continue
+		}
+		if active := cl.LastActive(); !active.IsZero() && now.Sub(active) >= AwayAfter {
+			st.Presence = models.Away
+			st.AutoAway = true
+			cl.autoAway.Store(true)
+			changed = append(changed, *st)
+		}
+	}
+	h.Mutex.Unlock()
+
+	if len(expired) > 0 {
+		if err := database.Db.Model(&models.User{}).Where("id IN ?", expired).Updates(map[string]interface{}{
+			"presence":          models.Available,
+			"status_text":       "",
+			"status_emoji":      "",
+			"status_expires_at": nil,
+		}).Error; err != nil {
+			log.Printf("sweepPresence: failed to clear expired statuses: %v", err)
+		}
+	}
+	for _, msg := range changed {
+		h.sendPresence(msg)
+	}
+}
+
+// sendPresence fans out a presence change made outside the hub lock.
+func (h *Hub) sendPresence(msg models.UserStatusMessage) {
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+	h.fanOutPresence(models.MessageTypeUserStatus, msg)
+}
+
+// MutualContacts returns the users who are contacts of userID and have userID as a contact.
+// Presence is only shared between mutual contacts.
+func MutualContacts(userID uint) []uint {
+	ids, err := loadMutualContacts(userID)
+	if err != nil {
+		log.Printf("MutualContacts: query failed for user %d: %v", userID, err)
+	}
+	return ids
+}
+
+func loadMutualContacts(userID uint) ([]uint, error) {
+	var ids []uint
+	err := database.Db.Table("user_contacts AS a").
+		Joins("JOIN user_contacts AS b ON b.user_id = a.contact_id AND b.contact_id = a.user_id").
+		Where("a.user_id = ?", userID).
+		Distinct().Pluck("a.contact_id", &ids).Error
+	return ids, err
+}
+
+// contactGraph caches each user's mutual contacts, so presence fan-out doesn't query the
+// database while holding h.Mutex. A user's entry is dropped when their contacts change.
+type contactGraph struct {
+	mu      sync.Mutex
+	mutuals map[uint][]uint
+	gen     uint64 // bumped by forget, so a load that raced with it isn't kept
+}
+
+// get returns userID's mutual contacts, loading them on a miss.
+func (g *contactGraph) get(userID uint) []uint {
+	g.mu.Lock()
+	ids, ok := g.mutuals[userID]
+	gen := g.gen
+	g.mu.Unlock()
+	if ok {
+		return ids
+	}
+	ids, err := loadMutualContacts(userID)
+	if err != nil {
+		log.Printf("contactGraph: query failed for user %d: %v", userID, err)
+		return ids
+	}
+	g.mu.Lock()
+	if g.gen == gen {
+		if g.mutuals == nil {
+			g.mutuals = make(map[uint][]uint)
+		}
+		g.mutuals[userID] = ids
+	}
+	g.mu.Unlock()
+	return ids
+}
+
+// forget drops the cached contacts of userIDs.
+func (g *contactGraph) forget(userIDs ...uint) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.gen++
+	for _, uid := range userIDs {
+		delete(g.mutuals, uid)
+	}
+}
+
+// fanOutPresence sends st to its owner and their connected mutual contacts, and records what each
+// contact was sent so the next diff doesn't repeat it. Callers must hold h.Mutex.
+func (h *Hub) fanOutPresence(msgType models.WSMessageType, st models.UserStatusMessage) {
+	view := st.Public()
+	for _, uid := range append(h.contacts.get(st.UserID), st.UserID) {
+		cl, ok := h.UserClients[uid]
+		if !ok {
+			continue
+		}
+		payload := view
+		if uid == st.UserID {
+			payload = st
+		}
+		select {
+		case cl.Send <- models.WebSocketMessage{Type: msgType, Payload: payload, Time: time.Now()}:
+			if uid != st.UserID {
+				if cl.presenceSeen == nil {
+					cl.presenceSeen = make(map[uint]models.UserStatusMessage)
+				}
+				cl.presenceSeen[st.UserID] = view
+			}
+		default:
+			log.Printf("fanOutPresence: send channel full for user %d", uid)
+		}
+	}
+}
+
+// visibleStatuses returns the presence of viewerID's mutual contacts, keyed by user id. Callers
+// must hold h.Mutex.
+func (h *Hub) visibleStatuses(viewerID uint) map[uint]models.UserStatusMessage {
+	out := make(map[uint]models.UserStatusMessage)
+	for _, uid := range h.contacts.get(viewerID) {
+		if st, ok := h.UserStatuses[uid]; ok {
+			out[uid] = st.Public()
+		}
+	}
+	return out
+}
+
+// VisibleStatuses lists the presence viewerID is allowed to see.
+func (h *Hub) VisibleStatuses(viewerID uint) []models.UserStatusMessage {
+	h.Mutex.RLock()
+	defer h.Mutex.RUnlock()
+	visible := h.visibleStatuses(viewerID)
+	out := make([]models.UserStatusMessage, 0, len(visible))
+	for _, st := range visible {
+		out = append(out, st)
+	}
+	return out
+}
+
+// StatusFor returns userID's presence if viewerID is allowed to see it.
+func (h *Hub) StatusFor(viewerID, userID uint) (models.UserStatusMessage, bool) {
+	h.Mutex.RLock()
+	st, ok := h.UserStatuses[userID]
+	var out models.UserStatusMessage
+	if ok {
+		out = *st
+	}
+	h.Mutex.RUnlock()
+	if !ok {
+		return models.UserStatusMessage{}, false
+	}
+	if viewerID == userID {
+		return out, true
+	}
+	for _, uid := range h.contacts.get(viewerID) {
+		if uid == userID {
+			return out.Public(), true
+		}
+	}
+	return models.UserStatusMessage{}, false
+}
+
+// sendPresenceDiffs sends every connected client what changed in the presence it can see since
+// the last update, instead of the full list.
+func (h *Hub) sendPresenceDiffs() {
+	h.Mutex.RLock()
+	connected := make([]uint, 0, len(h.UserClients))
+	for uid := range h.UserClients {
+		connected = append(connected, uid)
+	}
+	h.Mutex.RUnlock()
+	// load contacts missing from the cache before taking the lock
+	for _, uid := range connected {
+		h.contacts.get(uid)
+	}
+
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+	for _, cl := range h.UserClients {
+		h.sendPresenceDiff(cl)
+	}
+}
+
+// SyncPresence brings the listed users up to date right away. Call it with both users whenever
+// contacts change: it also reloads their cached mutual contacts.
+func (h *Hub) SyncPresence(userIDs ...uint) {
+	h.contacts.forget(userIDs...)
+	for _, uid := range userIDs {
+		h.contacts.get(uid)
+	}
+
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+	for _, uid := range userIDs {
+		if cl, ok := h.UserClients[uid]; ok {
+			h.sendPresenceDiff(cl)
+		}
+	}
+}
+
+// sendPresenceDiff sends cl what changed since its last update. Callers must hold h.Mutex.
+func (h *Hub) sendPresenceDiff(cl *Client) {
+	visible := h.visibleStatuses(cl.UserID)
+	var diff models.PresenceDiffMessage
+	for uid, st := range visible {
+		if prev, ok := cl.presenceSeen[uid]; !ok || !prev.Equal(st) {
+			diff.Updated = append(diff.Updated, st)
+		}
+	}
+	for uid := range cl.presenceSeen {
+		if _, ok := visible[uid]; !ok {
+			diff.Removed = append(diff.Removed, uid)
+		}
+	}
+	if len(diff.Updated) == 0 && len(diff.Removed) == 0 {
+		return
+	}
+	select {
+	case cl.Send <- models.WebSocketMessage{Type: models.MessageTypePresenceDiff, Payload: diff, Time: time.Now()}:
+		cl.presenceSeen = visible
+	default:
+		log.Printf("sendPresenceDiff: send channel full for user %d", cl.UserID)
+	}
+}
+
+// holdsCall reports whether calleeID's do-not-disturb keeps a call from ringing; favorite tells
+// whether calleeID marked the caller as a favorite. Callers must hold h.Mutex.
+func (h *Hub) holdsCall(calleeID uint, favorite bool) bool {
+	st, ok := h.UserStatuses[calleeID]
+	if !ok {
+		return false
+	}
+	dnd, allowFavorites := st.DoNotDisturb(time.Now())
+	return dnd && !(allowFavorites && favorite)
+}
+
+// isFavorite reports whether userID marked contactID as a favorite.
+func isFavorite(userID, contactID uint) bool {
+	var n int64
+	database.Db.Model(&models.UserContact{}).
+		Where("user_id = ? AND contact_id = ? AND favorite = ?", userID, contactID, true).Count(&n)
+	return n > 0
+}
+
+// BlockedBetween reports whether either user has blocked the other.
+func BlockedBetween(a, b uint) bool {
+	var n int64
+	database.Db.Model(&models.UserBlock{}).
+		Where("(user_id = ? AND blocked_id = ?) OR (user_id = ? AND blocked_id = ?)", a, b, b, a).Count(&n)
+	return n > 0
+}
@@ -0,0 +1,146 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestRingHoldsCallsInDoNotDisturb(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "caller", "bob", "carol")
+	caller, bob, carol := users[0], users[1], users[2]
+	// carol lets favorites through and marked the caller as one
+	database.Db.Create(&models.UserContact{UserId: carol, ContactId: caller, Favorite: true})
+
+	h := NewHub()
+	clients := make(map[uint]*Client)
+	for _, uid := range users {
+		clients[uid] = testClient(h, uid)
+	}
+	h.UserStatuses[bob].SetPresence(models.User{Presence: models.DoNotDisturb})
+	h.UserStatuses[carol].SetPresence(models.User{Presence: models.DoNotDisturb, DndAllowFavorites: true})
+
+	call := models.Call{CallerId: caller, CalleeIds: []uint{bob, carol}, Status: models.Ringing}
+	database.Db.Create(&call)
+	h.Ring(h.CreateCallSession(&call))
+
+	if n := len(messagesOfType(drain(clients[bob]), "incoming_call")); n != 0 {
+		t.Errorf("bob in do-not-disturb got %d incoming_call", n)
+	}
+	if n := len(messagesOfType(drain(clients[carol]), "incoming_call")); n != 1 {
+		t.Errorf("carol got %d incoming_call from a favorite, want 1", n)
+	}
+	var missed []models.History
+	database.Db.Where("call_id = ? AND status = ?", call.Id, models.Missed).Find(&missed)
+	if len(missed) != 1 || missed[0].UserId != bob {
+		t.Errorf("missed history = %+v, want one row for bob", missed)
+	}
+	if n := len(drain(clients[caller])); n != 0 {
+		t.Errorf("caller was told %d things about a call held by do-not-disturb", n)
+	}
+}
+
+func TestTouchBringsBackFromAutoAway(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "alice", "bob")
+	alice, bob := users[0], users[1]
+	database.Db.Create(&models.UserContact{UserId: alice, ContactId: bob})
+	database.Db.Create(&models.UserContact{UserId: bob, ContactId: alice})
+
+	h := NewHub()
+	ca, cb := testClient(h, alice), testClient(h, bob)
+	ca.lastActive.Store(time.Now().UnixNano())
+	cb.lastActive.Store(time.Now().UnixNano())
+
+	h.touch(ca)
+	if n := len(drain(cb)); n != 0 {
+		t.Fatalf("activity while available sent %d presence updates", n)
+	}
+
+	h.sweepPresence(time.Now().Add(AwayAfter))
+	if st := h.UserStatuses[alice]; st.Presence != models.Away || !st.AutoAway {
+		t.Fatalf("after idling alice is %s (auto %v), want away", st.Presence, st.AutoAway)
+	}
+	drain(cb)
+
+	h.touch(ca)
+	if st := h.UserStatuses[alice]; st.Presence != models.Available || st.AutoAway {
+		t.Errorf("after activity alice is %s (auto %v), want available", st.Presence, st.AutoAway)
+	}
+	got := messagesOfType(drain(cb), models.MessageTypeUserStatus)
+	if len(got) != 1 || got[0].Payload.(models.UserStatusMessage).Presence != models.Available {
+		t.Errorf("bob got %+v, want alice available", got)
+	}
+}
+
+func TestTransferRefusesDoNotDisturb(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "agent", "customer", "colleague")
+	h, s := moderatedCall(t, users[:2])
+	testClient(h, users[2])
+	h.UserStatuses[users[2]].SetPresence(models.User{Presence: models.DoNotDisturb})
+
+	if _, err := h.StartTransfer(s, users[0], users[2], models.TransferBlind); err != ErrTargetUnavailable {
+		t.Errorf("transfer to a colleague in do-not-disturb = %v, want ErrTargetUnavailable", err)
+	}
+}
+
+func TestSyncPresenceReloadsContacts(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "alice", "bob")
+	alice, bob := users[0], users[1]
+	h := NewHub()
+	ca := testClient(h, alice)
+	testClient(h, bob)
+
+	if got := h.VisibleStatuses(alice); len(got) != 0 {
+		t.Fatalf("alice sees %d statuses before adding contacts", len(got))
+	}
+	database.Db.Create(&models.UserContact{UserId: alice, ContactId: bob})
+	database.Db.Create(&models.UserContact{UserId: bob, ContactId: alice})
+	h.SyncPresence(alice, bob)
+
+	diffs := messagesOfType(drain(ca), models.MessageTypePresenceDiff)
+	if len(diffs) != 1 || len(diffs[0].Payload.(models.PresenceDiffMessage).Updated) != 1 {
+		t.Fatalf("alice got diffs %+v, want bob added", diffs)
+	}
+	if got := h.VisibleStatuses(alice); len(got) != 1 || got[0].UserID != bob {
+		t.Errorf("alice sees %+v, want bob", got)
+	}
+
+	database.Db.Where("1 = 1").Delete(&models.UserContact{})
+	h.SyncPresence(alice, bob)
+	diffs = messagesOfType(drain(ca), models.MessageTypePresenceDiff)
+	if len(diffs) != 1 || len(diffs[0].Payload.(models.PresenceDiffMessage).Removed) != 1 {
+		t.Errorf("alice got diffs %+v, want bob removed", diffs)
+	}
+}
+
+func TestPresenceDiffComparesExpiryByValue(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "alice", "bob")
+	alice, bob := users[0], users[1]
+	database.Db.Create(&models.UserContact{UserId: alice, ContactId: bob})
+	database.Db.Create(&models.UserContact{UserId: bob, ContactId: alice})
+	h := NewHub()
+	ca := testClient(h, alice)
+	testClient(h, bob)
+
+	expires := time.Now().Add(time.Hour)
+	h.UserStatuses[bob].SetPresence(models.User{Presence: models.Away, StatusText: "lunch", StatusExpiresAt: &expires})
+	h.sendPresenceDiffs()
+	if n := len(messagesOfType(drain(ca), models.MessageTypePresenceDiff)); n != 1 {
+		t.Fatalf("alice got %d diffs for bob's new status, want 1", n)
+	}
+
+	// the same expiry behind a different pointer, e.g. after reloading the user, is no change
+	same := expires
+	h.UserStatuses[bob].StatusExpiresAt = &same
+	h.sendPresenceDiffs()
+	if n := len(messagesOfType(drain(ca), models.MessageTypePresenceDiff)); n != 0 {
+		t.Errorf("alice got %d diffs for an unchanged status", n)
+	}
+}
@@ -0,0 +1,227 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+	"github.com/pion/webrtc/v4/pkg/media/h264writer"
+	"github.com/pion/webrtc/v4/pkg/media/ivfwriter"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+)
+
+// recordingsDir is where recordings are written, below the uploads folder.
+var recordingsDir = filepath.Join("uploads", "recordings")
+
+const recorderSink = "recorder"
+
+var (
+	ErrRecordingActive   = errors.New("call is already being recorded")
+	ErrRecordingInactive = errors.New("call is not being recorded")
+)
+
+// Recorder writes each published track of a call into its own media file.
+type Recorder struct {
+	CallID    uint
+	StartedBy uint
+	StartedAt time.Time
+
+	dir     string
+	mu      sync.Mutex
+	tracks  map[string]*recordedTrack // trackID -> file
+	stopped bool                      // set by Stop; later tracks are not recorded
+}
+
+type recordedTrack struct {
+	track     *ForwardTrack
+	writer    media.Writer
+	recording models.Recording
+}
+
+func newRecorder(callID, startedBy uint) (*Recorder, error) {
+	dir := filepath.Join(recordingsDir, fmt.Sprint(callID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		CallID:    callID,
+		StartedBy: startedBy,
+		StartedAt: time.Now(),
+		dir:       dir,
+		tracks:    make(map[string]*recordedTrack),
+	}, nil
+}
+
+// Attach starts recording a published track. Tracks with a codec that has no file container are
+// skipped. It creates a file and a database row, so callers must not hold the session lock.
+func (r *Recorder) Attach(publisherID uint, trackID string, track *ForwardTrack) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tracks[trackID]; ok || r.stopped {
+		return
+	}
+
+	codec := track.Codec()
+	now := time.Now()
+	name := fmt.Sprintf("%d_%s_%d_%d", publisherID, track.Kind(), now.Unix(), len(r.tracks))
+	path := filepath.Join(r.dir, name+recordingExt(codec.MimeType))
+	w, err := newMediaWriter(path, codec)
+	if err != nil {
+		log.Printf("Recorder: cannot record track %s of user %d: %v", trackID, publisherID, err)
+		return
+	}
+
+	rec := models.Recording{
+		CallId:    r.CallID,
+		UserId:    publisherID,
+		StartedBy: r.StartedBy,
+		TrackId:   trackID,
+		Kind:      track.Kind().String(),
+		MimeType:  codec.MimeType,
+		Path:      path,
+		StartTime: now,
+	}
+	if err := database.Db.Create(&rec).Error; err != nil {
+		log.Printf("Recorder: failed to save recording row: %v", err)
+	} else {
+		// files are only reachable through the access-controlled recordings API
+		rec.Url = fmt.Sprintf("/recordings/%d/download", rec.Id)
+		database.Db.Model(&rec).Update("url", rec.Url)
+	}
+
+	r.tracks[trackID] = &recordedTrack{track: track, writer: w, recording: rec}
+	track.AddSink(recorderSink, w)
+}
+
+// Stop detaches from every track, finalizes the files and returns the saved recordings.
+func (r *Recorder) Stop() []models.Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stopped = true
+	out := make([]models.Recording, 0, len(r.tracks))
+	now := time.Now()
+	for _, rt := range r.tracks {
+		rt.track.RemoveSink(recorderSink)
+		if err := rt.writer.Close(); err != nil {
+			log.Printf("Recorder: close %s: %v", rt.recording.Path, err)
+		}
+		rt.recording.EndTime = &now
+		if fi, err := os.Stat(rt.recording.Path); err == nil {
+			rt.recording.Size = fi.Size()
+		}
+		if err := database.Db.Save(&rt.recording).Error; err != nil {
+			log.Printf("Recorder: failed to update recording %d: %v", rt.recording.Id, err)
+		}
+		out = append(out, rt.recording)
+	}
+	r.tracks = make(map[string]*recordedTrack)
+	return out
+}
+
+func recordingExt(mimeType string) string {
+	switch strings.ToLower(mimeType) {
+	case strings.ToLower(webrtc.MimeTypeOpus):
+		return ".ogg"
+	case strings.ToLower(webrtc.MimeTypeH264):
+		return ".h264"
+	default:
+		return ".ivf"
+	}
+}
+
+func newMediaWriter(path string, codec webrtc.RTPCodecCapability) (media.Writer, error) {
+	switch strings.ToLower(codec.MimeType) {
+	case strings.ToLower(webrtc.MimeTypeOpus):
+		return oggwriter.New(path, codec.ClockRate, codec.Channels)
+	case strings.ToLower(webrtc.MimeTypeH264):
+		return h264writer.New(path)
+	case strings.ToLower(webrtc.MimeTypeVP8):
+		return ivfwriter.New(path, ivfwriter.WithCodec(webrtc.MimeTypeVP8))
+	case strings.ToLower(webrtc.MimeTypeVP9):
+		return ivfwriter.New(path, ivfwriter.WithCodec(webrtc.MimeTypeVP9))
+	case strings.ToLower(webrtc.MimeTypeAV1):
+		return ivfwriter.New(path, ivfwriter.WithCodec(webrtc.MimeTypeAV1))
+	default:
+		return nil, fmt.Errorf("unsupported codec %s", codec.MimeType)
+	}
+}
+
+// StartRecording begins recording every published track and notifies all participants.
+func (s *CallSession) StartRecording(userID uint) (*Recorder, error) {
+	s.Mu.Lock()
+	if s.Recorder != nil {
+		s.Mu.Unlock()
+		return nil, ErrRecordingActive
+	}
+	rec, err := newRecorder(s.ID, userID)
+	if err != nil {
+		s.Mu.Unlock()
+		return nil, err
+	}
+	s.Recorder = rec
+	tracks := make(map[string]*ForwardTrack, len(s.PublishedTracks))
+	for id, t := range s.PublishedTracks {
+		tracks[id] = t
+	}
+	owners := make(map[string]uint, len(s.PublishedOwners))
+	for id, uid := range s.PublishedOwners {
+		owners[id] = uid
+	}
+	s.Mu.Unlock()
+
+	for id, t := range tracks {
+		rec.Attach(owners[id], id, t)
+	}
+
+	s.Broadcast(models.WebSocketMessage{
+		Type: models.MessageTypeRecordingStarted,
+		Payload: models.RecordingStatusMessage{
+			CallId:    s.ID,
+			UserId:    userID,
+			StartedAt: rec.StartedAt,
+		},
+		Time: time.Now(),
+	}, 0)
+	return rec, nil
+}
+
+// StopRecording finalizes the active recording and notifies all participants.
+func (s *CallSession) StopRecording(userID uint) ([]models.Recording, error) {
+	s.Mu.Lock()
+	rec := s.Recorder
+	s.Recorder = nil
+	s.Mu.Unlock()
+	if rec == nil {
+		return nil, ErrRecordingInactive
+	}
+
+	recordings := rec.Stop()
+	s.Broadcast(models.WebSocketMessage{
+		Type: models.MessageTypeRecordingStopped,
+		Payload: models.RecordingStatusMessage{
+			CallId:     s.ID,
+			UserId:     userID,
+			StartedAt:  rec.StartedAt,
+			Recordings: recordings,
+		},
+		Time: time.Now(),
+	}, 0)
+	return recordings, nil
+}
+
+// IsRecording reports whether a recording is in progress.
+func (s *CallSession) IsRecording() bool {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.Recorder != nil
+}
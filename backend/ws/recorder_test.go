@@ -0,0 +1,79 @@
+package ws
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestRecordingExt(t *testing.T) {
+	for mime, want := range map[string]string{
+		"audio/opus": ".ogg",
+		"video/H264": ".h264",
+		"video/VP8":  ".ivf",
+		"video/AV1":  ".ivf",
+	} {
+		if got := recordingExt(mime); got != want {
+			t.Errorf("recordingExt(%q) = %q, want %q", mime, got, want)
+		}
+	}
+}
+
+func TestRecorderWritesPublishedTracks(t *testing.T) {
+	testDB(t)
+	s := NewCallSession(models.Call{Id: 7, CallerId: 1})
+	s.PublishTrack(1, "before", opusTrack("before"), models.SourceAudio, false)
+	if _, err := s.StartRecording(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.StartRecording(1); err != ErrRecordingActive {
+		t.Fatalf("second start = %v, want ErrRecordingActive", err)
+	}
+	// tracks published while recording are attached after the session lock is released
+	late := vp8Track("late")
+	if err := s.PublishTrack(2, "late", late, models.SourceCamera, false); err != nil {
+		t.Fatal(err)
+	}
+	feed(t, late, 10)
+
+	recs, err := s.StopRecording(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("got %d recordings, want 2", len(recs))
+	}
+	for _, r := range recs {
+		if r.EndTime == nil || r.Url == "" {
+			t.Errorf("recording %+v not finalized", r)
+		}
+		if _, err := os.Stat(r.Path); err != nil {
+			t.Errorf("recording file: %v", err)
+		}
+	}
+	if _, err := s.StopRecording(1); err != ErrRecordingInactive {
+		t.Fatalf("second stop = %v, want ErrRecordingInactive", err)
+	}
+}
+
+func TestRecorderIgnoresTracksAfterStop(t *testing.T) {
+	testDB(t)
+	rec, err := newRecorder(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Attach(1, "a", opusTrack("a"))
+	rec.Attach(1, "a", opusTrack("a"))
+	if got := len(rec.Stop()); got != 1 {
+		t.Fatalf("got %d recordings, want 1", got)
+	}
+	rec.Attach(1, "b", opusTrack("b"))
+
+	var n int64
+	database.Db.Model(&models.Recording{}).Count(&n)
+	if n != 1 {
+		t.Fatalf("%d recording rows, want 1", n)
+	}
+}
@@ -0,0 +1,142 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+var ErrRoomFull = errors.New("room is full")
+
+// Closed reports whether the session has ended.
+func (s *CallSession) Closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Admit lets userID into the call by adding them to its invite list, so the usual offer flow
+// and CanJoin checks accept them.
+func (s *CallSession) Admit(userID uint) error {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if s.removed[userID] {
+		return ErrNotPermitted
+	}
+	if _, ok := s.Participants[userID]; ok || userID == s.hostID() || s.admitted[userID] {
+		return nil
+	}
+	if s.Locked {
+		return ErrCallLocked
+	}
+	if s.MaxParticipants > 0 && len(s.Participants) >= s.MaxParticipants {
+		return ErrRoomFull
+	}
+	for _, id := range s.Call.CalleeIds {
+		if id == userID {
+			return nil
+		}
+	}
+	s.Call.CalleeIds = append(s.Call.CalleeIds, userID)
+	return nil
+}
+
+// roomSession returns the live session of a room; callers must hold h.Mutex.
+func (h *Hub) roomSession(roomID uint) *CallSession {
+	for _, s := range h.CallSessions {
+		s.Mu.RLock()
+		match := s.Call.RoomId != nil && *s.Call.RoomId == roomID
+		s.Mu.RUnlock()
+		if match && !s.Closed() {
+			return s
+		}
+	}
+	return nil
+}
+
+// RoomSession returns the call currently running in a room, if any.
+func (h *Hub) RoomSession(roomID uint) (*CallSession, bool) {
+	h.Mutex.RLock()
+	defer h.Mutex.RUnlock()
+	s := h.roomSession(roomID)
+	return s, s != nil
+}
+
+// JoinRoom attaches user to the call running in room, starting one when the room is empty.
+// The room owner hosts the call whether or not they are present. It reports false when the
+// user was put in the lobby instead.
+func (h *Hub) JoinRoom(room models.Room, user models.User) (*CallSession, bool, error) {
+	session, ok := h.RoomSession(room.Id)
+	if !ok {
+		var err error
+		if session, err = h.startRoomCall(room); err != nil {
+			return nil, false, err
+		}
+	}
+
+	admitted, err := session.RequestEntry(user)
+	if err != nil || !admitted {
+		return session, false, err
+	}
+	h.AttachClient(session, user.Id)
+	return session, true, nil
+}
+
+// startRoomCall starts the call of an empty room. The call row is written before taking the hub
+// lock; if someone else started the room meanwhile, it is deleted and their call is returned.
+func (h *Hub) startRoomCall(room models.Room) (*CallSession, error) {
+	roomID := room.Id
+	call := models.Call{
+		CallerId:  room.OwnerId,
+		StartTime: time.Now(),
+		Status:    models.Ongoing,
+		RoomId:    &roomID,
+	}
+	if err := database.Db.Create(&call).Error; err != nil {
+		return nil, err
+	}
+
+	h.Mutex.Lock()
+	if existing := h.roomSession(room.Id); existing != nil {
+		h.Mutex.Unlock()
+		if err := database.Db.Delete(&call).Error; err != nil {
+			log.Printf("JoinRoom: failed to delete unused call %d: %v", call.Id, err)
+		}
+		return existing, nil
+	}
+	// unlike CreateCallSession, nobody is pulled in until they open the link
+	session := NewCallSession(call)
+	session.MaxParticipants = room.MaxParticipants
+	session.MaxScreenShares = room.MaxScreenShares
+	session.LobbyEnabled = room.Lobby
+	session.AutoAdmit = AutoAdmitRules{Contacts: room.AutoAdmitContacts, Organization: room.AutoAdmitOrganization}
+	session.hub = h
+	h.CallSessions[call.Id] = session
+	h.Mutex.Unlock()
+
+	go session.runStats()
+	if room.AudioMixing {
+		if err := session.SetAudioMixing(true); err != nil {
+			log.Printf("JoinRoom: audio mixing unavailable for room %d: %v", room.Id, err)
+		}
+	}
+	return session, nil
+}
+
+// AttachClient adds userID's WS client to session if they are connected, for users joining a
+// call they were invited to after it started.
+func (h *Hub) AttachClient(session *CallSession, userID uint) bool {
+	h.Mutex.RLock()
+	cl, ok := h.UserClients[userID]
+	h.Mutex.RUnlock()
+	if ok {
+		session.AddParticipant(cl)
+	}
+	return ok
+}
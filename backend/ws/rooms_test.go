@@ -0,0 +1,56 @@
+package ws
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+func TestConcurrentRoomJoinsShareOneCall(t *testing.T) {
+	testDB(t)
+	ids := testUsers(t, "owner", "a", "b", "c", "d", "e")
+	room := models.Room{Slug: "standup", Name: "standup", OwnerId: ids[0]}
+	if err := database.Db.Create(&room).Error; err != nil {
+		t.Fatal(err)
+	}
+	h := NewHub()
+	for _, uid := range ids {
+		testClient(h, uid)
+	}
+
+	sessions := make([]*CallSession, len(ids))
+	var wg sync.WaitGroup
+	for i, uid := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, admitted, err := h.JoinRoom(room, models.User{Id: uid})
+			if err != nil || !admitted {
+				t.Errorf("user %d: admitted=%v err=%v", uid, admitted, err)
+			}
+			sessions[i] = s
+		}()
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	for _, s := range sessions[1:] {
+		if s != sessions[0] {
+			t.Fatalf("joiners ended up in calls %d and %d", sessions[0].ID, s.ID)
+		}
+	}
+	for _, uid := range ids {
+		if !sessions[0].HasParticipant(uid) {
+			t.Errorf("user %d is not in the call", uid)
+		}
+	}
+	var calls int64
+	database.Db.Model(&models.Call{}).Where("room_id = ?", room.Id).Count(&calls)
+	if calls != 1 {
+		t.Errorf("%d calls saved for the room, want 1", calls)
+	}
+}
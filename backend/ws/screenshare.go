@@ -0,0 +1,143 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/webrtc/v4"
+)
+
+// DefaultMaxScreenShares is how many screens a call may show at once unless the host changes it.
+const DefaultMaxScreenShares = 1
+
+// maxScreenSharesLimit caps what a host may configure; each share is a high-bitrate stream to everyone.
+const maxScreenSharesLimit = 8
+
+var (
+	ErrScreenShareLimit   = errors.New("the call already has the maximum number of screen shares")
+	ErrNoScreenShare      = errors.New("user is not sharing their screen")
+	ErrInvalidScreenLimit = errors.New("screen share limit out of range")
+)
+
+// offerTrackSources reads the optional "trackSources" field sent next to an offer's type and sdp,
+// mapping the sender's track ids to what they carry.
+func offerTrackSources(off json.RawMessage) map[string]models.TrackSource {
+	var meta struct {
+		TrackSources map[string]models.TrackSource `json:"trackSources"`
+	}
+	if err := json.Unmarshal(off, &meta); err != nil {
+		return nil
+	}
+	return meta.TrackSources
+}
+
+// trackSource returns the declared source of a track, falling back to its kind.
+func trackSource(declared map[string]models.TrackSource, trackID string, kind webrtc.RTPCodecType) models.TrackSource {
+	if src, ok := declared[trackID]; ok && src.Valid() {
+		return src
+	}
+	if kind == webrtc.RTPCodecTypeAudio {
+		return models.SourceAudio
+	}
+	return models.SourceCamera
+}
+
+// trackInfo describes a published track; callers must hold s.Mu.
+func (s *CallSession) trackInfo(trackID string) models.TrackInfo {
+	return models.TrackInfo{
+		PublisherId: s.PublishedOwners[trackID],
+		TrackId:     trackID,
+		Source:      s.PublishedSources[trackID],
+	}
+}
+
+// screenShareCount counts active screen tracks other than exceptTrack; callers must hold s.Mu.
+func (s *CallSession) screenShareCount(exceptTrack string) int {
+	n := 0
+	for id, src := range s.PublishedSources {
+		if src == models.SourceScreen && id != exceptTrack {
+			n++
+		}
+	}
+	return n
+}
+
+// ScreenShares returns the call's active screen share tracks.
+func (s *CallSession) ScreenShares() []models.TrackInfo {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	out := make([]models.TrackInfo, 0)
+	for id, src := range s.PublishedSources {
+		if src == models.SourceScreen {
+			out = append(out, s.trackInfo(id))
+		}
+	}
+	return out
+}
+
+// SetMaxScreenShares changes how many screens may be shared at once. Lowering it below the number
+// of active shares doesn't stop any of them; it only refuses new ones until enough have ended.
+func (s *CallSession) SetMaxScreenShares(n int) error {
+	if n < 0 || n > maxScreenSharesLimit {
+		return ErrInvalidScreenLimit
+	}
+	s.Mu.Lock()
+	s.MaxScreenShares = n
+	s.Mu.Unlock()
+	return nil
+}
+
+// StopScreenShare ends every screen share of userID on behalf of stoppedBy, e.g. the host taking
+// the floor back from a presenter.
+func (s *CallSession) StopScreenShare(userID, stoppedBy uint) error {
+	s.Mu.RLock()
+	var tracks []string
+	for id, src := range s.PublishedSources {
+		if src == models.SourceScreen && s.PublishedOwners[id] == userID {
+			tracks = append(tracks, id)
+		}
+	}
+	s.Mu.RUnlock()
+	if len(tracks) == 0 {
+		return ErrNoScreenShare
+	}
+	for _, id := range tracks {
+		s.unpublishTrack(id, nil, stoppedBy)
+	}
+	return nil
+}
+
+// screenShareMessage builds a screen share event for info.
+func screenShareMessage(typ models.WSMessageType, callID uint, info models.TrackInfo, stoppedBy uint, reason string) models.WebSocketMessage {
+	return models.WebSocketMessage{
+		Type: typ,
+		Payload: models.ScreenShareMessage{
+			CallId:    callID,
+			UserId:    info.PublisherId,
+			TrackId:   info.TrackId,
+			StoppedBy: stoppedBy,
+			Reason:    reason,
+		},
+		Time: time.Now(),
+	}
+}
+
+// stopRefusedTrack stops receiving a track that wasn't published. Nobody reads it, so its
+// packets would otherwise pile up in the receive buffer.
+func stopRefusedTrack(receiver *webrtc.RTPReceiver) {
+	if err := receiver.Stop(); err != nil {
+		log.Printf("stop refused track: %v", err)
+	}
+}
+
+// rejectScreenShare tells a publisher their screen track was refused.
+func (s *CallSession) rejectScreenShare(c *Client, trackID string, err error) {
+	info := models.TrackInfo{PublisherId: c.UserID, TrackId: trackID, Source: models.SourceScreen}
+	select {
+	case c.Send <- screenShareMessage(models.MessageTypeScreenShareRejected, s.ID, info, 0, err.Error()):
+	default:
+	}
+}
@@ -0,0 +1,85 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/webrtc/v4"
+)
+
+func TestMapMIDsKeepsPublisherMap(t *testing.T) {
+	s := NewCallSession(models.Call{Id: 1, CallerId: 1})
+	camera, screen := vp8Track("cam"), vp8Track("screen")
+	if err := s.PublishTrack(2, "cam", camera, models.SourceCamera, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PublishTrack(2, "screen", screen, models.SourceScreen, false); err != nil {
+		t.Fatal(err)
+	}
+
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	for _, tr := range []*ForwardTrack{camera, screen} {
+		if _, err := pc.AddTrack(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pc.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+
+	participant := &Client{UserID: 3, PeerConn: pc, Send: make(chan models.WebSocketMessage, 4)}
+	s.MapMIDsForParticipant(participant)
+	msgs := drain(participant)
+	if len(msgs) != 2 || msgs[0].Type != "mid-map" || msgs[1].Type != models.MessageTypeTrackMap {
+		t.Fatalf("got %v, want mid-map then track_map", msgs)
+	}
+
+	// existing clients read mid-map values as publisher ids
+	var publishers map[string]uint
+	raw, _ := json.Marshal(msgs[0].Payload)
+	if err := json.Unmarshal(raw, &publishers); err != nil {
+		t.Fatalf("mid-map payload %s: %v", raw, err)
+	}
+	var tracks map[string]models.TrackInfo
+	raw, _ = json.Marshal(msgs[1].Payload)
+	if err := json.Unmarshal(raw, &tracks); err != nil {
+		t.Fatalf("track_map payload %s: %v", raw, err)
+	}
+	if len(publishers) != 2 || len(tracks) != 2 {
+		t.Fatalf("mapped %d publishers and %d tracks, want 2 each", len(publishers), len(tracks))
+	}
+	sources := map[models.TrackSource]bool{}
+	for mid, info := range tracks {
+		if publishers[mid] != 2 || info.PublisherId != 2 {
+			t.Errorf("mid %s: publisher %d / %+v, want 2", mid, publishers[mid], info)
+		}
+		sources[info.Source] = true
+	}
+	if !sources[models.SourceCamera] || !sources[models.SourceScreen] {
+		t.Errorf("sources %v, want camera and screen", sources)
+	}
+}
+
+func TestScreenShareLimit(t *testing.T) {
+	s := NewCallSession(models.Call{Id: 1, CallerId: 1})
+	if err := s.PublishTrack(1, "a", vp8Track("a"), models.SourceScreen, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PublishTrack(2, "b", vp8Track("b"), models.SourceScreen, false); !errors.Is(err, ErrScreenShareLimit) {
+		t.Fatalf("second share = %v, want ErrScreenShareLimit", err)
+	}
+	// cameras aren't limited
+	if err := s.PublishTrack(2, "c", vp8Track("c"), models.SourceCamera, false); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,245 @@
+package ws
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/interceptor/pkg/stats"
+	"github.com/pion/webrtc/v4"
+)
+
+// statsInterval is how often a call session samples participant stats and pushes network_quality.
+const statsInterval = 5 * time.Second
+
+// trackSample is the previous cumulative reading of a stream, used to turn counters into rates.
+type trackSample struct {
+	bytes   uint64
+	packets uint64
+	lost    int64
+	at      time.Time
+}
+
+// runStats samples stats for every participant until the session is closed.
+func (s *CallSession) runStats() {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.collectStats()
+		}
+	}
+}
+
+// GetStats returns the latest stats sample for every participant.
+func (s *CallSession) GetStats() []models.ParticipantStats {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	out := make([]models.ParticipantStats, 0, len(s.Stats))
+	for _, ps := range s.Stats {
+		out = append(out, *ps)
+	}
+	return out
+}
+
+func (s *CallSession) collectStats() {
+	s.Mu.RLock()
+	parts := make([]*Client, 0, len(s.Participants))
+	for _, p := range s.Participants {
+		parts = append(parts, p)
+	}
+	trackIDs := make(map[webrtc.TrackLocal]string, len(s.PublishedTracks))
+	for id, t := range s.PublishedTracks {
+		trackIDs[t] = id
+	}
+	published := make(map[string]*ForwardTrack, len(s.PublishedTracks))
+	for id, t := range s.PublishedTracks {
+		published[id] = t
+	}
+	owners := make(map[string]uint, len(s.PublishedOwners))
+	for id, uid := range s.PublishedOwners {
+		owners[id] = uid
+	}
+	s.Mu.RUnlock()
+
+	now := time.Now()
+	results := make(map[uint]*models.ParticipantStats, len(parts))
+	samples := make(map[string]trackSample)
+	qualities := make(map[uint]models.NetworkQuality, len(parts))
+
+	for _, p := range parts {
+		if p == nil || p.PeerConn == nil {
+			continue
+		}
+		getter := p.StatsGetter()
+		if getter == nil {
+			continue
+		}
+		ps := &models.ParticipantStats{UserId: p.UserID, UpdatedAt: now, Tracks: []models.TrackStats{}}
+		ps.RttMs, ps.AvailableBitrate = candidatePairStats(p.PeerConn)
+
+		worstLoss := 0.0
+		for _, tr := range p.PeerConn.GetTransceivers() {
+			if tr == nil {
+				continue
+			}
+			if rcv := tr.Receiver(); rcv != nil && rcv.Track() != nil {
+				remote := rcv.Track()
+				if st := getter.Get(uint32(remote.SSRC())); st != nil {
+					key := sampleKey(p.UserID, "inbound", uint32(remote.SSRC()))
+					cur := trackSample{
+						bytes:   st.InboundRTPStreamStats.BytesReceived,
+						packets: st.InboundRTPStreamStats.PacketsReceived,
+						lost:    st.InboundRTPStreamStats.PacketsLost,
+						at:      now,
+					}
+					ts := models.TrackStats{
+						TrackId:     remote.ID(),
+						Mid:         tr.Mid(),
+						Kind:        remote.Kind().String(),
+						Direction:   "inbound",
+						Packets:     cur.packets,
+						PacketsLost: cur.lost,
+						JitterMs:    st.InboundRTPStreamStats.Jitter * 1000,
+						RttMs:       float64(st.RemoteOutboundRTPStreamStats.RoundTripTime) / float64(time.Millisecond),
+						NackCount:   st.InboundRTPStreamStats.NACKCount,
+						PliCount:    st.InboundRTPStreamStats.PLICount,
+					}
+					if ft, ok := published[remote.ID()]; ok {
+						ts.Frames = ft.Frames()
+					}
+					ts.Bitrate, ts.FractionLost = s.rates(key, cur)
+					samples[key] = cur
+					if ts.FractionLost > worstLoss {
+						worstLoss = ts.FractionLost
+					}
+					ps.Tracks = append(ps.Tracks, ts)
+				}
+			}
+
+			if snd := tr.Sender(); snd != nil && snd.Track() != nil {
+				params := snd.GetParameters()
+				var st *stats.Stats
+				var ssrc webrtc.SSRC
+				if len(params.Encodings) > 0 {
+					ssrc = params.Encodings[0].SSRC
+					st = getter.Get(uint32(ssrc))
+				}
+				if st != nil {
+					trackID := trackIDs[snd.Track()]
+					key := sampleKey(p.UserID, "outbound", uint32(ssrc))
+					cur := trackSample{
+						bytes:   st.OutboundRTPStreamStats.BytesSent,
+						packets: st.OutboundRTPStreamStats.PacketsSent,
+						at:      now,
+					}
+					ts := models.TrackStats{
+						TrackId:      trackID,
+						Mid:          tr.Mid(),
+						Kind:         snd.Track().Kind().String(),
+						Direction:    "outbound",
+						PublisherId:  owners[trackID],
+						Packets:      cur.packets,
+						PacketsLost:  st.RemoteInboundRTPStreamStats.PacketsLost,
+						FractionLost: st.RemoteInboundRTPStreamStats.FractionLost,
+						JitterMs:     st.RemoteInboundRTPStreamStats.Jitter * 1000,
+						RttMs:        float64(st.RemoteInboundRTPStreamStats.RoundTripTime) / float64(time.Millisecond),
+						NackCount:    st.OutboundRTPStreamStats.NACKCount,
+						PliCount:     st.OutboundRTPStreamStats.PLICount,
+					}
+					if ft, ok := published[trackID]; ok {
+						ts.Frames = ft.Frames()
+						ts.Retransmissions = ft.Retransmissions(ssrc)
+					}
+					ts.Bitrate, _ = s.rates(key, cur)
+					samples[key] = cur
+					if ts.FractionLost > worstLoss {
+						worstLoss = ts.FractionLost
+					}
+					if ps.RttMs == 0 && ts.RttMs > 0 {
+						ps.RttMs = ts.RttMs
+					}
+					ps.Tracks = append(ps.Tracks, ts)
+				}
+			}
+		}
+
+		ps.Quality = classifyQuality(worstLoss, ps.RttMs)
+		results[p.UserID] = ps
+		qualities[p.UserID] = ps.Quality
+	}
+
+	s.Mu.Lock()
+	s.Stats = results
+	s.statsSamples = samples
+	s.Mu.Unlock()
+
+	if len(qualities) == 0 {
+		return
+	}
+	msg := models.WebSocketMessage{
+		Type:    models.MessageTypeNetworkQuality,
+		Payload: models.NetworkQualityMessage{CallId: s.ID, Participants: qualities},
+		Time:    now,
+	}
+	for _, p := range parts {
+		select {
+		case p.Send <- msg:
+		default:
+			log.Printf("collectStats: send channel full for user %d", p.UserID)
+		}
+	}
+}
+
+// rates derives bitrate and the fraction of packets lost since the previous sample of key.
+func (s *CallSession) rates(key string, cur trackSample) (bitrate, fractionLost float64) {
+	s.Mu.RLock()
+	prev, ok := s.statsSamples[key]
+	s.Mu.RUnlock()
+	if !ok || !cur.at.After(prev.at) {
+		return 0, 0
+	}
+	if cur.bytes >= prev.bytes {
+		bitrate = float64(cur.bytes-prev.bytes) * 8 / cur.at.Sub(prev.at).Seconds()
+	}
+	if cur.packets >= prev.packets {
+		lost := cur.lost - prev.lost
+		expected := float64(cur.packets-prev.packets) + float64(lost)
+		if lost > 0 && expected > 0 {
+			fractionLost = float64(lost) / expected
+		}
+	}
+	return bitrate, fractionLost
+}
+
+// candidatePairStats returns the RTT in milliseconds and available outgoing bitrate of the nominated ICE pair.
+func candidatePairStats(pc *webrtc.PeerConnection) (rttMs, availableBitrate float64) {
+	for _, st := range pc.GetStats() {
+		pair, ok := st.(webrtc.ICECandidatePairStats)
+		if !ok || !pair.Nominated {
+			continue
+		}
+		return pair.CurrentRoundTripTime * 1000, pair.AvailableOutgoingBitrate
+	}
+	return 0, 0
+}
+
+// classifyQuality maps loss and round trip time onto the signal bars shown by clients.
+func classifyQuality(fractionLost, rttMs float64) models.NetworkQuality {
+	switch {
+	case fractionLost < 0.02 && rttMs < 150:
+		return models.QualityGood
+	case fractionLost < 0.08 && rttMs < 400:
+		return models.QualityFair
+	default:
+		return models.QualityPoor
+	}
+}
+
+func sampleKey(userID uint, direction string, ssrc uint32) string {
+	return fmt.Sprintf("%s:%d:%d", direction, userID, ssrc)
+}
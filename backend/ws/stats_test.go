@@ -0,0 +1,78 @@
+package ws
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/interceptor/pkg/stats"
+)
+
+type fakeGetter struct{}
+
+func (fakeGetter) Get(uint32) *stats.Stats { return nil }
+
+func TestClassifyQuality(t *testing.T) {
+	tests := []struct {
+		loss, rtt float64
+		want      models.NetworkQuality
+	}{
+		{0, 20, models.QualityGood},
+		{0.01, 149, models.QualityGood},
+		{0.05, 100, models.QualityFair},
+		{0, 300, models.QualityFair},
+		{0.1, 50, models.QualityPoor},
+		{0, 500, models.QualityPoor},
+	}
+	for _, tt := range tests {
+		if got := classifyQuality(tt.loss, tt.rtt); got != tt.want {
+			t.Errorf("classifyQuality(%v, %v) = %s, want %s", tt.loss, tt.rtt, got, tt.want)
+		}
+	}
+}
+
+func TestRates(t *testing.T) {
+	s := NewCallSession(models.Call{Id: 1})
+	start := time.Now()
+	s.statsSamples = map[string]trackSample{"k": {bytes: 1000, packets: 100, lost: 0, at: start}}
+
+	bitrate, loss := s.rates("k", trackSample{bytes: 2000, packets: 190, lost: 10, at: start.Add(time.Second)})
+	if bitrate != 8000 {
+		t.Errorf("bitrate = %v, want 8000", bitrate)
+	}
+	if loss != 0.1 {
+		t.Errorf("fractionLost = %v, want 0.1", loss)
+	}
+	if b, l := s.rates("unknown", trackSample{at: start}); b != 0 || l != 0 {
+		t.Errorf("rates without a previous sample = %v, %v", b, l)
+	}
+}
+
+// The stats interceptor sets the getter from the peer connection's goroutine while the stats
+// ticker reads it; run with -race.
+func TestStatsGetterConcurrentAccess(t *testing.T) {
+	c := &Client{}
+	if c.StatsGetter() != nil {
+		t.Fatal("getter set before the peer connection exists")
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			var g stats.Getter = fakeGetter{}
+			c.statsGetter.Store(&g)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			c.StatsGetter()
+		}
+	}()
+	wg.Wait()
+	if c.StatsGetter() == nil {
+		t.Fatal("getter not stored")
+	}
+}
@@ -0,0 +1,380 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+// transferRingTimeout is how long a transfer target has to answer.
+const transferRingTimeout = 30 * time.Second
+
+var (
+	ErrTransferPending   = errors.New("call already has a transfer in progress")
+	ErrNoTransfer        = errors.New("no transfer in progress for this call")
+	ErrNotOneToOne       = errors.New("only 1:1 calls can be transferred")
+	ErrTargetUnavailable = errors.New("transfer target is not available")
+	ErrInvalidTransfer   = errors.New("invalid transfer request")
+)
+
+// transfer is a pending transfer and the timer that gives up on an unanswered target.
+type transfer struct {
+	models.CallTransfer
+	timer *time.Timer
+}
+
+// markAnswered records that userID negotiated media in the call.
+func (s *CallSession) markAnswered(userID uint) {
+	s.Mu.Lock()
+	s.answered[userID] = true
+	s.Mu.Unlock()
+}
+
+// Answered reports whether userID has picked up the call.
+func (s *CallSession) Answered(userID uint) bool {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.answered[userID]
+}
+
+// otherParty returns the only other participant of a 1:1 call with userID.
+func (s *CallSession) otherParty(userID uint) (uint, error) {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	if _, ok := s.Participants[userID]; !ok {
+		return 0, ErrNotInCall
+	}
+	if len(s.Participants) != 2 || s.Parent != nil || len(s.breakouts) > 0 {
+		return 0, ErrNotOneToOne
+	}
+	for uid := range s.Participants {
+		if uid != userID {
+			return uid, nil
+		}
+	}
+	return 0, ErrNotOneToOne
+}
+
+// Transfer returns the transfer in progress for a call, if any.
+func (h *Hub) Transfer(callID uint) (models.CallTransfer, bool) {
+	h.Mutex.RLock()
+	defer h.Mutex.RUnlock()
+	t, ok := h.transfers[callID]
+	if !ok {
+		return models.CallTransfer{}, false
+	}
+	return t.CallTransfer, true
+}
+
+// HandleTransfer applies one step of a transfer on behalf of userID.
+func (h *Hub) HandleTransfer(userID uint, req models.TransferRequest) (models.CallTransfer, error) {
+	switch req.Action {
+	case models.TransferStart:
+		session, ok := h.GetCallSession(req.CallId)
+		if !ok {
+			return models.CallTransfer{}, ErrNotInCall
+		}
+		return h.StartTransfer(session, userID, req.TargetId, req.Mode)
+	case models.TransferAccept:
+		return h.AcceptTransfer(req.CallId, userID)
+	case models.TransferDecline:
+		return h.endTransfer(req.CallId, userID, models.TransferDeclined)
+	case models.TransferComplete:
+		return h.CompleteTransfer(req.CallId, userID)
+	case models.TransferCancel:
+		return h.endTransfer(req.CallId, userID, models.TransferCancelled)
+	}
+	return models.CallTransfer{}, ErrInvalidTransfer
+}
+
+// StartTransfer rings targetID to take over the other party of fromID's 1:1 call. In a blind
+// transfer fromID drops out as soon as the target answers; in an attended one fromID talks to the
+// target first, while the other party waits, and then completes or cancels the transfer.
+func (h *Hub) StartTransfer(session *CallSession, fromID, targetID uint, mode models.TransferMode) (models.CallTransfer, error) {
+	if mode == "" {
+		mode = models.TransferBlind
+	}
+	if !mode.Valid() || targetID == 0 || targetID == fromID {
+		return models.CallTransfer{}, ErrInvalidTransfer
+	}
+	party, err := session.otherParty(fromID)
+	if err != nil {
+		return models.CallTransfer{}, err
+	}
+	if targetID == party {
+		return models.CallTransfer{}, ErrInvalidTransfer
+	}
+	if BlockedBetween(targetID, party) || BlockedBetween(targetID, fromID) {
+		return models.CallTransfer{}, ErrTargetUnavailable
+	}
+	// in an attended transfer the transferor calls the target first
+	caller := party
+	if mode == models.TransferAttended {
+		caller = fromID
+	}
+	favorite := isFavorite(targetID, caller)
+
+	h.Mutex.Lock()
+	if _, pending := h.transfers[session.ID]; pending {
+		h.Mutex.Unlock()
+		return models.CallTransfer{}, ErrTransferPending
+	}
+	status, online := h.UserStatuses[targetID]
+	if _, connected := h.UserClients[targetID]; !connected || !online || status.Status != models.Online || h.holdsCall(targetID, favorite) {
+		h.Mutex.Unlock()
+		return models.CallTransfer{}, ErrTargetUnavailable
+	}
+
+	prev := session.ID
+	call := models.Call{
+		CallerId:        caller,
+		CalleeIds:       []uint{targetID},
+		StartTime:       time.Now(),
+		Status:          models.Ringing,
+		TransferredFrom: &prev,
+	}
+	if err := database.Db.Create(&call).Error; err != nil {
+		h.Mutex.Unlock()
+		return models.CallTransfer{}, err
+	}
+	// built by hand: CreateCallSession would make both parties participants of two calls at once
+	next := NewCallSession(call)
+	next.hub = h
+	h.CallSessions[call.Id] = next
+	go next.runStats()
+
+	t := &transfer{CallTransfer: models.CallTransfer{
+		CallId:      session.ID,
+		NewCallId:   call.Id,
+		Mode:        mode,
+		FromId:      fromID,
+		PartyId:     party,
+		TargetId:    targetID,
+		Status:      models.TransferRinging,
+		RequestedAt: time.Now(),
+	}}
+	t.timer = time.AfterFunc(transferRingTimeout, func() { h.expireTransfer(t) })
+	h.transfers[session.ID] = t
+	out := t.CallTransfer
+	h.Mutex.Unlock()
+
+	h.Ring(next)
+	h.notifyTransfer(out)
+	return out, nil
+}
+
+// AcceptTransfer connects the target. A blind transfer completes right away; an attended one
+// moves the transferor into the new call to talk to the target.
+func (h *Hub) AcceptTransfer(callID, userID uint) (models.CallTransfer, error) {
+	h.Mutex.Lock()
+	t, ok := h.transfers[callID]
+	if !ok || t.Status != models.TransferRinging {
+		h.Mutex.Unlock()
+		return models.CallTransfer{}, ErrNoTransfer
+	}
+	if t.TargetId != userID {
+		h.Mutex.Unlock()
+		return models.CallTransfer{}, ErrNotPermitted
+	}
+	t.timer.Stop()
+	orig, next := h.CallSessions[t.CallId], h.CallSessions[t.NewCallId]
+	target := h.UserClients[userID]
+	if t.Mode == models.TransferAttended {
+		t.Status = models.TransferConsulting
+	}
+	h.Mutex.Unlock()
+	if orig == nil || next == nil {
+		return models.CallTransfer{}, ErrNoTransfer
+	}
+
+	next.Mu.Lock()
+	next.Call.Status = models.Ongoing
+	next.Mu.Unlock()
+	if err := database.Db.Model(&models.Call{}).Where("id = ?", next.ID).Update("status", models.Ongoing).Error; err != nil {
+		log.Printf("AcceptTransfer: failed to update call %d: %v", next.ID, err)
+	}
+	if target != nil {
+		next.AddParticipant(target)
+	}
+
+	if t.Mode == models.TransferBlind {
+		return h.completeTransfer(t, orig, next), nil
+	}
+	moveParticipant(t.FromId, orig, next)
+	out := h.transferState(t)
+	h.notifyTransfer(out)
+	return out, nil
+}
+
+// CompleteTransfer finishes an attended transfer once the transferor has talked to the target.
+func (h *Hub) CompleteTransfer(callID, userID uint) (models.CallTransfer, error) {
+	h.Mutex.RLock()
+	t, ok := h.transfers[callID]
+	var orig, next *CallSession
+	if ok {
+		orig, next = h.CallSessions[t.CallId], h.CallSessions[t.NewCallId]
+	}
+	h.Mutex.RUnlock()
+	if !ok || orig == nil || next == nil {
+		return models.CallTransfer{}, ErrNoTransfer
+	}
+	if t.FromId != userID {
+		return models.CallTransfer{}, ErrNotPermitted
+	}
+	if h.transferState(t).Status != models.TransferConsulting {
+		return models.CallTransfer{}, ErrInvalidTransfer
+	}
+	return h.completeTransfer(t, orig, next), nil
+}
+
+// completeTransfer moves the party into the call with the target, drops the transferor and ends
+// the original call.
+func (h *Hub) completeTransfer(t *transfer, orig, next *CallSession) models.CallTransfer {
+	h.Mutex.Lock()
+	t.Status = models.TransferCompleted
+	delete(h.transfers, t.CallId)
+	out := t.CallTransfer
+	h.Mutex.Unlock()
+
+	moveParticipant(t.PartyId, orig, next)
+	next.Mu.Lock()
+	next.HostId = t.PartyId
+	next.Mu.Unlock()
+	// in an attended transfer the transferor is in the new call by now
+	if t.Mode == models.TransferAttended {
+		next.RemoveParticipant(t.FromId, nil)
+	}
+
+	end := time.Now()
+	history := []models.History{
+		{UserId: t.FromId, CallId: orig.ID, Status: models.Transferred, Role: callRole(orig, t.FromId), EndTime: end, TransferredTo: &out.TargetId},
+		{UserId: t.PartyId, CallId: orig.ID, Status: models.Transferred, Role: callRole(orig, t.PartyId), EndTime: end, TransferredTo: &out.TargetId},
+	}
+	if err := database.Db.Create(&history).Error; err != nil {
+		log.Printf("completeTransfer: failed to record history for call %d: %v", orig.ID, err)
+	}
+	h.finishCall(orig, models.Ended)
+
+	h.notifyTransfer(out)
+	// the transferor is free again
+	h.updateUserOnlineStatus(t.FromId, models.Online)
+	h.Mutex.Lock()
+	if st, ok := h.UserStatuses[t.FromId]; ok {
+		st.Status = models.Online
+	}
+	h.broadcastUserStatus(t.FromId, models.Online)
+	h.Mutex.Unlock()
+	return out
+}
+
+// expireTransfer gives up on a target that didn't answer in time.
+func (h *Hub) expireTransfer(t *transfer) {
+	h.Mutex.RLock()
+	current := h.transfers[t.CallId] == t
+	h.Mutex.RUnlock()
+	if current {
+		h.endTransfer(t.CallId, t.TargetId, models.TransferUnanswered)
+	}
+}
+
+// endTransfer abandons a transfer: the target declined or didn't answer, or the transferor
+// cancelled. Anyone moved into the new call goes back to the original one.
+func (h *Hub) endTransfer(callID, userID uint, status models.TransferStatus) (models.CallTransfer, error) {
+	h.Mutex.Lock()
+	t, ok := h.transfers[callID]
+	if !ok {
+		h.Mutex.Unlock()
+		return models.CallTransfer{}, ErrNoTransfer
+	}
+	allowed := t.FromId
+	if status != models.TransferCancelled {
+		allowed = t.TargetId
+	}
+	if userID != allowed || (status != models.TransferCancelled && t.Status != models.TransferRinging) {
+		h.Mutex.Unlock()
+		return models.CallTransfer{}, ErrNotPermitted
+	}
+	t.timer.Stop()
+	consulting := t.Status == models.TransferConsulting
+	t.Status = status
+	delete(h.transfers, callID)
+	orig, next := h.CallSessions[t.CallId], h.CallSessions[t.NewCallId]
+	out := t.CallTransfer
+	h.Mutex.Unlock()
+
+	if consulting && orig != nil && next != nil {
+		moveParticipant(t.FromId, next, orig)
+	}
+	if next != nil {
+		callStatus := models.Missed
+		if consulting {
+			callStatus = models.Ended
+		} else if err := database.Db.Create(&models.History{
+			UserId: t.TargetId, CallId: next.ID, Status: models.Missed, Role: "callee", EndTime: time.Now(),
+		}).Error; err != nil {
+			log.Printf("endTransfer: failed to record history for call %d: %v", next.ID, err)
+		}
+		h.finishCall(next, callStatus)
+	}
+	h.notifyTransfer(out)
+	return out, nil
+}
+
+// finishCall records a call as over, closes its session and forgets it.
+func (h *Hub) finishCall(s *CallSession, status models.CallStatus) {
+	s.end(status)
+	h.forgetCallSessions(s)
+}
+
+// end records the call as over and closes the session.
+func (s *CallSession) end(status models.CallStatus) {
+	t := time.Now()
+	s.Mu.Lock()
+	s.Call.Status = status
+	s.Call.EndTime = &t
+	s.Mu.Unlock()
+	if err := database.Db.Model(&models.Call{}).Where("id = ?", s.ID).
+		Updates(map[string]interface{}{"status": status, "end_time": &t}).Error; err != nil {
+		log.Printf("end call %d: failed to save: %v", s.ID, err)
+	}
+	s.Close()
+}
+
+// forgetCallSessions removes closed sessions from the hub.
+func (h *Hub) forgetCallSessions(sessions ...*CallSession) {
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+	for _, s := range sessions {
+		if h.CallSessions[s.ID] == s {
+			delete(h.CallSessions, s.ID)
+		}
+	}
+}
+
+// transferState returns a snapshot of t.
+func (h *Hub) transferState(t *transfer) models.CallTransfer {
+	h.Mutex.RLock()
+	defer h.Mutex.RUnlock()
+	return t.CallTransfer
+}
+
+// notifyTransfer sends the transfer's state to everyone involved in it.
+func (h *Hub) notifyTransfer(t models.CallTransfer) {
+	msg := models.WebSocketMessage{Type: models.MessageTypeCallTransfer, Payload: t, Time: time.Now()}
+	for _, uid := range []uint{t.FromId, t.PartyId, t.TargetId} {
+		h.SendToUser(uid, msg)
+	}
+}
+
+// callRole names userID's side of the call in history rows.
+func callRole(s *CallSession, userID uint) string {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	if s.Call.CallerId == userID {
+		return "caller"
+	}
+	return "callee"
+}
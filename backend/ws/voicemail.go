@@ -0,0 +1,284 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// voicemailDir is where voicemail is written, below the uploads folder.
+var voicemailDir = filepath.Join("uploads", "voicemail")
+
+const (
+	voicemailSink = "voicemail"
+	// MaxVoicemailLength stops a voicemail that is still being recorded.
+	MaxVoicemailLength = 3 * time.Minute
+	// unansweredAfter is how long a callee rings before the caller is offered voicemail.
+	unansweredAfter = DefaultForwardAfter
+)
+
+var (
+	ErrVoicemailActive     = errors.New("a voicemail is already being recorded")
+	ErrNoVoicemail         = errors.New("no voicemail is being recorded")
+	ErrVoicemailNotAllowed = errors.New("only the caller can leave voicemail for a callee who hasn't answered")
+	ErrVoicemailEmpty      = errors.New("no media was recorded")
+)
+
+// voicemailRecorder writes the caller's audio and camera tracks while they leave a voicemail.
+type voicemailRecorder struct {
+	mu     sync.Mutex
+	vm     models.Voicemail
+	dir    string
+	tracks map[string]voicemailTrack // trackID -> file
+	timer  *time.Timer
+}
+
+type voicemailTrack struct {
+	track  *ForwardTrack
+	writer media.Writer
+}
+
+// attach records a track of the caller. Only the first audio and the first camera track are kept.
+func (v *voicemailRecorder) attach(trackID string, track *ForwardTrack, source models.TrackSource) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.tracks == nil || source == models.SourceScreen {
+		return
+	}
+	codec := track.Codec()
+	kind := track.Kind()
+	if (kind == webrtc.RTPCodecTypeAudio && v.vm.AudioPath != "") || (kind == webrtc.RTPCodecTypeVideo && v.vm.VideoPath != "") {
+		return
+	}
+	path := filepath.Join(v.dir, kind.String()+recordingExt(codec.MimeType))
+	w, err := newMediaWriter(path, codec)
+	if err != nil {
+		log.Printf("Voicemail: cannot record track %s of user %d: %v", trackID, v.vm.FromId, err)
+		return
+	}
+	if kind == webrtc.RTPCodecTypeAudio {
+		v.vm.AudioPath, v.vm.AudioMimeType = path, codec.MimeType
+	} else {
+		v.vm.VideoPath, v.vm.VideoMimeType = path, codec.MimeType
+	}
+	v.tracks[trackID] = voicemailTrack{track: track, writer: w}
+	track.AddSink(voicemailSink, w)
+}
+
+// finish detaches from the tracks and closes the files. Later attach calls are ignored.
+func (v *voicemailRecorder) finish() models.Voicemail {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.timer != nil {
+		v.timer.Stop()
+	}
+	for _, t := range v.tracks {
+		t.track.RemoveSink(voicemailSink)
+		if err := t.writer.Close(); err != nil {
+			log.Printf("Voicemail: close file of voicemail %d: %v", v.vm.Id, err)
+		}
+	}
+	v.tracks = nil
+
+	now := time.Now()
+	v.vm.EndTime = &now
+	v.vm.DurationSeconds = int(now.Sub(v.vm.StartTime).Round(time.Second).Seconds())
+	v.vm.Size = 0
+	for _, p := range []string{v.vm.AudioPath, v.vm.VideoPath} {
+		if fi, err := os.Stat(p); p != "" && err == nil {
+			v.vm.Size += fi.Size()
+		}
+	}
+	return v.vm
+}
+
+// StartVoicemail starts recording callerID's published tracks as a voicemail for calleeID, who
+// must be a callee who hasn't answered. Recording stops on its own after MaxVoicemailLength.
+func (s *CallSession) StartVoicemail(callerID, calleeID uint) (models.Voicemail, error) {
+	if BlockedBetween(callerID, calleeID) {
+		return models.Voicemail{}, ErrVoicemailNotAllowed
+	}
+	s.Mu.RLock()
+	err := s.voicemailAllowed(callerID, calleeID)
+	s.Mu.RUnlock()
+	if err != nil {
+		return models.Voicemail{}, err
+	}
+
+	// the row and folder are made without holding the session lock, and dropped again if another
+	// voicemail started or the callee answered meanwhile
+	rec := &voicemailRecorder{
+		vm:     models.Voicemail{CallId: s.ID, FromId: callerID, ToId: calleeID, StartTime: time.Now()},
+		tracks: make(map[string]voicemailTrack),
+	}
+	if err := database.Db.Create(&rec.vm).Error; err != nil {
+		return models.Voicemail{}, err
+	}
+	rec.dir = filepath.Join(voicemailDir, fmt.Sprint(rec.vm.Id))
+	if err := os.MkdirAll(rec.dir, 0755); err != nil {
+		database.Db.Delete(&rec.vm)
+		return models.Voicemail{}, err
+	}
+
+	s.Mu.Lock()
+	if err := s.voicemailAllowed(callerID, calleeID); err != nil {
+		s.Mu.Unlock()
+		if derr := DeleteVoicemail(rec.vm); derr != nil {
+			log.Printf("Voicemail: failed to drop voicemail %d: %v", rec.vm.Id, derr)
+		}
+		return models.Voicemail{}, err
+	}
+	s.voicemail = rec
+	tracks := make(map[string]*ForwardTrack)
+	for id, t := range s.PublishedTracks {
+		if s.PublishedOwners[id] == callerID {
+			tracks[id] = t
+		}
+	}
+	sources := make(map[string]models.TrackSource, len(tracks))
+	for id := range tracks {
+		sources[id] = s.PublishedSources[id]
+	}
+	s.Mu.Unlock()
+
+	for id, t := range tracks {
+		rec.attach(id, t, sources[id])
+	}
+	rec.mu.Lock()
+	rec.timer = time.AfterFunc(MaxVoicemailLength, func() {
+		if _, err := s.StopVoicemail(callerID); err != nil && !errors.Is(err, ErrNoVoicemail) {
+			log.Printf("Voicemail: auto-stop in call %d: %v", s.ID, err)
+		}
+	})
+	vm := rec.vm
+	rec.mu.Unlock()
+	return vm, nil
+}
+
+// voicemailAllowed reports why callerID can't start a voicemail for calleeID, if they can't.
+// Callers must hold s.Mu.
+func (s *CallSession) voicemailAllowed(callerID, calleeID uint) error {
+	if s.voicemail != nil {
+		return ErrVoicemailActive
+	}
+	callee := false
+	for _, id := range s.Call.CalleeIds {
+		callee = callee || id == calleeID
+	}
+	if callerID != s.Call.CallerId || calleeID == callerID || !callee || s.answered[calleeID] {
+		return ErrVoicemailNotAllowed
+	}
+	return nil
+}
+
+// StopVoicemail finishes the voicemail callerID is leaving and delivers it to the callee.
+func (s *CallSession) StopVoicemail(callerID uint) (models.Voicemail, error) {
+	rec := s.takeVoicemail(callerID)
+	if rec == nil {
+		return models.Voicemail{}, ErrNoVoicemail
+	}
+	return s.saveVoicemail(rec)
+}
+
+// CancelVoicemail discards the voicemail callerID is leaving.
+func (s *CallSession) CancelVoicemail(callerID uint) error {
+	rec := s.takeVoicemail(callerID)
+	if rec == nil {
+		return ErrNoVoicemail
+	}
+	return DeleteVoicemail(rec.finish())
+}
+
+func (s *CallSession) takeVoicemail(callerID uint) *voicemailRecorder {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	rec := s.voicemail
+	if rec == nil || rec.vm.FromId != callerID {
+		return nil
+	}
+	s.voicemail = nil
+	return rec
+}
+
+// saveVoicemail finalizes rec and hands it to the callee; a voicemail without media is dropped.
+func (s *CallSession) saveVoicemail(rec *voicemailRecorder) (models.Voicemail, error) {
+	vm := rec.finish()
+	if vm.AudioPath == "" && vm.VideoPath == "" {
+		if err := DeleteVoicemail(vm); err != nil {
+			log.Printf("Voicemail: failed to drop empty voicemail %d: %v", vm.Id, err)
+		}
+		return vm, ErrVoicemailEmpty
+	}
+	if err := database.Db.Save(&vm).Error; err != nil {
+		return vm, err
+	}
+	// delivery takes the hub lock, which Close may be called under
+	if s.hub != nil {
+		go s.hub.deliverVoicemail(vm)
+	}
+	return vm, nil
+}
+
+// DeleteVoicemail removes a voicemail and its files.
+func DeleteVoicemail(vm models.Voicemail) error {
+	if err := os.RemoveAll(filepath.Join(voicemailDir, fmt.Sprint(vm.Id))); err != nil {
+		return err
+	}
+	return database.Db.Delete(&models.Voicemail{}, vm.Id).Error
+}
+
+// deliverVoicemail pushes a new voicemail to its recipient and marks it delivered if they are connected.
+func (h *Hub) deliverVoicemail(vm models.Voicemail) {
+	if !h.SendToUser(vm.ToId, models.WebSocketMessage{Type: models.MessageTypeVoicemailNew, Payload: vm, Time: time.Now()}) {
+		return
+	}
+	if err := database.Db.Model(&vm).Update("delivered_at", time.Now()).Error; err != nil {
+		log.Printf("deliverVoicemail: update voicemail %d: %v", vm.Id, err)
+	}
+}
+
+// deliverPendingVoicemails pushes the voicemail userID received while offline.
+func (h *Hub) deliverPendingVoicemails(client *Client) {
+	var pending []models.Voicemail
+	if err := database.Db.Where("to_id = ? AND end_time IS NOT NULL AND delivered_at IS NULL", client.UserID).
+		Order("id").Limit(pendingMessageBatch).Find(&pending).Error; err != nil {
+		log.Printf("deliverPendingVoicemails: query for user %d: %v", client.UserID, err)
+		return
+	}
+	for _, vm := range pending {
+		h.deliverVoicemail(vm)
+	}
+}
+
+// notifyUnavailable tells the caller that calleeID can't take the call. Callers must not hold
+// h.Mutex.
+func (h *Hub) notifyUnavailable(call models.Call, calleeID uint, reason models.UnavailableReason) {
+	h.SendToUser(call.CallerId, models.WebSocketMessage{
+		Type:    models.MessageTypeCalleeUnavailable,
+		Payload: models.CalleeUnavailableMessage{CallId: call.Id, UserId: calleeID, Reason: reason},
+		Time:    time.Now(),
+	})
+}
+
+// reportIfUnanswered offers the caller voicemail if calleeID still hasn't picked up.
+func (h *Hub) reportIfUnanswered(session *CallSession, calleeID uint) {
+	h.Mutex.RLock()
+	ringing := h.CallSessions[session.ID] == session && !session.Answered(calleeID)
+	h.Mutex.RUnlock()
+	if !ringing {
+		return
+	}
+	session.Mu.RLock()
+	call := session.Call
+	session.Mu.RUnlock()
+	h.notifyUnavailable(call, calleeID, models.CalleeUnanswered)
+}
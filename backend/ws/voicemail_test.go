@@ -0,0 +1,126 @@
+package ws
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/database"
+	"github.com/Neb-iyu/facetime-app/backend/models"
+)
+
+// ringingCall creates a hub session in which caller rings callees, with everyone connected.
+func ringingCall(t *testing.T, caller uint, callees ...uint) (*Hub, *CallSession) {
+	t.Helper()
+	h := NewHub()
+	call := models.Call{CallerId: caller, CalleeIds: callees, Status: models.Ringing}
+	if err := database.Db.Create(&call).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, uid := range append([]uint{caller}, callees...) {
+		testClient(h, uid)
+	}
+	return h, h.CreateCallSession(&call)
+}
+
+func TestVoicemailLifecycle(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "caller", "bob")
+	caller, bob := users[0], users[1]
+	h, s := ringingCall(t, caller, bob)
+
+	if _, err := s.StartVoicemail(bob, caller); err != ErrVoicemailNotAllowed {
+		t.Errorf("callee leaving voicemail = %v, want ErrVoicemailNotAllowed", err)
+	}
+	if _, err := s.StartVoicemail(caller, bob); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.StartVoicemail(caller, bob); err != ErrVoicemailActive {
+		t.Errorf("second voicemail = %v, want ErrVoicemailActive", err)
+	}
+	// a track published while recording is attached after the session lock is released
+	mic := opusTrack("mic")
+	if err := s.PublishTrack(caller, "mic", mic, models.SourceAudio, false); err != nil {
+		t.Fatal(err)
+	}
+	feed(t, mic, 10)
+
+	vm, err := s.StopVoicemail(caller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.EndTime == nil || vm.AudioPath == "" || vm.VideoPath != "" {
+		t.Fatalf("voicemail %+v, want a finished audio voicemail", vm)
+	}
+	if _, err := os.Stat(vm.AudioPath); err != nil {
+		t.Errorf("voicemail file: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(messagesOfType(drain(h.UserClients[bob]), models.MessageTypeVoicemailNew)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("bob was not sent the voicemail")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var stored models.Voicemail
+	if err := database.Db.First(&stored, vm.Id).Error; err != nil || stored.DeliveredAt == nil {
+		t.Errorf("stored voicemail %+v (%v), want delivered", stored, err)
+	}
+
+	if _, err := s.StopVoicemail(caller); err != ErrNoVoicemail {
+		t.Errorf("second stop = %v, want ErrNoVoicemail", err)
+	}
+}
+
+func TestVoicemailWithoutMediaIsDropped(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "caller", "bob")
+	_, s := ringingCall(t, users[0], users[1])
+
+	vm, err := s.StartVoicemail(users[0], users[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.StopVoicemail(users[0]); err != ErrVoicemailEmpty {
+		t.Errorf("stopping an empty voicemail = %v, want ErrVoicemailEmpty", err)
+	}
+	if _, err := s.StartVoicemail(users[0], users[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CancelVoicemail(users[0]); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	database.Db.Model(&models.Voicemail{}).Count(&n)
+	if n != 0 {
+		t.Errorf("%d voicemail rows left, want 0", n)
+	}
+	if _, err := os.Stat(filepath.Join(voicemailDir, fmt.Sprint(vm.Id))); !os.IsNotExist(err) {
+		t.Errorf("voicemail folder left behind: %v", err)
+	}
+}
+
+func TestRingReportsUnavailableCallees(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "caller", "bob", "carol")
+	caller, bob, carol := users[0], users[1], users[2]
+	h := NewHub()
+	cc := testClient(h, caller)
+	testClient(h, carol)
+	h.UserStatuses[carol].Status = models.Busy
+
+	call := models.Call{CallerId: caller, CalleeIds: []uint{bob, carol}, Status: models.Ringing}
+	database.Db.Create(&call)
+	h.Ring(h.CreateCallSession(&call))
+
+	reasons := make(map[uint]models.UnavailableReason)
+	for _, m := range messagesOfType(drain(cc), models.MessageTypeCalleeUnavailable) {
+		p := m.Payload.(models.CalleeUnavailableMessage)
+		reasons[p.UserId] = p.Reason
+	}
+	if reasons[bob] != models.CalleeOffline || reasons[carol] != models.CalleeBusy {
+		t.Errorf("caller was told %v, want bob offline and carol busy", reasons)
+	}
+}
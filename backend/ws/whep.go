@@ -0,0 +1,137 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/webrtc/v4"
+)
+
+var (
+	ErrWHEPResourceNotFound = errors.New("WHEP resource not found")
+	ErrNoTracksToView       = errors.New("no published tracks to view")
+)
+
+// WHEPViewer is a receive-only peer connection created through the WHEP egress endpoint.
+// Viewers are not participants: they don't appear in Participants and never publish.
+type WHEPViewer struct {
+	ID       string
+	UserID   uint
+	PeerConn *webrtc.PeerConnection
+	JoinedAt time.Time
+}
+
+// AddWHEPViewer answers a WHEP offer with the session's published tracks. If trackIDs is empty
+// every published track is sent. WHEP has no server-initiated renegotiation, so tracks published
+// after the viewer connected are not added; the viewer reconnects to pick them up.
+func (s *CallSession) AddWHEPViewer(userID uint, offerSDP string, trackIDs []string) (*WHEPViewer, string, error) {
+	s.Mu.RLock()
+	tracks := make([]*ForwardTrack, 0, len(s.PublishedTracks))
+	if len(trackIDs) == 0 {
+		for _, t := range s.PublishedTracks {
+			tracks = append(tracks, t)
+		}
+	} else {
+		for _, id := range trackIDs {
+			if t, ok := s.PublishedTracks[id]; ok {
+				tracks = append(tracks, t)
+			}
+		}
+	}
+	s.Mu.RUnlock()
+	if len(tracks) == 0 {
+		return nil, "", ErrNoTracksToView
+	}
+
+	id, err := newResourceID()
+	if err != nil {
+		return nil, "", err
+	}
+	pc, err := newPeerConnection(peerConnectionConfig, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, t := range tracks {
+		sender, err := pc.AddTrack(t)
+		if err != nil {
+			pc.Close()
+			return nil, "", err
+		}
+		go t.ServeRTCP(sender)
+	}
+	viewer := &WHEPViewer{ID: id, UserID: userID, PeerConn: pc, JoinedAt: time.Now()}
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		if state == webrtc.PeerConnectionStateFailed || state == webrtc.PeerConnectionStateClosed {
+			if err := s.RemoveWHEPViewer(id); err != nil && !errors.Is(err, ErrWHEPResourceNotFound) {
+				log.Printf("WHEP cleanup error for %s: %v", id, err)
+			}
+		}
+	})
+
+	answer, err := answerOffer(pc, offerSDP)
+	if err != nil {
+		pc.Close()
+		return nil, "", err
+	}
+
+	s.Mu.Lock()
+	s.WHEPViewers[id] = viewer
+	s.Mu.Unlock()
+	s.notifyViewerCount()
+
+	return viewer, answer, nil
+}
+
+// GetWHEPViewer returns the WHEP viewer with the given resource id.
+func (s *CallSession) GetWHEPViewer(id string) (*WHEPViewer, bool) {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	v, ok := s.WHEPViewers[id]
+	return v, ok
+}
+
+// RemoveWHEPViewer disconnects a viewer.
+func (s *CallSession) RemoveWHEPViewer(id string) error {
+	s.Mu.Lock()
+	v, ok := s.WHEPViewers[id]
+	delete(s.WHEPViewers, id)
+	s.Mu.Unlock()
+	if !ok {
+		return ErrWHEPResourceNotFound
+	}
+	s.notifyViewerCount()
+	return v.PeerConn.Close()
+}
+
+// Viewers returns the currently connected WHEP viewers.
+func (s *CallSession) Viewers() []*WHEPViewer {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	out := make([]*WHEPViewer, 0, len(s.WHEPViewers))
+	for _, v := range s.WHEPViewers {
+		out = append(out, v)
+	}
+	return out
+}
+
+// notifyViewerCount tells the host how many viewers are watching.
+func (s *CallSession) notifyViewerCount() {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	host, ok := s.Participants[s.hostID()]
+	if !ok || host == nil {
+		return
+	}
+	msg := models.WebSocketMessage{
+		Type:    models.MessageTypeViewerCount,
+		Payload: models.ViewerCountMessage{CallId: s.ID, Count: len(s.WHEPViewers)},
+		Time:    time.Now(),
+	}
+	select {
+	case host.Send <- msg:
+	default:
+	}
+}
@@ -0,0 +1,43 @@
+package ws
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/webrtc/v4"
+)
+
+func TestWHEPViewerLifecycle(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "host", "viewer")
+	h, s := moderatedCall(t, users[:1])
+	recvOnly := func(pc *webrtc.PeerConnection) error {
+		_, err := pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly})
+		return err
+	}
+
+	if _, _, err := s.AddWHEPViewer(users[1], offerFrom(t, recvOnly), nil); err != ErrNoTracksToView {
+		t.Fatalf("viewing a call without tracks = %v, want ErrNoTracksToView", err)
+	}
+	if err := s.PublishTrack(users[0], "mic", opusTrack("mic"), models.SourceAudio, false); err != nil {
+		t.Fatal(err)
+	}
+	viewer, answer, err := s.AddWHEPViewer(users[1], offerFrom(t, recvOnly), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(answer, "a=sendonly") {
+		t.Errorf("answer to a viewer doesn't send:\n%s", answer)
+	}
+	if n := len(s.Viewers()); n != 1 {
+		t.Errorf("%d viewers, want 1", n)
+	}
+	if err := s.RemoveWHEPViewer(viewer.ID); err != nil {
+		t.Fatal(err)
+	}
+	counts := messagesOfType(drain(h.UserClients[users[0]]), models.MessageTypeViewerCount)
+	if len(counts) != 2 || counts[1].Payload.(models.ViewerCountMessage).Count != 0 {
+		t.Errorf("host was sent viewer counts %+v, want 1 then 0", counts)
+	}
+}
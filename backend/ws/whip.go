@@ -0,0 +1,140 @@
+package ws
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/webrtc/v4"
+)
+
+var ErrWHIPResourceNotFound = errors.New("WHIP resource not found")
+
+// WHIPPublisher is a publish-only peer connection created through the WHIP ingest endpoint
+// (e.g. OBS, ffmpeg or a headless test client). It is not a call participant: it only feeds
+// its tracks into the session.
+type WHIPPublisher struct {
+	ID       string
+	UserID   uint
+	PeerConn *webrtc.PeerConnection
+
+	mu       sync.Mutex
+	trackIDs []string
+}
+
+// AddWHIPPublisher answers a WHIP offer from userID and publishes every track it sends. Video is
+// published as source (camera when empty), so an encoder capturing a desktop can present it.
+// The returned answer already contains all ICE candidates since WHIP clients don't trickle by default.
+func (s *CallSession) AddWHIPPublisher(userID uint, offerSDP string, source models.TrackSource) (*WHIPPublisher, string, error) {
+	id, err := newResourceID()
+	if err != nil {
+		return nil, "", err
+	}
+	pc, err := newPeerConnection(peerConnectionConfig, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	pub := &WHIPPublisher{ID: id, UserID: userID, PeerConn: pc}
+
+	pc.OnTrack(func(remoteTrack *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		// external encoders often use fixed ids like "video", so scope them to the resource
+		trackID := fmt.Sprintf("whip-%s-%s", id, remoteTrack.ID())
+		localTrack := NewForwardTrack(remoteTrack.Codec().RTPCodecCapability, remoteTrack.Kind().String(), fmt.Sprintf("whip-%s", id))
+		localTrack.SetKeyframeRequester(pliRequester(pc, remoteTrack.SSRC()))
+
+		src := models.SourceAudio
+		if remoteTrack.Kind() == webrtc.RTPCodecTypeVideo {
+			src = models.SourceCamera
+			if source.Valid() {
+				src = source
+			}
+		}
+		if err := s.PublishTrack(userID, trackID, localTrack, src, true); err != nil {
+			log.Printf("WHIP publisher %s: track %s refused: %v", id, trackID, err)
+			stopRefusedTrack(receiver)
+			return
+		}
+
+		pub.mu.Lock()
+		pub.trackIDs = append(pub.trackIDs, trackID)
+		pub.mu.Unlock()
+
+		forwardRTP(remoteTrack, localTrack)
+	})
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		if state == webrtc.PeerConnectionStateFailed || state == webrtc.PeerConnectionStateClosed {
+			if err := s.RemoveWHIPPublisher(id); err != nil && !errors.Is(err, ErrWHIPResourceNotFound) {
+				log.Printf("WHIP cleanup error for %s: %v", id, err)
+			}
+		}
+	})
+
+	answer, err := answerOffer(pc, offerSDP)
+	if err != nil {
+		pc.Close()
+		return nil, "", err
+	}
+
+	s.Mu.Lock()
+	s.WHIPPublishers[id] = pub
+	s.Mu.Unlock()
+
+	return pub, answer, nil
+}
+
+// GetWHIPPublisher returns the WHIP publisher with the given resource id.
+func (s *CallSession) GetWHIPPublisher(id string) (*WHIPPublisher, bool) {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	pub, ok := s.WHIPPublishers[id]
+	return pub, ok
+}
+
+// RemoveWHIPPublisher stops a WHIP publisher and unpublishes its tracks.
+func (s *CallSession) RemoveWHIPPublisher(id string) error {
+	s.Mu.Lock()
+	pub, ok := s.WHIPPublishers[id]
+	delete(s.WHIPPublishers, id)
+	s.Mu.Unlock()
+	if !ok {
+		return ErrWHIPResourceNotFound
+	}
+
+	pub.mu.Lock()
+	trackIDs := pub.trackIDs
+	pub.trackIDs = nil
+	pub.mu.Unlock()
+	for _, trackID := range trackIDs {
+		s.UnpublishTrack(trackID)
+	}
+	return pub.PeerConn.Close()
+}
+
+// answerOffer applies an SDP offer and returns the answer once ICE gathering has completed.
+func answerOffer(pc *webrtc.PeerConnection, offerSDP string) (string, error) {
+	if err := pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: offerSDP}); err != nil {
+		return "", err
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return "", err
+	}
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		return "", err
+	}
+	<-gatherComplete
+	return pc.LocalDescription().SDP, nil
+}
+
+func newResourceID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,61 @@
+package ws
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Neb-iyu/facetime-app/backend/models"
+	"github.com/pion/webrtc/v4"
+)
+
+// offerFrom returns the SDP offer of a fresh peer connection set up by prepare.
+func offerFrom(t *testing.T, prepare func(pc *webrtc.PeerConnection) error) string {
+	t.Helper()
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	if err := prepare(pc); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pc.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	return offer.SDP
+}
+
+func TestWHIPPublisherLifecycle(t *testing.T) {
+	testDB(t)
+	users := testUsers(t, "host", "encoder")
+	_, s := moderatedCall(t, users)
+
+	offer := offerFrom(t, func(pc *webrtc.PeerConnection) error {
+		track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", "obs")
+		if err != nil {
+			return err
+		}
+		_, err = pc.AddTrack(track)
+		return err
+	})
+	pub, answer, err := s.AddWHIPPublisher(users[1], offer, models.SourceScreen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(answer, "a=recvonly") {
+		t.Errorf("answer to a send-only encoder doesn't receive:\n%s", answer)
+	}
+	if got, ok := s.GetWHIPPublisher(pub.ID); !ok || got != pub {
+		t.Fatal("publisher not registered")
+	}
+	if err := s.RemoveWHIPPublisher(pub.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveWHIPPublisher(pub.ID); err != ErrWHIPResourceNotFound {
+		t.Errorf("second removal = %v, want ErrWHIPResourceNotFound", err)
+	}
+}